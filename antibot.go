@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const debugDir = "debug"
+
+// Errore restituito quando l'endpoint risponde con una pagina di challenge anti-bot
+// (es. Cloudflare) invece del JSON atteso. Va trattato come un blocco temporaneo.
+var errAntiBotChallenge = errors.New("anti-bot challenge detected")
+
+// Funzione per riconoscere una risposta HTML/non JSON al posto dei dati degli store
+func isChallengeResponse(contentType string, body []byte) bool {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) > 0 && trimmed[0] == '<' {
+		return true
+	}
+	return strings.Contains(strings.ToLower(contentType), "text/html")
+}
+
+// Funzione per salvare il corpo di una risposta nella cartella debug/ (solo con -log-level debug)
+func saveDebugBody(prefix string, body []byte) (string, error) {
+	if logger.minLevel > levelDebug || readOnlyMode {
+		return "", nil
+	}
+	if err := os.MkdirAll(debugDir, 0755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(debugDir, fmt.Sprintf("%s-%s.html", prefix, time.Now().Format("20060102-150405")))
+	return path, os.WriteFile(path, body, 0644)
+}
+
+// Intervallo di attesa aggiuntivo dopo un blocco: cresce di backoff_factor ad ogni blocco
+// consecutivo, tra backoff_min e backoff_max (default 1m, 1h, fattore 2)
+const (
+	defaultBlockBackoffMin    = time.Minute
+	defaultBlockBackoffMax    = time.Hour
+	defaultBlockBackoffFactor = 2.0
+)
+
+func blockBackoffLimits() (time.Duration, time.Duration, float64) {
+	minBackoff, maxBackoff, factor := defaultBlockBackoffMin, defaultBlockBackoffMax, defaultBlockBackoffFactor
+	if config.BackoffMin.Duration > 0 {
+		minBackoff = config.BackoffMin.Duration
+	}
+	if config.BackoffMax.Duration > 0 {
+		maxBackoff = config.BackoffMax.Duration
+	}
+	if config.BackoffFactor > 1 {
+		factor = config.BackoffFactor
+	}
+	if maxBackoff < minBackoff {
+		maxBackoff = minBackoff
+	}
+	return minBackoff, maxBackoff, factor
+}
+
+func nextBlockBackoff(current time.Duration) time.Duration {
+	minBackoff, maxBackoff, factor := blockBackoffLimits()
+	if current < minBackoff {
+		return minBackoff
+	}
+	next := time.Duration(float64(current) * factor)
+	if next > maxBackoff {
+		return maxBackoff
+	}
+	return next
+}
+
+// Funzione per ridurre il backoff dopo un controllo riuscito: azzerato subito (default),
+// oppure diviso per backoff_recovery per tornare gradualmente all'intervallo normale
+func recoverBlockBackoff(current time.Duration) time.Duration {
+	if config.BackoffRecovery <= 1 {
+		return 0
+	}
+	minBackoff, _, _ := blockBackoffLimits()
+	next := time.Duration(float64(current) / config.BackoffRecovery)
+	if next < minBackoff {
+		return 0
+	}
+	return next
+}
+
+// Funzione per salvare (solo a livello debug) il corpo di una pagina di challenge
+func reportChallenge(body []byte) {
+	if path, err := saveDebugBody("challenge", body); err != nil {
+		logger.Warn("Failed to save challenge body", logFields{"error": err})
+	} else if path != "" {
+		logger.Debug("Challenge body saved", logFields{"path": path})
+	}
+}
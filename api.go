@@ -0,0 +1,302 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Numero di risultati recenti conservati per GET /results
+const apiMaxResults = 200
+
+// API HTTP locale per controllare lo sniper da script o da un'interfaccia web (-api-addr).
+// Le modifiche agli store passano dai file di configurazione e vengono applicate con la
+// stessa ricarica di SIGHUP; ogni richiesta richiede il token in "Authorization: Bearer".
+type controlAPI struct {
+	token    string
+	reload   chan<- os.Signal
+	checkNow chan struct{}
+
+	// Serializza lettura e scrittura del file degli Store ID tra richieste concorrenti
+	storesMu sync.Mutex
+
+	mu        sync.Mutex
+	results   []CheckResult
+	started   time.Time
+	cycle     int
+	lastCheck time.Time
+	nextCheck map[string]time.Time
+	intervals map[string]string
+}
+
+// Stato restituito da GET /status
+type apiStatus struct {
+	StartedAt time.Time            `json:"started_at"`
+	Cycle     int                  `json:"cycle"`
+	LastCheck time.Time            `json:"last_check,omitempty"`
+	NextCheck map[string]time.Time `json:"next_check"`
+	// Intervallo effettivo per paese, che include l'eventuale backoff anti-bot
+	Intervals map[string]string `json:"intervals"`
+	StoreIDs  []string          `json:"store_ids"`
+	// Monitoraggio in pausa (POST /pause) e da quando
+	Paused      bool      `json:"paused"`
+	PausedSince time.Time `json:"paused_since,omitempty"`
+}
+
+// Funzione per avviare l'API sull'indirizzo indicato; il server si ferma insieme a ctx.
+// Senza api_token configurato viene generato un token casuale valido per questa esecuzione.
+func startControlAPI(ctx context.Context, addr string, reload chan<- os.Signal) (*controlAPI, error) {
+	token := config.APIToken
+	if token == "" {
+		buf := make([]byte, 16)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+		token = hex.EncodeToString(buf)
+		outputf("Control API token for this run: %s\n", token)
+	}
+
+	api := &controlAPI{
+		token:     token,
+		reload:    reload,
+		checkNow:  make(chan struct{}, 1),
+		started:   time.Now(),
+		nextCheck: make(map[string]time.Time),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", api.handleStatus)
+	mux.HandleFunc("/stores", api.handleStores)
+	mux.HandleFunc("/stores/", api.handleStores)
+	mux.HandleFunc("/check", api.handleCheck)
+	mux.HandleFunc("/results", api.handleResults)
+	mux.HandleFunc("/pause", api.handlePause)
+	mux.HandleFunc("/resume", api.handlePause)
+
+	// Apriamo la porta prima di avviare il server, così un indirizzo occupato o non valido
+	// viene restituito al chiamante invece di comparire solo nel log
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("control API: %w", err)
+	}
+
+	server := &http.Server{Addr: addr, Handler: api.authorize(mux), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("Control API stopped", logFields{"addr": addr, "error": err})
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	logger.Info("Control API listening", logFields{"addr": listener.Addr().String()})
+	return api, nil
+}
+
+func (a *controlAPI) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(a.token)) != 1 {
+			writeAPIError(w, http.StatusUnauthorized, errors.New("invalid or missing token"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Funzione per registrare i risultati di un controllo (ignorata se l'API non è attiva)
+func (a *controlAPI) recordResults(results []CheckResult) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.results = append(a.results, results...)
+	if extra := len(a.results) - apiMaxResults; extra > 0 {
+		a.results = append([]CheckResult(nil), a.results[extra:]...)
+	}
+}
+
+// Funzione per registrare la fine di un ciclo e i prossimi controlli pianificati
+func (a *controlAPI) recordCycle(cycle int, schedules []*countrySchedule) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.cycle = cycle
+	a.lastCheck = time.Now()
+	a.nextCheck = make(map[string]time.Time, len(schedules))
+	a.intervals = make(map[string]string, len(schedules))
+	for _, schedule := range schedules {
+		a.nextCheck[schedule.Country] = schedule.NextCheck
+		a.intervals[schedule.Country] = schedule.effectiveInterval().String()
+	}
+}
+
+// Canale delle richieste di controllo immediato (nil se l'API non è attiva)
+func (a *controlAPI) checkRequests() <-chan struct{} {
+	if a == nil {
+		return nil
+	}
+	return a.checkNow
+}
+
+func (a *controlAPI) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	storeIDs, err := readStoreIDs()
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	a.mu.Lock()
+	status := apiStatus{StartedAt: a.started, Cycle: a.cycle, LastCheck: a.lastCheck, NextCheck: a.nextCheck, Intervals: a.intervals, StoreIDs: storeIDs}
+	a.mu.Unlock()
+	if paused, since := monitorPause.state(); paused {
+		status.Paused, status.PausedSince = true, since
+	}
+	writeAPIJSON(w, http.StatusOK, status)
+}
+
+// GET /stores, POST /stores {"id": "..."}, DELETE /stores/{id}
+func (a *controlAPI) handleStores(w http.ResponseWriter, r *http.Request) {
+	// Lettura, modifica e scrittura del file avvengono sotto lock: due POST/DELETE
+	// concorrenti non possono perdere l'una le modifiche dell'altra
+	a.storesMu.Lock()
+	defer a.storesMu.Unlock()
+
+	ids, err := readStoreIDs()
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeAPIJSON(w, http.StatusOK, ids)
+
+	case http.MethodPost:
+		var entry StoreEntry
+		if err := json.NewDecoder(r.Body).Decode(&entry); err != nil {
+			writeAPIError(w, http.StatusBadRequest, fmt.Errorf("expected {\"id\": \"...\"}: %v", err))
+			return
+		}
+		id := strings.TrimSpace(entry.ID)
+		if !validStoreID(id) {
+			writeAPIError(w, http.StatusBadRequest, fmt.Errorf("invalid store ID %q", id))
+			return
+		}
+		for _, existing := range ids {
+			if existing == id {
+				writeAPIError(w, http.StatusConflict, fmt.Errorf("store %s is already monitored", id))
+				return
+			}
+		}
+		if err := writeStoreIDs(append(ids, id)); err != nil {
+			writeAPIError(w, http.StatusInternalServerError, err)
+			return
+		}
+		a.requestReload()
+		writeAPIJSON(w, http.StatusCreated, append(ids, id))
+
+	case http.MethodDelete:
+		id := strings.TrimPrefix(r.URL.Path, "/stores/")
+		var kept []string
+		for _, existing := range ids {
+			if existing != id {
+				kept = append(kept, existing)
+			}
+		}
+		if len(kept) == len(ids) {
+			writeAPIError(w, http.StatusNotFound, fmt.Errorf("store %s is not monitored", id))
+			return
+		}
+		if err := writeStoreIDs(kept); err != nil {
+			writeAPIError(w, http.StatusInternalServerError, err)
+			return
+		}
+		a.requestReload()
+		writeAPIJSON(w, http.StatusOK, kept)
+
+	default:
+		writeAPIError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+	}
+}
+
+// POST /check: avvia subito un controllo di tutti i paesi
+func (a *controlAPI) handleCheck(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeAPIError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	select {
+	case a.checkNow <- struct{}{}:
+	default:
+		// Un controllo è già in attesa
+	}
+	writeAPIJSON(w, http.StatusAccepted, map[string]string{"status": "check scheduled"})
+}
+
+// POST /pause e POST /resume: sospende o riprende i controlli
+func (a *controlAPI) handlePause(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeAPIError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	pause := r.URL.Path == "/pause"
+	monitorPause.set(pause, "api")
+	status := "resumed"
+	if pause {
+		status = "paused"
+	}
+	writeAPIJSON(w, http.StatusOK, map[string]string{"status": status})
+}
+
+// GET /results: risultati più recenti, dal più vecchio al più nuovo
+func (a *controlAPI) handleResults(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	a.mu.Lock()
+	results := append([]CheckResult{}, a.results...)
+	a.mu.Unlock()
+	writeAPIJSON(w, http.StatusOK, results)
+}
+
+// Le modifiche ai file vengono applicate dallo sniper come una ricarica SIGHUP
+func (a *controlAPI) requestReload() {
+	select {
+	case a.reload <- syscall.SIGHUP:
+	default:
+	}
+}
+
+func writeAPIJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+func writeAPIError(w http.ResponseWriter, status int, err error) {
+	writeAPIJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"sync"
+)
+
+// Avvisi dello store (attention_message: sospensione temporanea di un servizio, trasferimento,
+// ...), ripuliti dai tag HTML. Ogni avviso viene registrato nel log una sola volta per store,
+// e di nuovo solo se cambia.
+var (
+	attentionLoggedMu sync.Mutex
+	attentionLogged   = make(map[string]string)
+)
+
+// Funzione per ottenere l'avviso attivo dello store ("" se non ce n'è)
+func attentionMessage(store Location) string {
+	return plainText(store.AttentionMessage)
+}
+
+// Funzione per registrare nel log l'avviso di uno store, se nuovo o cambiato
+func logAttentionMessage(result CheckResult) {
+	message := attentionMessage(result.Store)
+	attentionLoggedMu.Lock()
+	previous, seen := attentionLogged[result.StoreID]
+	attentionLogged[result.StoreID] = message
+	attentionLoggedMu.Unlock()
+
+	switch {
+	case message != "" && message != previous:
+		logger.Warn("Store notice", logFields{"country": result.Country, "store": result.StoreID, "name": result.Name, "attention_message": message})
+	case message == "" && seen && previous != "":
+		logger.Info("Store notice removed", logFields{"country": result.Country, "store": result.StoreID, "name": result.Name})
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Predicato che decide se uno store va considerato "disponibile"
+type availabilityPredicate func(Location) bool
+
+// Predicati selezionabili con "availability_mode"
+var availabilityPredicates = map[string]availabilityPredicate{
+	// Solo il flag di disponibilità del prodotto
+	"strict": func(store Location) bool {
+		return store.ProductAvailability
+	},
+	// Disponibilità del prodotto oppure click & collect attivo nello store
+	"click_collect": func(store Location) bool {
+		return store.ProductAvailability || store.EnableClickCollect
+	},
+	// Disponibilità del prodotto oppure consegna in negozio attiva
+	"delivery": func(store Location) bool {
+		return store.ProductAvailability || store.EnableDeliveryToStore
+	},
+	// Uno qualsiasi dei segnali
+	"any": func(store Location) bool {
+		return store.ProductAvailability || store.EnableClickCollect || store.EnableDeliveryToStore
+	},
+}
+
+const defaultAvailabilityMode = "strict"
+
+// Funzione per validare la modalità configurata
+func validateAvailabilityMode(mode string) error {
+	if _, ok := availabilityPredicates[mode]; ok {
+		return nil
+	}
+	modes := make([]string, 0, len(availabilityPredicates))
+	for name := range availabilityPredicates {
+		modes = append(modes, name)
+	}
+	sort.Strings(modes)
+	return fmt.Errorf("invalid availability_mode %q: use one of %s", mode, strings.Join(modes, ", "))
+}
+
+// Funzione per decidere se lo store è disponibile secondo la modalità configurata
+func isAvailable(store Location) bool {
+	predicate, ok := availabilityPredicates[config.AvailabilityMode]
+	if !ok {
+		predicate = availabilityPredicates[defaultAvailabilityMode]
+	}
+	// Sotto la soglia di unità il flag del prodotto non conta, gli altri segnali sì
+	if !hasMinQuantity(store) {
+		store.ProductAvailability = false
+	}
+	return predicate(store)
+}
+
+// Avviso mostrato una sola volta se min_quantity è impostato ma l'endpoint non riporta le quantità
+var quantityUnavailableOnce sync.Once
+
+// Funzione per ottenere le unità disponibili di uno store. Se l'endpoint espone solo il
+// booleano, un prodotto disponibile conta come 1 unità. Il secondo valore indica se la
+// quantità è stata riportata davvero.
+func stockQuantity(store Location) (int, bool) {
+	if store.Quantity != nil {
+		return int(*store.Quantity), true
+	}
+	if store.ProductAvailability {
+		return 1, false
+	}
+	return 0, false
+}
+
+// Funzione per applicare la soglia min_quantity. Vale solo per la disponibilità del prodotto e
+// solo se l'endpoint riporta la quantità: i segnali click & collect e consegna in negozio delle
+// altre modalità non hanno una quantità.
+func hasMinQuantity(store Location) bool {
+	if config.MinQuantity <= 1 || !store.ProductAvailability {
+		return true
+	}
+	quantity, reported := stockQuantity(store)
+	// Senza quantità la soglia non è applicabile: il booleano decide da solo, altrimenti
+	// nessuno store raggiungerebbe mai una soglia superiore a 1
+	if !reported {
+		quantityUnavailableOnce.Do(func() {
+			logger.Warn("min_quantity is set but the endpoint doesn't report stock quantities: the threshold is ignored",
+				logFields{"min_quantity": config.MinQuantity})
+		})
+		return true
+	}
+	return quantity >= config.MinQuantity
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const backupDir = "backups"
+
+// Numero di backup conservati se non configurato diversamente
+const defaultBackupKeep = 10
+
+// File di configurazione e stato inclusi nei backup
+func backupFiles() []string {
+	return []string{
+		storeIDFile,
+		storeLabelsFile,
+		intervalFile,
+		"country_selection.txt",
+		"webhook_url.txt",
+		configFile,
+		headersFile,
+	}
+}
+
+// Funzione per salvare una copia dei file di configurazione prima di una scrittura distruttiva.
+// Crea la cartella backups/<timestamp>/ e mantiene solo gli ultimi N backup.
+func backupConfig() error {
+	if err := snapshotConfig(); err != nil {
+		return err
+	}
+	return pruneBackups()
+}
+
+// Funzione per creare la cartella backups/<timestamp>/ con i file attuali, senza eliminare
+// i backup più vecchi
+func snapshotConfig() error {
+	if readOnlyMode {
+		return errReadOnly
+	}
+	name := time.Now().Format("20060102-150405.000000000")
+	dir := filepath.Join(backupDir, name)
+
+	copied := 0
+	for _, file := range backupFiles() {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+
+		// I backup possono contenere webhook e token: leggibili solo dal proprietario
+		if copied == 0 {
+			if err := os.MkdirAll(dir, 0700); err != nil {
+				return err
+			}
+		}
+		if err := os.WriteFile(filepath.Join(dir, file), content, 0600); err != nil {
+			return err
+		}
+		copied++
+	}
+
+	if copied == 0 {
+		return nil
+	}
+	logger.Debug("Configuration backup created", logFields{"backup": name, "files": copied})
+	return nil
+}
+
+// Funzione per eliminare i backup più vecchi oltre il limite configurato
+func pruneBackups() error {
+	keep := config.BackupKeep
+	if keep <= 0 {
+		keep = defaultBackupKeep
+	}
+
+	backups, err := listBackups()
+	if err != nil {
+		return err
+	}
+	for len(backups) > keep {
+		if err := os.RemoveAll(filepath.Join(backupDir, backups[0])); err != nil {
+			return err
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
+
+// Funzione per elencare i backup disponibili, dal più vecchio al più recente
+func listBackups() ([]string, error) {
+	entries, err := os.ReadDir(backupDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var backups []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			backups = append(backups, entry.Name())
+		}
+	}
+	sort.Strings(backups)
+	return backups, nil
+}
+
+// Funzione per ripristinare i file di configurazione da un backup.
+// Lo stato attuale viene salvato a sua volta prima di essere sovrascritto; i backup più vecchi
+// vengono eliminati solo a ripristino riuscito, altrimenti potrebbe sparire proprio quello
+// da ripristinare.
+func restoreBackup(name string) error {
+	dir := filepath.Join(backupDir, filepath.Base(name))
+	if _, err := os.Stat(dir); err != nil {
+		return fmt.Errorf("backup %q not found", name)
+	}
+
+	if err := snapshotConfig(); err != nil {
+		return fmt.Errorf("failed to back up current configuration: %v", err)
+	}
+
+	for _, file := range backupFiles() {
+		content, err := os.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			if os.IsNotExist(err) {
+				// Il file non esisteva al momento del backup
+				if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+					return err
+				}
+				continue
+			}
+			return err
+		}
+		if err := os.WriteFile(file, content, 0644); err != nil {
+			return err
+		}
+	}
+	return pruneBackups()
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Funzione per calcolare il pin di un certificato: SHA-256 della chiave pubblica (SPKI) in base64,
+// lo stesso formato usato da HPKP e da curl --pinnedpubkey
+func spkiPin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// Funzione per verificare la connessione TLS contro i pin configurati. Il client non valida
+// la catena (InsecureSkipVerify), quindi per gli host con un pin questo è l'unico controllo:
+// una chiave diversa indica un possibile attacco man-in-the-middle.
+// Viene confrontato solo il certificato del server, l'unico di cui l'handshake dimostra il
+// possesso della chiave: gli altri della catena possono essere aggiunti da chiunque.
+// Gli host senza pin mantengono il comportamento precedente.
+func verifyCertPin(cs tls.ConnectionState) error {
+	expected, ok := config.CertPins[strings.ToLower(cs.ServerName)]
+	if !ok {
+		return nil
+	}
+	if len(cs.PeerCertificates) > 0 && spkiPin(cs.PeerCertificates[0]) == expected {
+		return nil
+	}
+	logger.Error("Certificate pin mismatch, possible MITM", logFields{"host": cs.ServerName})
+	return fmt.Errorf("certificate pin mismatch for %s", cs.ServerName)
+}
+
+// Funzione per leggere il pin attuale degli endpoint di tutti i paesi e salvarlo in config.json.
+// Qui la catena viene verificata normalmente, così non si registra il pin di un intermediario.
+func recordCertPins() (map[string]string, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.CertPins == nil {
+		cfg.CertPins = make(map[string]string)
+	}
+
+	recorded := make(map[string]string)
+	for _, country := range supportedCountries {
+		u, err := url.Parse(endpointForCountry(country))
+		if err != nil {
+			return recorded, err
+		}
+		host := u.Hostname()
+
+		dialer := &net.Dialer{Timeout: 10 * time.Second}
+		conn, err := tls.DialWithDialer(dialer, "tcp", net.JoinHostPort(host, "443"), &tls.Config{ServerName: host})
+		if err != nil {
+			return recorded, fmt.Errorf("%s: %v", host, err)
+		}
+		certs := conn.ConnectionState().PeerCertificates
+		conn.Close()
+		if len(certs) == 0 {
+			return recorded, fmt.Errorf("%s: no certificate presented", host)
+		}
+
+		pin := spkiPin(certs[0])
+		cfg.CertPins[host] = pin
+		recorded[host] = pin
+	}
+
+	return recorded, saveConfig(cfg)
+}
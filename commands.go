@@ -0,0 +1,288 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// Sottocomando della riga di comando (es. "snipe list"). I flag globali vanno indicati prima
+// del sottocomando; senza sottocomando si apre il menu interattivo.
+type command struct {
+	Name    string
+	Args    string
+	Summary string
+	// Funzione eseguita dopo il caricamento della configurazione; ritorna il codice di uscita.
+	// nil = il comando prosegue nel menu o nello sniper (menu, run, check).
+	Run func(fs *flag.FlagSet) int
+	// Flag propri del sottocomando
+	Flags func(fs *flag.FlagSet)
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{Name: "menu", Summary: "open the interactive menu (default)"},
+		{Name: "run", Summary: "start the sniper immediately (same as -snipe)"},
+		{Name: "check", Summary: "run a single check cycle and exit (same as -once)"},
+		{Name: "list", Summary: "print the country, interval and monitored StoreIDs", Run: runListCommand},
+		{Name: "add-store", Args: "<STOREID>...", Summary: "add StoreIDs to the monitored list", Run: runAddStoreCommand},
+		{Name: "remove-store", Args: "<STOREID>...", Summary: "remove StoreIDs from the monitored list", Run: runRemoveStoreCommand},
+		{Name: "city", Args: "<name>", Summary: "list the StoreIDs of a city", Run: runCityCommand, Flags: func(fs *flag.FlagSet) {
+			fs.String("country", "", "country to search (default: the selected country)")
+		}},
+		{Name: "preview", Args: "[STOREID[:PRODUCTID]]", Summary: "print the restock notification for a store (or a sample store) without sending it", Run: runPreviewCommand},
+		{Name: "export-map", Args: "<file.gpx|file.kml>", Summary: "export the monitored stores as GPX or KML waypoints for a maps app", Run: runExportMapCommand},
+		{Name: "export-state", Args: "[DIR]", Summary: "write JSON copies of the state files to DIR (default state-export)", Run: runExportStateCommand},
+		{Name: "config", Args: "[validate | export <file> | import <file>]", Summary: "validate, export or import the configuration", Run: runConfigCommand},
+	}
+	flag.Usage = printUsage
+}
+
+// Funzione per stampare l'uso del programma con l'elenco dei sottocomandi e dei flag globali
+func printUsage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: %s [flags] [command] [args]\n\nCommands:\n", os.Args[0])
+	for _, cmd := range commands {
+		fmt.Fprintf(out, "  %-40s %s\n", strings.TrimSpace(cmd.Name+" "+cmd.Args), cmd.Summary)
+	}
+	fmt.Fprintln(out, "\nFlags (before the command):")
+	flag.PrintDefaults()
+}
+
+// Funzione per riconoscere il sottocomando negli argomenti rimasti dopo i flag globali.
+// Ritorna nil se non è indicato nessun sottocomando.
+func parseCommand(args []string) (*command, *flag.FlagSet, error) {
+	if len(args) == 0 {
+		return nil, nil, nil
+	}
+	for i := range commands {
+		cmd := &commands[i]
+		if cmd.Name != args[0] {
+			continue
+		}
+		fs := flag.NewFlagSet(cmd.Name, flag.ContinueOnError)
+		fs.Usage = func() {
+			fmt.Fprintf(fs.Output(), "Usage: %s %s %s\n%s\n", os.Args[0], cmd.Name, cmd.Args, cmd.Summary)
+			fs.PrintDefaults()
+		}
+		if cmd.Flags != nil {
+			cmd.Flags(fs)
+		}
+		if err := fs.Parse(args[1:]); err != nil {
+			return nil, nil, err
+		}
+		return cmd, fs, nil
+	}
+	return nil, nil, fmt.Errorf("unknown command %q", args[0])
+}
+
+func runListCommand(fs *flag.FlagSet) int {
+	country, err := readCountrySelection()
+	if err != nil {
+		country = "not selected"
+	}
+	interval, err := readCheckInterval()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading the check interval: %v\n", err)
+		return exitConfigError
+	}
+	ids, err := readStoreIDs()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading the StoreIDs: %v\n", err)
+		return exitConfigError
+	}
+	labels, _ := readStoreLabels()
+
+	fmt.Printf("Country: %s\n", strings.TrimSpace(country))
+	fmt.Printf("Interval: %v\n", interval)
+	fmt.Printf("StoreIDs (%d):\n", len(ids))
+	for _, id := range ids {
+		if label := labels[id]; label != "" {
+			fmt.Printf("%s\t%s\n", id, label)
+		} else {
+			fmt.Println(id)
+		}
+	}
+	return 0
+}
+
+func runAddStoreCommand(fs *flag.FlagSet) int {
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitConfigError
+	}
+	ids, err := readStoreIDs()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading the StoreIDs: %v\n", err)
+		return exitConfigError
+	}
+
+	code := 0
+	for _, id := range fs.Args() {
+		id = strings.ToUpper(strings.TrimSpace(id))
+		switch {
+		case !validStoreID(id):
+			color.Red("Invalid StoreID %q, skipped.\n", id)
+			code = exitConfigError
+		case containsString(ids, id):
+			fmt.Printf("%s is already monitored.\n", id)
+		default:
+			if err := writeStoreID(id); err != nil {
+				fmt.Fprintf(os.Stderr, "Error saving %s: %v\n", id, err)
+				return exitConfigError
+			}
+			ids = append(ids, id)
+			color.Green("%s added.\n", id)
+		}
+	}
+	return code
+}
+
+func runRemoveStoreCommand(fs *flag.FlagSet) int {
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitConfigError
+	}
+	ids, err := readStoreIDs()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading the StoreIDs: %v\n", err)
+		return exitConfigError
+	}
+
+	remove := make(map[string]bool, fs.NArg())
+	for _, id := range fs.Args() {
+		remove[strings.ToUpper(strings.TrimSpace(id))] = true
+	}
+	kept := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if remove[id] {
+			color.Green("%s removed.\n", id)
+			delete(remove, id)
+			continue
+		}
+		kept = append(kept, id)
+	}
+	for id := range remove {
+		fmt.Printf("%s is not monitored.\n", id)
+	}
+	if len(kept) == len(ids) {
+		return 0
+	}
+	if err := writeStoreIDs(kept); err != nil {
+		fmt.Fprintf(os.Stderr, "Error saving the StoreIDs: %v\n", err)
+		return exitConfigError
+	}
+	return 0
+}
+
+func runCityCommand(fs *flag.FlagSet) int {
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitConfigError
+	}
+	country := fs.Lookup("country").Value.String()
+	if country == "" {
+		selected, err := readCountrySelection()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "No country selected: pass -country or select one from the menu.")
+			return exitConfigError
+		}
+		country = strings.TrimSpace(selected)
+	} else if code, _ := resolveCountry(country); code != "" {
+		country = code
+	} else {
+		fmt.Fprintf(os.Stderr, "Unknown country %q: use one of %s.\n", country, strings.Join(supportedCountries, ", "))
+		return exitConfigError
+	}
+
+	cityName := strings.Join(fs.Args(), " ")
+	color.Magenta("Stores found for %s: ", cityName)
+	if err := getStoreIDsByCity(strings.ToUpper(cityName), endpointForCountry(country)); err != nil {
+		printLookupError(err)
+		return exitNetworkError
+	}
+	return 0
+}
+
+func runConfigCommand(fs *flag.FlagSet) int {
+	// "config" e "config validate" sono gestiti come -validate-config, prima di caricare config.json
+	action := fs.Arg(0)
+	switch {
+	case action == "export" && fs.NArg() == 2:
+		stripped, err := exportSharedConfig(fs.Arg(1))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Export failed: %v\n", err)
+			return exitConfigError
+		}
+		color.Green("Configuration exported to %s\n", fs.Arg(1))
+		if len(stripped) > 0 {
+			fmt.Printf("Secrets replaced with %s: %s\n", secretPlaceholder, strings.Join(stripped, ", "))
+		}
+		return 0
+	case action == "import" && fs.NArg() == 2:
+		if err := importSharedConfig(fs.Arg(1)); err != nil {
+			fmt.Fprintf(os.Stderr, "Import failed: %v\n", err)
+			return exitConfigError
+		}
+		color.Green("Configuration imported. The previous files were saved in backups/.\n")
+		return 0
+	}
+	fs.Usage()
+	return exitConfigError
+}
+
+func runPreviewCommand(fs *flag.FlagSet) int {
+	storeID, productID, _ := strings.Cut(fs.Arg(0), ":")
+	if err := previewNotification(strings.ToUpper(strings.TrimSpace(storeID)), productID); err != nil {
+		fmt.Fprintf(os.Stderr, "Preview failed: %v\n", err)
+		return exitConfigError
+	}
+	return 0
+}
+
+func runExportMapCommand(fs *flag.FlagSet) int {
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitConfigError
+	}
+	exported, skipped, err := exportStoreMap(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Export failed: %v\n", err)
+		return exitConfigError
+	}
+	color.Green("%d stores exported to %s\n", exported, fs.Arg(0))
+	if len(skipped) > 0 {
+		fmt.Printf("Skipped (not found or without coordinates): %s\n", strings.Join(skipped, ", "))
+	}
+	return 0
+}
+
+func runExportStateCommand(fs *flag.FlagSet) int {
+	dir := fs.Arg(0)
+	if dir == "" {
+		dir = "state-export"
+	}
+	written, err := exportStateJSON(dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Export failed: %v\n", err)
+		return exitConfigError
+	}
+	for _, path := range written {
+		color.Green("Exported %s\n", path)
+	}
+	return 0
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
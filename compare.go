@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// Funzione per confrontare gli store monitorati che hanno un prodotto disponibile:
+// tabella compatta ordinata per distanza, con servizi e orari, per scegliere dove andare
+func compareStores(storeIDs []string, endpoint_url string, pid string) error {
+	results, err := checkProductAvailability(storeIDs, endpointForProduct(endpoint_url, pid))
+	if err != nil {
+		return err
+	}
+
+	var available []CheckResult
+	for _, result := range results {
+		if result.Available {
+			available = append(available, result)
+		}
+	}
+	if len(available) == 0 {
+		fmt.Printf("Product %s is not available in any of the %d monitored stores.\n", pid, len(results))
+		return nil
+	}
+	sortByDistance(available)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "#\tSTORE\tNAME\tCITY\tDISTANCE\tSERVICES\tHOURS")
+	for i, result := range available {
+		distance := "-"
+		if result.Distance > 0 {
+			distance = fmt.Sprintf("%.1f km", result.Distance)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n", i+1, result.StoreID, result.Name, result.City, distance, serviceCodes(result.Store), storeHours(result.Store))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("%d of %d monitored stores have product %s.\n", len(available), len(results), pid)
+	return nil
+}
+
+// Sigle compatte dei servizi: C&C = click & collect, DTS = consegna in store
+func serviceCodes(store Location) string {
+	var codes []string
+	if store.EnableClickCollect {
+		codes = append(codes, "C&C")
+	}
+	if store.EnableDeliveryToStore {
+		codes = append(codes, "DTS")
+	}
+	if len(codes) == 0 {
+		return "-"
+	}
+	return strings.Join(codes, ",")
+}
+
+// Orario dello store come riportato dall'endpoint (es. "Open until 20:00")
+func storeHours(store Location) string {
+	if message := strings.TrimSpace(store.WorkingStatus.Message); message != "" {
+		return message
+	}
+	if status := strings.TrimSpace(store.WorkingStatus.Status); status != "" {
+		return status
+	}
+	return "-"
+}
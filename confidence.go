@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Numero di controlli considerati per il punteggio di affidabilità se non configurato
+const defaultConfidenceWindow = 5
+
+// Livelli di affidabilità di un risultato
+const (
+	confidenceHigh   = "high"
+	confidenceMedium = "medium"
+	confidenceLow    = "low"
+)
+
+// Flag dei messaggi Discord che invia la notifica senza suono né push (@silent)
+const discordSuppressNotifications = 1 << 12
+
+// Colore giallo degli embed per gli avvisi a bassa affidabilità
+const embedColorYellow = 0xF1C40F
+
+// Storico delle ultime disponibilità di ogni store/prodotto (chiave di availabilityKey),
+// usato per riconoscere le risposte in cache che alternano disponibile ed esaurito
+var (
+	availabilityHistoryMu sync.Mutex
+	availabilityHistory   = make(map[string][]bool)
+)
+
+func confidenceWindow() int {
+	if config.ConfidenceWindow > 1 {
+		return config.ConfidenceWindow
+	}
+	return defaultConfidenceWindow
+}
+
+// Funzione per registrare l'esito di un controllo e calcolare l'affidabilità dello stato attuale:
+// 1 se gli ultimi controlli sono coerenti, più basso quanti più cambi di stato ci sono stati
+func recordAvailability(key string, available bool) (float64, string) {
+	availabilityHistoryMu.Lock()
+	defer availabilityHistoryMu.Unlock()
+
+	history := append(availabilityHistory[key], available)
+	if window := confidenceWindow(); len(history) > window {
+		history = history[len(history)-window:]
+	}
+	availabilityHistory[key] = history
+
+	// Con un solo controllo non sappiamo ancora se il dato è stabile
+	if len(history) < 2 {
+		return 0.5, confidenceMedium
+	}
+	flips := 0
+	for i := 1; i < len(history); i++ {
+		if history[i] != history[i-1] {
+			flips++
+		}
+	}
+	score := 1 - float64(flips)/float64(len(history)-1)
+	return score, confidenceLevel(score)
+}
+
+func confidenceLevel(score float64) string {
+	switch {
+	case score >= 0.75:
+		return confidenceHigh
+	case score >= 0.5:
+		return confidenceMedium
+	default:
+		return confidenceLow
+	}
+}
+
+// Suffisso delle righe di output con il livello di affidabilità, se noto
+func confidenceSuffix(result CheckResult) string {
+	if result.Confidence == "" {
+		return ""
+	}
+	return fmt.Sprintf(", Confidence: %s", result.Confidence)
+}
+
+// Funzione per inviare un avviso a bassa affidabilità: embed giallo inviato senza notifica push,
+// così l'utente può valutare se vale la pena muoversi subito
+func dispatchLowConfidenceNotification(webhookurl string, message string, result CheckResult) error {
+	title := emojiPrefix("low_confidence") + "Possible restock (low confidence)"
+	description := fmt.Sprintf("Availability for this store changed several times in the last %d checks and may come from a cached response.\n\n%s", confidenceWindow(), message)
+	if runes := []rune(description); len(runes) > 4096 {
+		description = string(runes[:4096])
+	}
+	return dispatch(webhookurl, fmt.Sprintf("**%s**\n%s", title, message), result.fields().with("confidence", result.Confidence), withLatency(result, func() error {
+		return postDiscordPayload(webhookurl, DiscordWebhookPayload{
+			Embeds: []DiscordEmbed{{Title: title, Description: description, Color: embedColorYellow}},
+			Flags:  discordSuppressNotifications,
+		})
+	}))
+}
@@ -0,0 +1,400 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const configFile = "config.json"
+
+// Impostazioni opzionali lette da config.json. I campi assenti mantengono i valori di default.
+type Config struct {
+	// Formato (layout Go) usato per i timestamp in output, log e notifiche
+	TimestampFormat string `json:"timestamp_format"`
+	// Fuso orario IANA (es. "Europe/Rome", "UTC"); vuoto = ora locale
+	Timezone string `json:"timezone"`
+
+	// Richieste di conferma quando uno store diventa disponibile (0 = disattivato)
+	ConfirmAttempts int `json:"confirm_attempts"`
+	// Attesa prima di ogni richiesta di conferma
+	ConfirmDelay Duration `json:"confirm_delay"`
+
+	// Paesi da monitorare insieme a quello selezionato (es. ["IT", "FR"])
+	Countries []string `json:"countries"`
+	// Intervallo di controllo per paese; i paesi assenti usano l'intervallo globale
+	CountryIntervals map[string]Duration `json:"country_intervals"`
+
+	// PID dei prodotti da monitorare; vuoto = il prodotto di default degli endpoint
+	Products []string `json:"products"`
+	// Url di pagine categoria i cui prodotti vengono aggiunti a quelli monitorati
+	Categories []string `json:"categories"`
+	// Ogni quanto aggiornare i prodotti delle categorie (default 6h)
+	CategoryRefresh Duration `json:"category_refresh"`
+	// Prodotti con varianti (taglie, tonalità), controllate e notificate singolarmente
+	Variants []VariantGroup `json:"variants"`
+	// Limite al numero di prodotti monitorati, per evitare troppe richieste (default 20)
+	MaxProducts int `json:"max_products"`
+
+	// Criterio di disponibilità: "strict" (default), "click_collect", "delivery" o "any"
+	AvailabilityMode string `json:"availability_mode"`
+	// Unità minime perché uno store sia disponibile (0 o 1 = nessun limite); richiede che
+	// l'endpoint riporti la quantità, altrimenti la soglia viene ignorata
+	MinQuantity int `json:"min_quantity"`
+
+	// Tipo di ritiro da controllare: "click_collect" (default), "delivery" o "both"
+	Fulfillment string `json:"fulfillment"`
+	// Parametri della richiesta per la consegna in negozio (default clickcollect=false, deliverytostore=true)
+	DeliveryParams map[string]string `json:"delivery_params"`
+
+	// Url dell'endpoint di stock della pagina prodotto, con segnaposto {pid}, {store} e {country}
+	PDPStockEndpoint string `json:"pdp_stock_endpoint"`
+	// Sorgente autorevole per la disponibilità: "locator" (default) o "pdp"
+	AvailabilitySource string `json:"availability_source"`
+
+	// Metodo, header e corpo JSON delle richieste allo store locator e all'endpoint di stock
+	LocatorRequest  RequestSpec `json:"locator_request"`
+	PDPStockRequest RequestSpec `json:"pdp_stock_request"`
+
+	// Intervallo minimo tra due avvisi per lo stesso store/prodotto (0 = ad ogni controllo)
+	NotifyCooldown Duration `json:"notify_cooldown"`
+
+	// Token di un bot Discord nel canale del webhook: abilita il riconoscimento degli avvisi
+	// tramite reazione (default ✅)
+	DiscordBotToken string `json:"discord_bot_token"`
+	AckReaction     string `json:"ack_reaction"`
+
+	// Promemoria "still available" durante il cooldown, se il prodotto resta disponibile (0 = disattivato)
+	StillAvailableInterval Duration `json:"still_available_interval"`
+
+	// Controlli considerati per l'affidabilità di un risultato (default 5)
+	ConfidenceWindow int `json:"confidence_window"`
+
+	// Non notifica i restock negli store chiusi per festività (default false: l'avviso viene annotato)
+	SuppressExceptionalClosures bool `json:"suppress_exceptional_closures"`
+	// Notifica solo gli store aperti in questo momento secondo gli orari (schedule); con orari
+	// mancanti o non interpretabili l'avviso viene inviato comunque
+	OnlyOpenStores bool `json:"only_open_stores"`
+	// Non notifica i restock negli store con un avviso attivo (attention_message), dove il
+	// servizio potrebbe essere sospeso (default false: l'avviso viene aggiunto alla notifica)
+	SuppressAttentionStores bool `json:"suppress_attention_stores"`
+
+	// Dopo il primo avviso per un prodotto, smette di controllarlo per la sessione o per il cooldown
+	FirstAvailableWins     bool     `json:"first_available_wins"`
+	FirstAvailableCooldown Duration `json:"first_available_cooldown"`
+
+	// Notifica anche quando un prodotto disponibile torna esaurito (default false)
+	NotifyOutOfStock bool `json:"notify_out_of_stock"`
+
+	// Webhook per paese o per store; gli altri avvisi usano webhook_url.txt
+	WebhookRoutes WebhookRoutes `json:"webhook_routes"`
+
+	// Notifiche push tramite ntfy, in aggiunta a Discord
+	Ntfy NtfyConfig `json:"ntfy"`
+
+	// Invii falliti consecutivi dopo i quali un canale di notifica viene segnalato (default 5);
+	// con disable_failing_notifiers il canale viene anche disattivato fino alla riattivazione dal menu
+	NotifierFailureThreshold int  `json:"notifier_failure_threshold"`
+	DisableFailingNotifiers  bool `json:"disable_failing_notifiers"`
+
+	// Tempo massimo per l'invio di una notifica, separato dal timeout dello store locator (default 10s)
+	NotifyTimeout Duration `json:"notify_timeout"`
+
+	// Numero massimo di notifiche inviate al secondo, es. 0.5 = una ogni 2 secondi (0 = nessun limite)
+	NotifyRate float64 `json:"notify_rate"`
+
+	// Fascia oraria senza notifiche
+	QuietHours QuietHours `json:"quiet_hours"`
+
+	// File con il template (text/template) delle notifiche; default message_template.txt
+	MessageTemplateFile string `json:"message_template_file"`
+	// Stile delle notifiche: "emoji" (default) o "plain", senza emoji; emoji sostituisce
+	// singolarmente quelle predefinite (es. {"cart": "🧺"})
+	NotificationStyle string            `json:"notification_style"`
+	Emoji             map[string]string `json:"emoji"`
+
+	// Distribuzione dei controlli in lotti nell'arco dell'intervallo, per molti prodotti
+	MonitorQueue MonitorQueue `json:"monitor_queue"`
+
+	// Città di cui monitorare tutti gli store, oltre agli Store ID configurati
+	MonitorCities []string `json:"monitor_cities"`
+	// Ogni quanto riscaricare l'elenco degli store (città, nomi, indirizzi); default 1h
+	StoreRefresh Duration `json:"store_refresh"`
+	// Avvisa quando nelle città monitorate compare un nuovo store o uno store scompare
+	NotifyNewStores bool `json:"notify_new_stores"`
+
+	// Coordinate e raggio di ricerca dello store locator per paese, al posto di quelli degli
+	// endpoint; dal menu vengono salvati solo dopo una richiesta di prova riuscita
+	SearchArea map[string]SearchArea `json:"search_area"`
+	// ID dei servizi (StoreService.ID) che gli store devono offrire, passati nel parametro
+	// storeservices dell'endpoint; vuoto = nessun filtro
+	StoreServices []string `json:"store_services"`
+
+	// Posizione da cui calcolare la distanza degli store, se l'endpoint non la restituisce
+	HomeLocation *Coordinates `json:"home_location"`
+	// Store mostrati nella ricerca per città, i più vicini (default 10, -1 = tutti)
+	CityLookupLimit int `json:"city_lookup_limit"`
+	// Soglie per colorare la distanza degli store nell'output
+	DistanceColors DistanceBuckets `json:"distance_colors"`
+
+	// Data (YYYY-MM-DD) prima della quale gli avvisi di uno store sono soppressi, es. per un viaggio;
+	// lo store viene comunque controllato
+	StoreNotifyAfter map[string]string `json:"store_notify_after"`
+
+	// Store preferiti, evidenziati con una stella negli elenchi, nei risultati e nelle notifiche
+	FavoriteStores []string `json:"favorite_stores"`
+	// Notifica gli store preferiti prima degli altri
+	PrioritizeFavorites bool `json:"prioritize_favorites"`
+
+	// Aggiunge alle notifiche un link Google Maps verso lo store
+	IncludeMapsLink bool `json:"include_maps_link"`
+
+	// Rallentamento dopo un blocco anti-bot: attesa minima e massima e fattore di crescita
+	// (default 1m, 1h, 2); backoff_recovery > 1 riduce l'attesa gradualmente invece di azzerarla
+	BackoffMin      Duration `json:"backoff_min"`
+	BackoffMax      Duration `json:"backoff_max"`
+	BackoffFactor   float64  `json:"backoff_factor"`
+	BackoffRecovery float64  `json:"backoff_recovery"`
+
+	// Pausa tra due richieste consecutive nello stesso ciclo (default 500ms, 0 = nessuna) e
+	// ritardo casuale aggiuntivo fino a request_jitter
+	RequestSpacing Duration `json:"request_spacing"`
+	RequestJitter  Duration `json:"request_jitter"`
+
+	// Errori di rete consecutivi dopo i quali i controlli vengono sospesi (default 3)
+	OfflineThreshold int `json:"offline_threshold"`
+	// Intervallo tra le sonde di connettività quando si è offline (default 30s)
+	OfflineProbeInterval Duration `json:"offline_probe_interval"`
+
+	// Cache degli indirizzi DNS dell'endpoint, con durata configurabile (default 5m)
+	DNSCache    bool     `json:"dns_cache"`
+	DNSCacheTTL Duration `json:"dns_cache_ttl"`
+
+	// Pin SHA-256 (base64) della chiave pubblica del certificato, per host; vedi -record-pins
+	CertPins map[string]string `json:"cert_pins"`
+
+	// Salva i cookie del client in cookies.json e li riusa all'avvio successivo
+	PersistCookies bool `json:"persist_cookies"`
+
+	// Dimensione massima in byte di una risposta (default 10 MB)
+	MaxResponseSize int64 `json:"max_response_size"`
+
+	// Proxy (http, https o socks5) per le richieste allo store locator: si usa il più affidabile
+	// e una richiesta bloccata viene ripetuta con un altro. Gli url possono contenere credenziali:
+	// sono trattati come segreti (portachiavi, backup, configurazione condivisa)
+	Proxies []string `json:"proxies"`
+
+	// Tuning del pool di connessioni del client HTTP condiviso
+	MaxIdleConns        int      `json:"max_idle_conns"`
+	MaxIdleConnsPerHost int      `json:"max_idle_conns_per_host"`
+	IdleConnTimeout     Duration `json:"idle_conn_timeout"`
+
+	// Token richiesto dall'API di controllo (-api-addr); vuoto = generato ad ogni avvio
+	APIToken string `json:"api_token"`
+
+	// Legge e salva webhook e token nel portachiavi del sistema, con i file come ripiego
+	UseKeyring bool `json:"use_keyring"`
+
+	// Numero di backup della configurazione da conservare in backups/
+	BackupKeep int `json:"backup_keep"`
+
+	// Formato dei file di stato: "json" (default) o "gob", più compatto con molti store e prodotti;
+	// i file esistenti vengono convertiti al primo utilizzo
+	StateFormat string `json:"state_format"`
+
+	location *time.Location
+}
+
+var config = defaultConfig()
+
+func defaultConfig() Config {
+	return Config{
+		TimestampFormat:  "2006-01-02 15:04:05",
+		AvailabilityMode: defaultAvailabilityMode,
+		ConfirmDelay:     Duration{3 * time.Second},
+		RequestSpacing:   Duration{defaultRequestSpacing},
+		location:         time.Local,
+	}
+}
+
+// Durata serializzata in JSON come stringa leggibile ("30s", "5m", "1h30m")
+type Duration struct {
+	time.Duration
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("duration must be a string like \"30s\" or \"5m\": %v", err)
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	d.Duration = parsed
+	return nil
+}
+
+// Funzione per leggere la configurazione dal file, se presente
+func loadConfig() (Config, error) {
+	content, err := os.ReadFile(configFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return defaultConfig(), nil
+		}
+		return defaultConfig(), err
+	}
+	return parseConfig(content)
+}
+
+// Funzione per interpretare e validare il contenuto di config.json, anche prima di salvarlo
+func parseConfig(content []byte) (Config, error) {
+	cfg := defaultConfig()
+	if err := json.Unmarshal(content, &cfg); err != nil {
+		return cfg, fmt.Errorf("invalid %s: %v", configFile, err)
+	}
+
+	if cfg.TimestampFormat == "" {
+		cfg.TimestampFormat = defaultConfig().TimestampFormat
+	}
+	if cfg.Timezone != "" {
+		loc, err := time.LoadLocation(cfg.Timezone)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid timezone %q: %v", cfg.Timezone, err)
+		}
+		cfg.location = loc
+	}
+	for _, country := range cfg.Countries {
+		if err := checkCountryCode(country); err != nil {
+			return cfg, fmt.Errorf("countries: %w", err)
+		}
+	}
+	if len(cfg.CountryIntervals) > 0 {
+		intervals := make(map[string]Duration, len(cfg.CountryIntervals))
+		for country, interval := range cfg.CountryIntervals {
+			if err := checkCountryCode(country); err != nil {
+				return cfg, fmt.Errorf("country_intervals: %w", err)
+			}
+			if interval.Duration <= 0 {
+				return cfg, fmt.Errorf("country_intervals: interval for %s must be positive", country)
+			}
+			intervals[strings.ToUpper(strings.TrimSpace(country))] = interval
+		}
+		cfg.CountryIntervals = intervals
+	}
+	applyKeyringSecrets(&cfg)
+	if err := cfg.QuietHours.validate(); err != nil {
+		return cfg, err
+	}
+	if err := cfg.WebhookRoutes.validate(); err != nil {
+		return cfg, err
+	}
+	if err := cfg.DistanceColors.validate(); err != nil {
+		return cfg, err
+	}
+	if err := cfg.MonitorQueue.validate(); err != nil {
+		return cfg, err
+	}
+	if err := cfg.Ntfy.validate(); err != nil {
+		return cfg, err
+	}
+	if err := validateProxies(cfg.Proxies); err != nil {
+		return cfg, err
+	}
+	for _, group := range cfg.Variants {
+		if err := group.validate(); err != nil {
+			return cfg, err
+		}
+	}
+	if err := validateNotifyAfter(cfg.StoreNotifyAfter); err != nil {
+		return cfg, err
+	}
+	if cfg.AvailabilityMode == "" {
+		cfg.AvailabilityMode = defaultAvailabilityMode
+	}
+	if err := validateAvailabilityMode(cfg.AvailabilityMode); err != nil {
+		return cfg, err
+	}
+	if cfg.RequestSpacing.Duration < 0 || cfg.RequestJitter.Duration < 0 {
+		return cfg, fmt.Errorf("request_spacing and request_jitter must not be negative")
+	}
+	if cfg.NotifierFailureThreshold < 0 {
+		return cfg, fmt.Errorf("invalid notifier_failure_threshold %d: must be 0 or more", cfg.NotifierFailureThreshold)
+	}
+	if cfg.MinQuantity < 0 {
+		return cfg, fmt.Errorf("invalid min_quantity %d: must be 0 or more", cfg.MinQuantity)
+	}
+	if err := validateStateFormat(cfg.StateFormat); err != nil {
+		return cfg, err
+	}
+	if err := validateNotificationStyle(cfg.NotificationStyle, cfg.Emoji); err != nil {
+		return cfg, err
+	}
+	if err := validateSearchAreas(cfg.SearchArea); err != nil {
+		return cfg, err
+	}
+	if err := validateStoreServices(cfg.StoreServices); err != nil {
+		return cfg, err
+	}
+	if err := validateFulfillmentMode(cfg.Fulfillment); err != nil {
+		return cfg, err
+	}
+	switch cfg.AvailabilitySource {
+	case "":
+		cfg.AvailabilitySource = sourceLocator
+	case sourceLocator, sourcePDP:
+	default:
+		return cfg, fmt.Errorf("invalid availability_source %q: use %q or %q", cfg.AvailabilitySource, sourceLocator, sourcePDP)
+	}
+	if cfg.AvailabilitySource == sourcePDP && cfg.PDPStockEndpoint == "" {
+		return cfg, fmt.Errorf("availability_source %q requires pdp_stock_endpoint", sourcePDP)
+	}
+	if err := cfg.LocatorRequest.validate("locator_request"); err != nil {
+		return cfg, err
+	}
+	if err := cfg.PDPStockRequest.validate("pdp_stock_request"); err != nil {
+		return cfg, err
+	}
+
+	return cfg, nil
+}
+
+// Funzione per scrivere la configurazione nel file
+func saveConfig(cfg Config) error {
+	if err := backupConfig(); err != nil {
+		return err
+	}
+
+	content, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(configFile, append(content, '\n'), 0644)
+}
+
+// Funzione per scrivere nel file un contenuto già pronto (es. una sola chiave modificata)
+func saveConfigContent(content []byte) error {
+	if err := backupConfig(); err != nil {
+		return err
+	}
+	return os.WriteFile(configFile, content, 0644)
+}
+
+// Fuso orario configurato, ora locale se non specificato
+func configuredLocation() *time.Location {
+	if config.location == nil {
+		return time.Local
+	}
+	return config.location
+}
+
+// Funzione per formattare un orario secondo formato e fuso orario configurati
+func formatTimestamp(t time.Time) string {
+	return t.In(configuredLocation()).Format(config.TimestampFormat)
+}
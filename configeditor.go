@@ -0,0 +1,293 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// Impostazione modificabile dall'editor del menu. Key è la chiave in config.json; le impostazioni
+// salvate nei file storici (paese, intervallo, webhook) hanno File valorizzato e vengono scritte
+// direttamente da Set.
+type editableSetting struct {
+	Key         string
+	File        string
+	Description string
+	Get         func(cfg Config) string
+	Set         func(cfg *Config, value string) error
+}
+
+func (s editableSetting) name() string {
+	if s.File != "" {
+		return s.File
+	}
+	return s.Key
+}
+
+func intSetting(key string, description string, field func(cfg *Config) *int) editableSetting {
+	return editableSetting{
+		Key:         key,
+		Description: description,
+		Get:         func(cfg Config) string { return strconv.Itoa(*field(&cfg)) },
+		Set: func(cfg *Config, value string) error {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return fmt.Errorf("%q is not a whole number of 0 or more", value)
+			}
+			*field(cfg) = n
+			return nil
+		},
+	}
+}
+
+func floatSetting(key string, description string, field func(cfg *Config) *float64) editableSetting {
+	return editableSetting{
+		Key:         key,
+		Description: description,
+		Get:         func(cfg Config) string { return strconv.FormatFloat(*field(&cfg), 'g', -1, 64) },
+		Set: func(cfg *Config, value string) error {
+			f, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return fmt.Errorf("%q is not a number", value)
+			}
+			*field(cfg) = f
+			return nil
+		},
+	}
+}
+
+func boolSetting(key string, description string, field func(cfg *Config) *bool) editableSetting {
+	return editableSetting{
+		Key:         key,
+		Description: description + " (y/n)",
+		Get: func(cfg Config) string {
+			if *field(&cfg) {
+				return "yes"
+			}
+			return "no"
+		},
+		Set: func(cfg *Config, value string) error {
+			switch strings.ToLower(value) {
+			case "y", "yes", "true", "1":
+				*field(cfg) = true
+			case "n", "no", "false", "0":
+				*field(cfg) = false
+			default:
+				return fmt.Errorf("enter y or n")
+			}
+			return nil
+		},
+	}
+}
+
+func durationSetting(key string, description string, field func(cfg *Config) *Duration) editableSetting {
+	return editableSetting{
+		Key:         key,
+		Description: description + " (e.g. 30s, 5m, 0 to disable)",
+		Get:         func(cfg Config) string { return field(&cfg).String() },
+		Set: func(cfg *Config, value string) error {
+			d, err := time.ParseDuration(value)
+			if err != nil || d < 0 {
+				return fmt.Errorf("%q is not a duration like 30s or 5m", value)
+			}
+			field(cfg).Duration = d
+			return nil
+		},
+	}
+}
+
+func stringSetting(key string, description string, field func(cfg *Config) *string) editableSetting {
+	return editableSetting{
+		Key:         key,
+		Description: description + " (\"-\" to clear)",
+		Get:         func(cfg Config) string { return *field(&cfg) },
+		Set: func(cfg *Config, value string) error {
+			if value == "-" {
+				value = ""
+			}
+			*field(cfg) = value
+			return nil
+		},
+	}
+}
+
+// Impostazioni mostrate dall'editor, nell'ordine del menu
+func editableSettings() []editableSetting {
+	return []editableSetting{
+		{
+			File:        "country_selection.txt",
+			Description: "Country to monitor (IT, DE, FR or the country name)",
+			Get: func(Config) string {
+				country, _ := readCountrySelection()
+				return strings.TrimSpace(country)
+			},
+			Set: func(_ *Config, value string) error {
+				code, candidates := resolveCountry(value)
+				if code == "" {
+					if len(candidates) > 0 {
+						return fmt.Errorf("%q is ambiguous: did you mean %s?", value, strings.Join(candidates, " or "))
+					}
+					return fmt.Errorf("unknown country %q: use one of %s", value, strings.Join(supportedCountries, ", "))
+				}
+				return writeCountrySelection(code)
+			},
+		},
+		{
+			File:        intervalFile,
+			Description: "Check interval (e.g. 45s, 10m, 1h30m, or a number of hours)",
+			Get: func(Config) string {
+				interval, _ := readCheckInterval()
+				return interval.String()
+			},
+			Set: func(_ *Config, value string) error {
+				interval, err := parseInterval(value)
+				if err != nil {
+					return err
+				}
+				if interval <= 0 {
+					return fmt.Errorf("the interval must be positive")
+				}
+				return writeCheckInterval(interval)
+			},
+		},
+		{
+			File:        "webhook_url.txt",
+			Description: "Discord webhook URL",
+			Get: func(Config) string {
+				webhookURL, err := readWebhookURL()
+				if err != nil || webhookURL == "" {
+					return ""
+				}
+				return "set (hidden)"
+			},
+			Set: func(_ *Config, value string) error {
+				if err := validateWebhookURL(value); err != nil {
+					return err
+				}
+				return writeWebhookURL(value)
+			},
+		},
+		stringSetting("availability_mode", "What counts as available: strict, click_collect, delivery or any", func(c *Config) *string { return &c.AvailabilityMode }),
+		intSetting("min_quantity", "Minimum units for a store to count as available (0 = no threshold)", func(c *Config) *int { return &c.MinQuantity }),
+		intSetting("confirm_attempts", "Confirmation requests before notifying a restock (0 = off)", func(c *Config) *int { return &c.ConfirmAttempts }),
+		intSetting("max_products", "Maximum number of monitored products (0 = default 20)", func(c *Config) *int { return &c.MaxProducts }),
+		durationSetting("request_spacing", "Pause between requests within a cycle", func(c *Config) *Duration { return &c.RequestSpacing }),
+		durationSetting("request_jitter", "Extra random delay added to each pause", func(c *Config) *Duration { return &c.RequestJitter }),
+		durationSetting("notify_cooldown", "Minimum time between two alerts for the same store and product", func(c *Config) *Duration { return &c.NotifyCooldown }),
+		durationSetting("notify_timeout", "Timeout of a single notification request", func(c *Config) *Duration { return &c.NotifyTimeout }),
+		floatSetting("notify_rate", "Maximum notifications per second (0 = no limit)", func(c *Config) *float64 { return &c.NotifyRate }),
+		intSetting("confidence_window", "Checks used for the confidence score (0 = default 5)", func(c *Config) *int { return &c.ConfidenceWindow }),
+		intSetting("notifier_failure_threshold", "Consecutive failures before a notification channel is flagged (0 = default 5)", func(c *Config) *int { return &c.NotifierFailureThreshold }),
+		boolSetting("disable_failing_notifiers", "Stop sending to a channel once it is flagged", func(c *Config) *bool { return &c.DisableFailingNotifiers }),
+		boolSetting("notify_out_of_stock", "Notify when a product sells out again", func(c *Config) *bool { return &c.NotifyOutOfStock }),
+		stringSetting("notification_style", "Notification style: emoji or plain (text only)", func(c *Config) *string { return &c.NotificationStyle }),
+		boolSetting("include_maps_link", "Add a Google Maps link to notifications", func(c *Config) *bool { return &c.IncludeMapsLink }),
+		boolSetting("first_available_wins", "Stop checking a product after its first alert", func(c *Config) *bool { return &c.FirstAvailableWins }),
+		stringSetting("ntfy.topic", "ntfy topic for push notifications", func(c *Config) *string { return &c.Ntfy.Topic }),
+	}
+}
+
+// Funzione per leggere config.json così com'è, senza i segreti del portachiavi, per poterlo
+// riscrivere senza copiarli nel file
+func readConfigFile() (Config, error) {
+	cfg := defaultConfig()
+	content, err := os.ReadFile(configFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cfg, nil
+		}
+		return cfg, err
+	}
+	if err := json.Unmarshal(content, &cfg); err != nil {
+		return cfg, fmt.Errorf("invalid %s: %v", configFile, err)
+	}
+	return cfg, nil
+}
+
+// Funzione per controllare una modifica prima di salvarla. Il contenuto candidato di config.json
+// deve superare la validazione di loadConfig e non introdurre nuovi problemi di -validate-config,
+// anche su chiavi annidate o tra campi diversi; i problemi già presenti nel file non bloccano
+// la modifica. Restituisce la configurazione da usare subito.
+func validateSetting(content []byte) (Config, error) {
+	existing := make(map[string]bool)
+	if current, err := os.ReadFile(configFile); err == nil {
+		for _, problem := range validateConfigJSON(current) {
+			existing[problem.Message] = true
+		}
+	}
+	for _, problem := range validateConfigJSON(content) {
+		if !existing[problem.Message] {
+			return Config{}, fmt.Errorf("%s", problem.Message)
+		}
+	}
+	return parseConfig(content)
+}
+
+// Editor guidato delle impostazioni (menu): mostra i valori attuali, chiede quale modificare
+// e salva subito ogni modifica valida
+func editSettings() {
+	settings := editableSettings()
+	for {
+		cfg, err := readConfigFile()
+		if err != nil {
+			color.Red("Cannot edit the settings: %v\n", err)
+			return
+		}
+
+		fmt.Println("Current settings:")
+		for i, setting := range settings {
+			value := setting.Get(cfg)
+			if value == "" {
+				value = "(not set)"
+			}
+			fmt.Printf("%2d) %-28s ", i+1, setting.name())
+			color.Cyan("%s", value)
+		}
+		fmt.Println("Enter the number of the setting to change (0 to go back):")
+		choice, err := strconv.Atoi(readLine())
+		if err != nil || choice < 1 || choice > len(settings) {
+			return
+		}
+		setting := settings[choice-1]
+
+		fmt.Println(setting.Description)
+		fmt.Printf("Current value: %s\n", setting.Get(cfg))
+		fmt.Println("New value (leave empty to keep it):")
+		value := readOptionalLine()
+		if value == "" {
+			continue
+		}
+
+		if err := setting.Set(&cfg, value); err != nil {
+			color.Red("Not saved: %v\n", err)
+			continue
+		}
+		if setting.File == "" {
+			// Nel file viene riscritta solo la chiave modificata: le altre restano come le ha
+			// scritte l'utente
+			content, err := patchConfigSetting(cfg, setting.Key)
+			if err != nil {
+				color.Red("Not saved: %v\n", err)
+				continue
+			}
+			updated, err := validateSetting(content)
+			if err != nil {
+				color.Red("Not saved: %v\n", err)
+				continue
+			}
+			if err := saveConfigContent(content); err != nil {
+				color.Red("Not saved: %v\n", err)
+				continue
+			}
+			// La configurazione in uso viene aggiornata, così la modifica vale subito
+			config = updated
+		}
+		color.Green("%s updated.\n", setting.name())
+		fmt.Println()
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Funzione per ricavare da una Config il valore JSON di una chiave di config.json, anche
+// annidata ("ntfy.topic")
+func configValue(cfg Config, key string) (json.RawMessage, error) {
+	content, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	value := json.RawMessage(content)
+	for _, name := range strings.Split(key, ".") {
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(value, &fields); err != nil {
+			return nil, fmt.Errorf("%s: not an object", key)
+		}
+		var ok bool
+		if value, ok = fields[name]; !ok {
+			return nil, fmt.Errorf("unknown setting %s", key)
+		}
+	}
+	return value, nil
+}
+
+// Funzione per applicare al contenuto attuale di config.json il valore di una sola impostazione
+// di cfg, senza toccare il resto del file
+func patchConfigSetting(cfg Config, key string) ([]byte, error) {
+	value, err := configValue(cfg, key)
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(configFile)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return patchJSONKey(content, key, value)
+}
+
+// Funzione per sostituire il valore di una sola chiave (anche annidata) nel contenuto di
+// config.json, lasciando invariati il resto del file, l'ordine delle chiavi e la formattazione.
+// Le chiavi mancanti vengono aggiunte in fondo all'oggetto che le contiene.
+func patchJSONKey(content []byte, key string, value json.RawMessage) ([]byte, error) {
+	if len(bytes.TrimSpace(content)) == 0 {
+		content = []byte("{}\n")
+	}
+	path := strings.Split(key, ".")
+
+	start := bytes.IndexByte(content, '{')
+	if start < 0 {
+		return nil, fmt.Errorf("%s is not a JSON object", configFile)
+	}
+	for i, name := range path {
+		member, err := findJSONMember(content, start, name)
+		if err != nil {
+			return nil, err
+		}
+		if !member.Found {
+			// Le chiavi intermedie mancanti diventano oggetti annidati
+			inserted, err := nestJSONValue(path[i+1:], value)
+			if err != nil {
+				return nil, err
+			}
+			return insertJSONMember(content, start, member, name, inserted)
+		}
+		if i == len(path)-1 {
+			return splice(content, member.ValueStart, member.ValueEnd, value), nil
+		}
+		if content[member.ValueStart] != '{' {
+			// Un valore non oggetto (es. null) viene sostituito dall'oggetto con la sola chiave
+			inserted, err := nestJSONValue(path[i+1:], value)
+			if err != nil {
+				return nil, err
+			}
+			return splice(content, member.ValueStart, member.ValueEnd, inserted), nil
+		}
+		start = member.ValueStart
+	}
+	return content, nil
+}
+
+// Funzione per annidare value sotto le chiavi di path: ["a", "b"] diventa {"a":{"b":value}}
+func nestJSONValue(path []string, value json.RawMessage) (json.RawMessage, error) {
+	for i := len(path) - 1; i >= 0; i-- {
+		wrapped, err := json.Marshal(map[string]json.RawMessage{path[i]: value})
+		if err != nil {
+			return nil, err
+		}
+		value = wrapped
+	}
+	return value, nil
+}
+
+// Posizione di un membro in un oggetto JSON: inizio e fine del valore se trovato, altrimenti
+// la fine dell'ultimo membro (o della "{" se l'oggetto è vuoto) dove inserirlo
+type jsonMember struct {
+	Found      bool
+	ValueStart int
+	ValueEnd   int
+	InsertAt   int
+	Empty      bool
+}
+
+// Funzione per cercare name tra i membri dell'oggetto che inizia all'offset start
+func findJSONMember(content []byte, start int, name string) (jsonMember, error) {
+	dec := json.NewDecoder(bytes.NewReader(content[start:]))
+	if _, err := dec.Token(); err != nil {
+		return jsonMember{}, err
+	}
+	member := jsonMember{InsertAt: start + 1, Empty: true}
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return jsonMember{}, err
+		}
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return jsonMember{}, err
+		}
+		end := start + int(dec.InputOffset())
+		if token == name {
+			return jsonMember{Found: true, ValueStart: end - len(raw), ValueEnd: end}, nil
+		}
+		member.InsertAt, member.Empty = end, false
+	}
+	return member, nil
+}
+
+// Funzione per aggiungere un membro a un oggetto, con il rientro dei membri già presenti
+func insertJSONMember(content []byte, start int, member jsonMember, name string, value json.RawMessage) ([]byte, error) {
+	key, err := json.Marshal(name)
+	if err != nil {
+		return nil, err
+	}
+	indent := lineIndent(content, start) + "  "
+	if !member.Empty {
+		indent = lineIndent(content, member.InsertAt)
+	}
+	text := fmt.Sprintf("\n%s%s: %s", indent, key, value)
+	if !member.Empty {
+		text = "," + text
+	} else if closing := bytes.IndexByte(content[start:], '}'); closing >= 0 && !bytes.Contains(content[start:start+closing], []byte("\n")) {
+		// Oggetto vuoto su una riga ("{}"): la "}" va a capo con il rientro dell'oggetto
+		text += "\n" + lineIndent(content, start)
+	}
+	return splice(content, member.InsertAt, member.InsertAt, []byte(text)), nil
+}
+
+// Rientro (spazi e tabulazioni iniziali) della riga che contiene l'offset
+func lineIndent(content []byte, offset int) string {
+	lineStart := bytes.LastIndexByte(content[:offset], '\n') + 1
+	line := content[lineStart:offset]
+	return string(line[:len(line)-len(bytes.TrimLeft(line, " \t"))])
+}
+
+func splice(content []byte, from int, to int, insert []byte) []byte {
+	out := make([]byte, 0, len(content)-(to-from)+len(insert))
+	out = append(out, content[:from]...)
+	out = append(out, insert...)
+	return append(out, content[to:]...)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+)
+
+// File in cui vengono salvati i cookie tra un'esecuzione e l'altra (se persist_cookies è attivo)
+const cookiesFile = "cookies.json"
+
+// Cookie salvato su file; l'interfaccia CookieJar espone solo nome e valore
+type persistedCookie struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Url per cui i cookie vengono salvati e ripristinati: gli endpoint dei paesi supportati
+func cookieURLs() []*url.URL {
+	var urls []*url.URL
+	for _, country := range supportedCountries {
+		if u, err := url.Parse(endpointForCountry(country)); err == nil {
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
+
+// Funzione per creare il cookie jar del client condiviso, così i cookie di sessione e
+// anti-bot impostati dalla prima risposta vengono riusati nelle richieste successive
+func newCookieJar() http.CookieJar {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		logger.Warn("Failed to create cookie jar", logFields{"error": err})
+		return nil
+	}
+	if !config.PersistCookies {
+		return jar
+	}
+
+	saved, err := readPersistedCookies()
+	if err != nil {
+		logger.Warn("Ignoring saved cookies", logFields{"error": err})
+		return jar
+	}
+	for _, u := range cookieURLs() {
+		var cookies []*http.Cookie
+		for _, c := range saved[u.Host] {
+			cookies = append(cookies, &http.Cookie{Name: c.Name, Value: c.Value, Path: "/"})
+		}
+		if len(cookies) > 0 {
+			jar.SetCookies(u, cookies)
+		}
+	}
+	return jar
+}
+
+func readPersistedCookies() (map[string][]persistedCookie, error) {
+	saved := make(map[string][]persistedCookie)
+	content, err := os.ReadFile(cookiesFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return saved, nil
+		}
+		return saved, err
+	}
+	if err := json.Unmarshal(content, &saved); err != nil {
+		return saved, fmt.Errorf("invalid %s: %v", cookiesFile, err)
+	}
+	return saved, nil
+}
+
+// Funzione per salvare su file i cookie attuali del client condiviso, per host
+func saveCookies() {
+	if !config.PersistCookies || readOnlyMode {
+		return
+	}
+	jar := httpClient().Jar
+	if jar == nil {
+		return
+	}
+
+	saved := make(map[string][]persistedCookie)
+	for _, u := range cookieURLs() {
+		for _, c := range jar.Cookies(u) {
+			saved[u.Host] = append(saved[u.Host], persistedCookie{Name: c.Name, Value: c.Value})
+		}
+	}
+
+	content, err := json.MarshalIndent(saved, "", "  ")
+	if err == nil {
+		err = os.WriteFile(cookiesFile, append(content, '\n'), 0600)
+	}
+	if err != nil {
+		logger.Warn("Failed to save cookies", logFields{"error": err})
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/texttheater/golang-levenshtein/levenshtein"
+)
+
+// Paesi supportati
+var supportedCountries = []string{"IT", "DE", "FR"}
+
+// Funzione per verificare che un codice paese sia tra quelli supportati (maiuscolo, es. "IT")
+func isSupportedCountry(code string) bool {
+	for _, country := range supportedCountries {
+		if code == country {
+			return true
+		}
+	}
+	return false
+}
+
+// Funzione per verificare un codice paese di config.json ("countries", "country_intervals"):
+// maiuscole e spazi non contano, ma solo i codici supportati sono accettati, così un codice
+// sconosciuto non finisce per monitorare l'endpoint di un altro paese
+func checkCountryCode(code string) error {
+	if !isSupportedCountry(strings.ToUpper(strings.TrimSpace(code))) {
+		return fmt.Errorf("invalid country %q: use one of %s", code, strings.Join(supportedCountries, ", "))
+	}
+	return nil
+}
+
+// Nomi alternativi accettati per ogni paese, in minuscolo
+var countryAliases = map[string]string{
+	"it":          "IT",
+	"ita":         "IT",
+	"italy":       "IT",
+	"italia":      "IT",
+	"italie":      "IT",
+	"italien":     "IT",
+	"de":          "DE",
+	"deu":         "DE",
+	"ger":         "DE",
+	"germany":     "DE",
+	"germania":    "DE",
+	"deutschland": "DE",
+	"allemagne":   "DE",
+	"fr":          "FR",
+	"fra":         "FR",
+	"france":      "FR",
+	"francia":     "FR",
+	"frankreich":  "FR",
+}
+
+// Distanza massima di Levenshtein per correggere un errore di battitura
+const maxCountryDistance = 2
+
+// Funzione per ricavare il codice paese da un input libero ("italia", " Germany ", "Frnace").
+// Se l'input è ambiguo restituisce una stringa vuota e i codici candidati.
+func resolveCountry(input string) (string, []string) {
+	normalized := strings.ToLower(strings.Join(strings.Fields(input), " "))
+	if normalized == "" {
+		return "", nil
+	}
+	if code, ok := countryAliases[normalized]; ok {
+		return code, nil
+	}
+
+	// Errori di battitura: consideriamo gli alias più vicini, solo per input abbastanza lunghi
+	// da non confondere codici di due lettere (es. "IE" sarebbe vicino sia a IT che a DE)
+	if len(normalized) < 4 {
+		return "", nil
+	}
+	best := maxCountryDistance + 1
+	candidates := make(map[string]bool)
+	for alias, code := range countryAliases {
+		if len(alias) < 4 {
+			continue
+		}
+		distance := levenshtein.DistanceForStrings([]rune(normalized), []rune(alias), levenshtein.DefaultOptions)
+		if distance < best {
+			best = distance
+			candidates = map[string]bool{code: true}
+		} else if distance == best {
+			candidates[code] = true
+		}
+	}
+	if best > maxCountryDistance {
+		return "", nil
+	}
+
+	codes := make([]string, 0, len(candidates))
+	for code := range candidates {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	if len(codes) == 1 {
+		return codes[0], nil
+	}
+	return "", codes
+}
+
+// Funzione per chiedere il paese all'utente finché l'input non è riconosciuto
+func promptCountry() string {
+	for {
+		input := readLine()
+		code, candidates := resolveCountry(input)
+		if code != "" {
+			return code
+		}
+		if len(candidates) > 0 {
+			fmt.Printf("%q is ambiguous: did you mean %s?\n", input, strings.Join(candidates, " or "))
+		} else {
+			fmt.Printf("Invalid selection. Please select one of %s (or the country name).\n", strings.Join(supportedCountries, ", "))
+		}
+	}
+}
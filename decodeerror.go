@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"unicode/utf8"
+)
+
+// Byte del corpo riportati nel messaggio di errore quando la decodifica del JSON fallisce
+const decodeSnippetSize = 500
+
+// Errore di decodifica del JSON con il contesto della risposta: stato HTTP, content-type,
+// dimensione, inizio del corpo e file in cui è stato salvato il corpo completo
+type decodeError struct {
+	StatusCode  int
+	ContentType string
+	Length      int
+	Snippet     string
+	BodyFile    string
+	// Il corpo è stato interrotto a metà, non è un problema di schema
+	Truncated bool
+	Err       error
+}
+
+// Tipo di errore riportato nei log: "truncated" o "schema"
+func (e *decodeError) kind() string {
+	if e.Truncated {
+		return "truncated"
+	}
+	return "schema"
+}
+
+func (e *decodeError) Error() string {
+	msg := fmt.Sprintf("errore nel decodificare il JSON (%s, HTTP %d, content-type %q, %d bytes): %v; body: %q",
+		e.kind(), e.StatusCode, e.ContentType, e.Length, e.Err, e.Snippet)
+	if e.BodyFile != "" {
+		msg += "; full body saved to " + e.BodyFile
+	}
+	return msg
+}
+
+func (e *decodeError) Unwrap() error {
+	return e.Err
+}
+
+// Funzione per costruire l'errore di decodifica di una risposta e salvarne il corpo completo
+// in debug/, così una pagina HTML o uno schema cambiato si possono esaminare dopo
+func newDecodeError(resp *http.Response, body []byte, err error) error {
+	truncated := truncatedJSON(body, err) || (resp.ContentLength > 0 && int64(len(body)) < resp.ContentLength)
+	return buildDecodeError(resp, body, len(body), truncated, err)
+}
+
+// Come newDecodeError, per un corpo decodificato in streaming: head è la parte iniziale
+// conservata durante la lettura, length il numero totale di byte letti. Il file in debug/
+// contiene solo la parte conservata.
+func newStreamDecodeError(resp *http.Response, head []byte, length int64, err error) error {
+	// Il decoder si ferma alla fine del corpo mentre si aspetta altri token: risposta troncata
+	var syntaxErr *json.SyntaxError
+	truncated := errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		(errors.As(err, &syntaxErr) && length > 0 && syntaxErr.Offset >= length) ||
+		(resp.ContentLength > 0 && length < resp.ContentLength)
+	return buildDecodeError(resp, head, int(length), truncated, err)
+}
+
+func buildDecodeError(resp *http.Response, body []byte, length int, truncated bool, err error) error {
+	decodeErr := &decodeError{
+		StatusCode:  resp.StatusCode,
+		ContentType: resp.Header.Get("Content-Type"),
+		Length:      length,
+		Snippet:     bodySnippet(body),
+		Truncated:   truncated,
+		Err:         err,
+	}
+	if path, saveErr := saveDecodeFailureBody(body); saveErr != nil {
+		logger.Warn("Failed to save the undecodable response body", logFields{"error": saveErr})
+	} else {
+		decodeErr.BodyFile = path
+	}
+	return decodeErr
+}
+
+// Funzione per troncare il corpo ai primi decodeSnippetSize byte senza spezzare un carattere
+func bodySnippet(body []byte) string {
+	if len(body) <= decodeSnippetSize {
+		return string(body)
+	}
+	cut := decodeSnippetSize
+	for cut > 0 && !utf8.RuneStart(body[cut]) {
+		cut--
+	}
+	return string(body[:cut]) + "..."
+}
+
+// Funzione per salvare il corpo di una risposta non decodificabile, a qualsiasi livello di log.
+// Il file viene sovrascritto ad ogni errore, così un endpoint che cambia schema non riempie il disco.
+func saveDecodeFailureBody(body []byte) (string, error) {
+	if readOnlyMode {
+		return "", nil
+	}
+	if err := os.MkdirAll(debugDir, 0755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(debugDir, "decode-error-latest.txt")
+	return path, os.WriteFile(path, body, 0644)
+}
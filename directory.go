@@ -0,0 +1,92 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+// Elenco degli store aggiornato periodicamente, separato dai controlli di disponibilità.
+// Serve a monitorare tutti gli store delle città configurate e a registrare quando nome o
+// indirizzo di uno store cambiano durante esecuzioni lunghe.
+type storeDirectory struct {
+	refreshed  map[string]time.Time
+	stores     map[string]Location
+	cityStores map[string][]string
+	// Store noti per città, per gli avvisi notify_new_stores (caricati al primo aggiornamento)
+	knownStores map[string][]string
+}
+
+func newStoreDirectory() *storeDirectory {
+	return &storeDirectory{
+		refreshed:  make(map[string]time.Time),
+		stores:     make(map[string]Location),
+		cityStores: make(map[string][]string),
+	}
+}
+
+func storeRefreshInterval() time.Duration {
+	if config.StoreRefresh.Duration > 0 {
+		return config.StoreRefresh.Duration
+	}
+	return time.Hour
+}
+
+// Funzione per aggiornare l'elenco degli store di un paese se è trascorso l'intervallo configurato
+func (d *storeDirectory) refreshIfDue(country string, endpoint_url string, webhookurl string) {
+	if time.Since(d.refreshed[country]) < storeRefreshInterval() {
+		return
+	}
+
+	response, err := fetchStoreResponse(endpoint_url)
+	if err != nil {
+		logger.Warn("Store list refresh failed, keeping previous data", errorFields(logFields{"country": country}, err))
+		return
+	}
+	d.refreshed[country] = time.Now()
+
+	cityStores := make(map[string][]string)
+	for _, store := range response.Locations {
+		if old, ok := d.stores[store.ID]; ok && (old.Name != store.Name || old.Address1 != store.Address1) {
+			logger.Info("Store details changed", logFields{"country": country, "store": store.ID, "name": store.Name, "address": store.Address1})
+		}
+		d.stores[store.ID] = store
+
+		city := strings.ToUpper(strings.TrimSpace(store.City))
+		cityStores[city] = append(cityStores[city], store.ID)
+	}
+
+	for _, city := range config.MonitorCities {
+		city = strings.ToUpper(strings.TrimSpace(city))
+		if ids, ok := cityStores[city]; ok {
+			d.cityStores[country+"|"+city] = ids
+		}
+	}
+	d.detectStoreChanges(country, webhookurl)
+
+	logger.Debug("Store list refreshed", logFields{"country": country, "stores": len(response.Locations)})
+}
+
+// Funzione per ottenere gli store da monitorare: quelli configurati più quelli delle città monitorate
+func (d *storeDirectory) monitoredIDs(country string, storeIDs []string) []string {
+	if len(config.MonitorCities) == 0 {
+		return storeIDs
+	}
+
+	seen := make(map[string]bool)
+	var ids []string
+	add := func(id string) {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	for _, id := range storeIDs {
+		add(id)
+	}
+	for _, city := range config.MonitorCities {
+		for _, id := range d.cityStores[country+"|"+strings.ToUpper(strings.TrimSpace(city))] {
+			add(id)
+		}
+	}
+	return ids
+}
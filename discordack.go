@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// API REST di Discord, usata con il token del bot per leggere le reazioni ai messaggi
+const discordAPIBase = "https://discord.com/api/v10"
+
+// Reazione usata come pulsante di riconoscimento se non configurata
+const defaultAckReaction = "✅"
+
+// Dopo questo tempo un avviso non riconosciuto smette di essere controllato
+const discordAckExpiry = 24 * time.Hour
+
+// Messaggio di Discord restituito dal webhook con ?wait=true
+type discordMessage struct {
+	ID        string `json:"id"`
+	ChannelID string `json:"channel_id"`
+}
+
+type discordUser struct {
+	ID  string `json:"id"`
+	Bot bool   `json:"bot"`
+}
+
+// Avviso inviato di cui si attende la reazione di riconoscimento
+type pendingAck struct {
+	Message   discordMessage
+	StoreID   string
+	ProductID string
+	Sent      time.Time
+}
+
+var (
+	pendingAcksMu sync.Mutex
+	pendingAcks   []pendingAck
+)
+
+// Il riconoscimento via Discord richiede il token di un bot presente nel canale del webhook
+func discordAckEnabled() bool {
+	return config.DiscordBotToken != ""
+}
+
+func ackReaction() string {
+	if config.AckReaction != "" {
+		return config.AckReaction
+	}
+	return defaultAckReaction
+}
+
+// Funzione per inviare un avviso di disponibilità. Con il bot configurato, l'ultimo messaggio
+// riceve la reazione di riconoscimento: quando un utente la clicca lo store viene riconosciuto.
+func dispatchRestockNotification(webhookurl string, message string, result CheckResult) error {
+	if !discordAckEnabled() {
+		return dispatch(webhookurl, message, result.fields(), withLatency(result, func() error {
+			return sendDiscordNotification(webhookurl, message)
+		}))
+	}
+
+	return dispatch(webhookurl, message, result.fields(), withLatency(result, func() error {
+		chunks := splitDiscordMessage(message, discordMessageLimit)
+		var last discordMessage
+		for _, chunk := range chunks {
+			sent, err := sendDiscordMessageWait(webhookurl, chunk)
+			if err != nil {
+				return err
+			}
+			last = sent
+		}
+
+		if err := addDiscordReaction(last); err != nil {
+			// L'avviso è comunque stato inviato: il riconoscimento resta possibile con -ack
+			logger.Warn("Failed to add acknowledgement reaction", result.fields().with("error", err))
+			return nil
+		}
+
+		pendingAcksMu.Lock()
+		pendingAcks = append(pendingAcks, pendingAck{Message: last, StoreID: result.StoreID, ProductID: result.ProductID, Sent: time.Now()})
+		pendingAcksMu.Unlock()
+		return nil
+	}))
+}
+
+// Funzione per inviare un messaggio tramite webhook attendendo la risposta con il messaggio creato
+func sendDiscordMessageWait(webhookURL string, message string) (discordMessage, error) {
+	var sent discordMessage
+
+	u, err := url.Parse(webhookURL)
+	if err != nil {
+		return sent, fmt.Errorf("invalid webhook URL: %v", err)
+	}
+	query := u.Query()
+	query.Set("wait", "true")
+	u.RawQuery = query.Encode()
+
+	jsonPayload, err := json.Marshal(DiscordWebhookPayload{Content: message})
+	if err != nil {
+		return sent, fmt.Errorf("failed to marshal JSON payload: %v", err)
+	}
+
+	resp, err := discordRequest("POST", u.String(), jsonPayload, false)
+	if err != nil {
+		return sent, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return sent, fmt.Errorf("received non-200 response status: %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&sent); err != nil {
+		return sent, fmt.Errorf("failed to decode webhook response: %v", err)
+	}
+	return sent, nil
+}
+
+// Funzione per aggiungere (come bot) la reazione di riconoscimento a un messaggio
+func addDiscordReaction(msg discordMessage) error {
+	endpoint := fmt.Sprintf("%s/channels/%s/messages/%s/reactions/%s/@me",
+		discordAPIBase, msg.ChannelID, msg.ID, url.PathEscape(ackReaction()))
+	resp, err := discordRequest("PUT", endpoint, nil, true)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("received non-204 response status: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Funzione per verificare se un utente (non bot) ha aggiunto la reazione di riconoscimento
+func hasUserReaction(msg discordMessage) (bool, error) {
+	endpoint := fmt.Sprintf("%s/channels/%s/messages/%s/reactions/%s",
+		discordAPIBase, msg.ChannelID, msg.ID, url.PathEscape(ackReaction()))
+	resp, err := discordRequest("GET", endpoint, nil, true)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("received non-200 response status: %d", resp.StatusCode)
+	}
+	var users []discordUser
+	if err := json.NewDecoder(resp.Body).Decode(&users); err != nil {
+		return false, fmt.Errorf("failed to decode reactions: %v", err)
+	}
+	for _, user := range users {
+		if !user.Bot {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func discordRequest(method string, endpoint string, body []byte, authorized bool) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(appCtx, method, endpoint, reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if authorized {
+		req.Header.Set("Authorization", "Bot "+config.DiscordBotToken)
+	}
+
+	resp, err := doLimited(notifierClient(), req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	return resp, nil
+}
+
+// Funzione per controllare le reazioni agli avvisi inviati, chiamata ad ogni ciclo.
+// Gli avvisi con la reazione di un utente vengono riconosciuti come con -ack.
+func pollDiscordAcks() {
+	if !discordAckEnabled() {
+		return
+	}
+
+	pendingAcksMu.Lock()
+	pending := pendingAcks
+	pendingAcks = nil
+	pendingAcksMu.Unlock()
+
+	var remaining []pendingAck
+	for _, ack := range pending {
+		if time.Since(ack.Sent) > discordAckExpiry {
+			continue
+		}
+
+		fields := logFields{"store": ack.StoreID, "product": ack.ProductID, "message": ack.Message.ID}
+		reacted, err := hasUserReaction(ack.Message)
+		if err != nil {
+			logger.Warn("Failed to read acknowledgement reactions", fields.with("error", err))
+			remaining = append(remaining, ack)
+			continue
+		}
+		if !reacted {
+			remaining = append(remaining, ack)
+			continue
+		}
+
+		count, err := acknowledgeRestock(ack.StoreID, ack.ProductID)
+		if err != nil {
+			logger.Error("Failed to acknowledge restock from Discord", fields.with("error", err))
+			remaining = append(remaining, ack)
+			continue
+		}
+		logger.Info("Restock acknowledged from Discord", fields.with("entries", count))
+	}
+
+	pendingAcksMu.Lock()
+	pendingAcks = append(remaining, pendingAcks...)
+	pendingAcksMu.Unlock()
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/fatih/color"
+)
+
+// Raggio medio della Terra in chilometri
+const earthRadiusKm = 6371.0
+
+// Coordinate geografiche in gradi decimali
+type Coordinates struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// Soglie in km per colorare la distanza degli store: verde entro Near, giallo fino a Far,
+// rosso oltre (default 5 e 20 km)
+type DistanceBuckets struct {
+	Near float64 `json:"near_km"`
+	Far  float64 `json:"far_km"`
+}
+
+const (
+	defaultNearKm = 5.0
+	defaultFarKm  = 20.0
+)
+
+// Funzione per validare le soglie configurate
+func (b DistanceBuckets) validate() error {
+	if b.Near < 0 || b.Far < 0 {
+		return fmt.Errorf("distance_colors thresholds must not be negative")
+	}
+	if b.Near > 0 && b.Far > 0 && b.Near > b.Far {
+		return fmt.Errorf("distance_colors near_km (%v) must not exceed far_km (%v)", b.Near, b.Far)
+	}
+	return nil
+}
+
+func (b DistanceBuckets) limits() (float64, float64) {
+	near, far := b.Near, b.Far
+	if near <= 0 {
+		near = defaultNearKm
+	}
+	if far <= 0 {
+		far = defaultFarKm
+	}
+	return near, far
+}
+
+// Funzione per formattare la distanza di uno store colorata in base alla vicinanza,
+// da aggiungere in fondo alle righe di output. Vuota se la distanza non è nota.
+func distanceTag(km float64) string {
+	if km <= 0 {
+		return ""
+	}
+	near, far := config.DistanceColors.limits()
+	switch {
+	case km <= near:
+		return " " + color.GreenString("[%.1f km]", km)
+	case km <= far:
+		return " " + color.YellowString("[%.1f km]", km)
+	default:
+		return " " + color.RedString("[%.1f km]", km)
+	}
+}
+
+// Funzione per calcolare la distanza in chilometri tra due punti con la formula dell'emisenoverso
+func haversine(lat1, lon1, lat2, lon2 float64) float64 {
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+
+	dLat := toRad(lat2 - lat1)
+	dLon := toRad(lon2 - lon1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(lat1))*math.Cos(toRad(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
+
+// Funzione per ricavare la distanza di uno store: quella restituita dall'endpoint se presente,
+// altrimenti calcolata dalla posizione configurata. 0 se non è possibile determinarla.
+func storeDistance(store Location) float64 {
+	if store.Distance > 0 {
+		return float64(store.Distance)
+	}
+	home := config.HomeLocation
+	if home == nil || (store.Latitude == 0 && store.Longitude == 0) {
+		return 0
+	}
+	return haversine(home.Latitude, home.Longitude, float64(store.Latitude), float64(store.Longitude))
+}
+
+// Numero di store mostrati di default nella ricerca per città
+const defaultCityLookupLimit = 10
+
+// Numero massimo di store mostrati nella ricerca per città (negativo = tutti)
+func cityLookupLimit() int {
+	if config.CityLookupLimit == 0 {
+		return defaultCityLookupLimit
+	}
+	return config.CityLookupLimit
+}
+
+// Funzione per ordinare gli store dal più vicino al più lontano, con quelli senza distanza in fondo
+func sortStoresByDistance(stores []Location) {
+	sort.SliceStable(stores, func(i, j int) bool {
+		di, dj := storeDistance(stores[i]), storeDistance(stores[j])
+		if di == 0 || dj == 0 {
+			return di != 0 && dj == 0
+		}
+		return di < dj
+	})
+}
+
+// Funzione per ordinare i risultati dal più vicino al più lontano.
+// Gli store senza distanza vanno in fondo, mantenendo l'ordine originale.
+func sortByDistance(results []CheckResult) {
+	sort.SliceStable(results, func(i, j int) bool {
+		di, dj := results[i].Distance, results[j].Distance
+		if di == 0 || dj == 0 {
+			return di != 0 && dj == 0
+		}
+		return di < dj
+	})
+}
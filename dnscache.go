@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+// Cache degli indirizzi risolti per host, usata dal DialContext del client condiviso.
+// Evita una risoluzione DNS ad ogni ciclo; lo svantaggio è che per tutta la durata del TTL
+// si resta legati allo stesso IP, ignorando il bilanciamento del carico basato sul DNS.
+type dnsCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]dnsCacheEntry
+}
+
+type dnsCacheEntry struct {
+	addrs   []string
+	expires time.Time
+}
+
+const defaultDNSCacheTTL = 5 * time.Minute
+
+func newDNSCache(ttl time.Duration) *dnsCache {
+	if ttl <= 0 {
+		ttl = defaultDNSCacheTTL
+	}
+	return &dnsCache{ttl: ttl, entries: make(map[string]dnsCacheEntry)}
+}
+
+// Funzione per risolvere un host usando la cache finché il TTL non scade
+func (c *dnsCache) lookup(ctx context.Context, host string) ([]string, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[host]
+	c.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.addrs, nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.entries[host] = dnsCacheEntry{addrs: addrs, expires: time.Now().Add(c.ttl)}
+	c.mu.Unlock()
+	logger.Debug("DNS resolved and cached", logFields{"host": host, "addrs": addrs, "ttl": c.ttl.String()})
+	return addrs, nil
+}
+
+func (c *dnsCache) invalidate(host string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, host)
+}
+
+// Funzione per creare un DialContext che si connette agli IP in cache; se la connessione
+// fallisce la voce viene invalidata e si riprova con una nuova risoluzione
+func (c *dnsCache) dialContext(dialer *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil || net.ParseIP(host) != nil {
+			return dialer.DialContext(ctx, network, addr)
+		}
+
+		for attempt := 0; attempt < 2; attempt++ {
+			addrs, err := c.lookup(ctx, host)
+			if err != nil {
+				return nil, err
+			}
+
+			var lastErr error
+			for _, ip := range addrs {
+				conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(ip, port))
+				if err == nil {
+					return conn, nil
+				}
+				lastErr = err
+			}
+
+			c.invalidate(host)
+			if attempt == 1 || ctx.Err() != nil {
+				return nil, lastErr
+			}
+		}
+		return nil, ctx.Err()
+	}
+}
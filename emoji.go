@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Stile delle notifiche: "emoji" (default) oppure "plain", solo testo per i client e i log
+// in cui le emoji non vengono mostrate correttamente
+const (
+	notificationStyleEmoji = "emoji"
+	notificationStylePlain = "plain"
+)
+
+// Emoji usate nelle notifiche, per nome; config.Emoji può sostituirle singolarmente
+var defaultEmoji = map[string]string{
+	"title":           "🛍️",
+	"store":           "🏪",
+	"cart":            "🛒",
+	"click_collect":   "🛍️",
+	"delivery":        "📦",
+	"closed":          "🚫",
+	"favorite":        "⭐",
+	"simulated":       "🧪",
+	"notice":          "📢",
+	"still_available": "⏰",
+	"out_of_stock":    "❌",
+	"low_confidence":  "⚠️",
+	"new_store":       "🏬",
+	"removed_store":   "🏚️",
+}
+
+func validateNotificationStyle(style string, overrides map[string]string) error {
+	switch style {
+	case "", notificationStyleEmoji, notificationStylePlain:
+	default:
+		return fmt.Errorf("invalid notification_style %q: use %q or %q", style, notificationStyleEmoji, notificationStylePlain)
+	}
+	for name := range overrides {
+		if _, ok := defaultEmoji[name]; !ok {
+			names := make([]string, 0, len(defaultEmoji))
+			for known := range defaultEmoji {
+				names = append(names, known)
+			}
+			sort.Strings(names)
+			return fmt.Errorf("unknown emoji %q: use one of %s", name, strings.Join(names, ", "))
+		}
+	}
+	return nil
+}
+
+func plainNotifications() bool {
+	return config.NotificationStyle == notificationStylePlain
+}
+
+// Funzione per ottenere l'emoji con il nome indicato ("" nello stile solo testo)
+func emoji(name string) string {
+	if plainNotifications() {
+		return ""
+	}
+	if override, ok := config.Emoji[name]; ok {
+		return override
+	}
+	return defaultEmoji[name]
+}
+
+// Emoji seguita da uno spazio, da anteporre a un testo ("" nello stile solo testo)
+func emojiPrefix(name string) string {
+	if e := emoji(name); e != "" {
+		return e + " "
+	}
+	return ""
+}
+
+// Tutte le emoji per nome, per il template delle notifiche ({{.Emoji.cart}})
+func emojiSet() map[string]string {
+	set := make(map[string]string, len(defaultEmoji))
+	for name := range defaultEmoji {
+		set[name] = emoji(name)
+	}
+	return set
+}
+
+// Tag di ntfy, che l'app mostra come emoji: omessi nello stile solo testo
+func ntfyTags(tags ...string) []string {
+	if plainNotifications() {
+		return nil
+	}
+	return tags
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"regexp"
+	"sync"
+	"time"
+)
+
+// Numero massimo di risposte tenute in cache: oltre questo limite viene scartata quella
+// usata meno di recente
+const maxCachedResponses = 256
+
+// Risposta già decodificata associata all'ETag con cui è stata ricevuta
+type cachedStoreResponse struct {
+	ETag     string
+	Response StoreResponse
+	used     time.Time
+}
+
+// Cache delle risposte dello store locator per url: se l'endpoint supporta gli ETag,
+// la richiesta successiva invia If-None-Match e un 304 riusa i dati senza rielaborarli
+var responseCache = struct {
+	mu      sync.Mutex
+	entries map[string]cachedStoreResponse
+}{entries: make(map[string]cachedStoreResponse)}
+
+// Parametro aggiunto da cacheBustedURL, sempre in fondo all'url
+var cacheBusterPattern = regexp.MustCompile(`[?&]_=[0-9]+$`)
+
+// Funzione per ricavare la chiave della cache: l'url senza il parametro anti-cache, così le
+// richieste di conferma riusano la voce dei controlli normali invece di crearne una nuova
+func responseCacheKey(endpoint_url string) string {
+	return cacheBusterPattern.ReplaceAllString(endpoint_url, "")
+}
+
+func cachedResponse(key string) (cachedStoreResponse, bool) {
+	responseCache.mu.Lock()
+	defer responseCache.mu.Unlock()
+	entry, ok := responseCache.entries[key]
+	if ok {
+		entry.used = time.Now()
+		responseCache.entries[key] = entry
+	}
+	return entry, ok
+}
+
+func storeCachedResponse(key string, etag string, response StoreResponse) {
+	responseCache.mu.Lock()
+	defer responseCache.mu.Unlock()
+	if etag == "" {
+		// Senza ETag non c'è modo di validare la cache
+		delete(responseCache.entries, key)
+		return
+	}
+	if _, ok := responseCache.entries[key]; !ok && len(responseCache.entries) >= maxCachedResponses {
+		evictLeastRecentlyUsed()
+	}
+	responseCache.entries[key] = cachedStoreResponse{ETag: etag, Response: response, used: time.Now()}
+}
+
+// Funzione per scartare la risposta usata meno di recente (da chiamare con il lock preso)
+func evictLeastRecentlyUsed() {
+	var oldestKey string
+	var oldest time.Time
+	for key, entry := range responseCache.entries {
+		if oldestKey == "" || entry.used.Before(oldest) {
+			oldestKey, oldest = key, entry.used
+		}
+	}
+	delete(responseCache.entries, oldestKey)
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"log"
+	"os"
+)
+
+// Codici di uscita delle modalità one-shot (-snipe, -once), pensati per gli script
+const (
+	exitInStock      = 0 // almeno uno store monitorato è disponibile
+	exitNotInStock   = 1 // controlli riusciti, nessuno store disponibile
+	exitNetworkError = 2 // errore di rete o risposta HTTP non valida
+	exitConfigError  = 3 // configurazione non valida o mancante
+	exitFatalError   = 4 // errore interno non recuperabile (logger.Fatal)
+)
+
+// Funzione per terminare per un errore di configurazione, come log.Fatalf ma con exitConfigError
+func fatalConfig(format string, args ...interface{}) {
+	log.Printf(format, args...)
+	os.Exit(exitConfigError)
+}
+
+// Funzione per terminare per un errore non recuperabile (es. un file che non si riesce a
+// scrivere), come log.Fatalf ma con exitFatalError: il codice 1 resta riservato a "nessuno
+// store disponibile"
+func fatalError(format string, args ...interface{}) {
+	log.Printf(format, args...)
+	os.Exit(exitFatalError)
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// Funzione per verificare se uno store è tra i preferiti: quelli configurati in
+// favorite_stores oppure quelli segnati come preferiti dall'endpoint
+func isFavorite(store Location) bool {
+	if store.Favorite {
+		return true
+	}
+	for _, id := range config.FavoriteStores {
+		if strings.EqualFold(strings.TrimSpace(id), store.ID) {
+			return true
+		}
+	}
+	return false
+}
+
+// Prefisso usato per evidenziare gli store preferiti negli elenchi e nei risultati
+func favoriteMark(store Location) string {
+	if isFavorite(store) {
+		return "⭐ "
+	}
+	return ""
+}
+
+// Funzione per portare in testa i risultati degli store preferiti, mantenendo l'ordine
+// (per distanza) all'interno dei due gruppi
+func prioritizeFavorites(results []CheckResult) {
+	sort.SliceStable(results, func(i, j int) bool {
+		return isFavorite(results[i].Store) && !isFavorite(results[j].Store)
+	})
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Modalità "first available wins": dopo il primo avviso per un prodotto, il prodotto esce dai
+// controlli per il resto della sessione (o fino alla fine del cooldown configurato)
+var (
+	wonProductsMu sync.Mutex
+	wonProducts   = make(map[string]time.Time)
+)
+
+// Funzione per verificare se un prodotto è già stato trovato; allo scadere del cooldown
+// il prodotto torna tra quelli controllati
+func productWon(pid string) bool {
+	if !config.FirstAvailableWins {
+		return false
+	}
+	wonProductsMu.Lock()
+	defer wonProductsMu.Unlock()
+
+	wonAt, ok := wonProducts[pid]
+	if !ok {
+		return false
+	}
+	if cooldown := config.FirstAvailableCooldown.Duration; cooldown > 0 && time.Since(wonAt) >= cooldown {
+		delete(wonProducts, pid)
+		logger.Info("Product added back to the check set", logFields{"product": pid})
+		return false
+	}
+	return true
+}
+
+// Funzione per togliere dai controlli il prodotto di un risultato appena notificato
+func markProductWon(result CheckResult) {
+	if !config.FirstAvailableWins || result.Simulated {
+		return
+	}
+	wonProductsMu.Lock()
+	wonProducts[result.ProductID] = time.Now()
+	wonProductsMu.Unlock()
+
+	fields := result.fields()
+	if cooldown := config.FirstAvailableCooldown.Duration; cooldown > 0 {
+		fields = fields.with("resume_in", cooldown.String())
+	}
+	logger.Info("First available store notified, product removed from the check set", fields)
+}
+
+// Funzione per escludere dalla lista i prodotti già trovati
+func activeProducts(products []string) []string {
+	active := products[:0:0]
+	for _, pid := range products {
+		if !productWon(pid) {
+			active = append(active, pid)
+		}
+	}
+	return active
+}
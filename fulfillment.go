@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// Tipi di ritiro controllati: click & collect (la richiesta storica, clickcollect=true)
+// e consegna in negozio, con una seconda richiesta allo store locator
+const (
+	fulfillmentClickCollect = "click_collect"
+	fulfillmentDelivery     = "delivery"
+	fulfillmentBoth         = "both"
+)
+
+// Parametri della richiesta per la consegna in negozio. L'endpoint non è documentato:
+// i valori possono essere sostituiti con "delivery_params" in config.json.
+var defaultDeliveryParams = map[string]string{
+	"clickcollect":    "false",
+	"deliverytostore": "true",
+}
+
+func deliveryParams() map[string]string {
+	if len(config.DeliveryParams) > 0 {
+		return config.DeliveryParams
+	}
+	return defaultDeliveryParams
+}
+
+// Funzione per validare la modalità configurata in "fulfillment"
+func validateFulfillmentMode(mode string) error {
+	switch mode {
+	case "", fulfillmentClickCollect, fulfillmentDelivery, fulfillmentBoth:
+		return nil
+	}
+	return fmt.Errorf("invalid fulfillment %q: use %q, %q or %q", mode, fulfillmentClickCollect, fulfillmentDelivery, fulfillmentBoth)
+}
+
+// Funzione per ottenere i tipi di ritiro da controllare ad ogni ciclo
+func fulfillmentKinds() []string {
+	switch config.Fulfillment {
+	case fulfillmentDelivery:
+		return []string{fulfillmentDelivery}
+	case fulfillmentBoth:
+		return []string{fulfillmentClickCollect, fulfillmentDelivery}
+	}
+	return []string{fulfillmentClickCollect}
+}
+
+// Funzione per costruire l'url della richiesta per un tipo di ritiro
+func fulfillmentURL(endpoint_url string, kind string) string {
+	if kind != fulfillmentDelivery {
+		return endpoint_url
+	}
+	u, err := url.Parse(endpoint_url)
+	if err != nil {
+		return endpoint_url
+	}
+	query := u.Query()
+	for key, value := range deliveryParams() {
+		query.Set(key, value)
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// Funzione per riconoscere il tipo di ritiro di un url costruito con fulfillmentURL
+func fulfillmentOfURL(endpoint_url string) string {
+	u, err := url.Parse(endpoint_url)
+	if err != nil {
+		return fulfillmentClickCollect
+	}
+	query := u.Query()
+	for key, value := range deliveryParams() {
+		if query.Get(key) != value {
+			return fulfillmentClickCollect
+		}
+	}
+	return fulfillmentDelivery
+}
+
+// Etichetta del tipo di ritiro usata nelle notifiche
+func fulfillmentLabel(kind string) string {
+	if kind == fulfillmentDelivery {
+		return emojiPrefix("delivery") + "Delivery to store"
+	}
+	return emojiPrefix("click_collect") + "Click & Collect"
+}
+
+// Funzione per costruire gli url da controllare in un ciclo: uno per prodotto e tipo di ritiro
+func checkURLs(endpoint_url string, products []string) []string {
+	var urls []string
+	for _, pid := range products {
+		for _, kind := range fulfillmentKinds() {
+			urls = append(urls, fulfillmentURL(endpointForProduct(endpoint_url, pid), kind))
+		}
+	}
+	return urls
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// File opzionale con gli header aggiuntivi delle richieste allo store locator
+const headersFile = "headers.json"
+
+// Lingua del browser per dominio, usata come Accept-Language di default
+var acceptLanguages = map[string]string{
+	"IT": "it-IT,it;q=0.9,en;q=0.8",
+	"DE": "de-DE,de;q=0.9,en;q=0.8",
+	"FR": "fr-FR,fr;q=0.9,en;q=0.8",
+}
+
+// Funzione per leggere gli header configurati in headers.json ({"Header": "valore"})
+func loadRequestHeaders() (map[string]string, error) {
+	headers := make(map[string]string)
+	content, err := os.ReadFile(headersFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return headers, nil
+		}
+		return headers, err
+	}
+	if err := json.Unmarshal(content, &headers); err != nil {
+		return make(map[string]string), fmt.Errorf("invalid %s: %v", headersFile, err)
+	}
+	return headers, nil
+}
+
+// Transport che aggiunge a ogni richiesta header simili a quelli di un browser e poi quelli
+// di headers.json, che hanno la precedenza anche sugli header impostati dal chiamante
+type headerTransport struct {
+	base    http.RoundTripper
+	headers map[string]string
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Un RoundTripper non deve modificare la richiesta originale
+	req = req.Clone(req.Context())
+
+	setDefault := func(key, value string) {
+		if req.Header.Get(key) == "" {
+			req.Header.Set(key, value)
+		}
+	}
+	setDefault("Accept", "application/json, text/plain, */*")
+	host := req.URL.Hostname()
+	if i := strings.LastIndex(host, "."); i >= 0 {
+		if language, ok := acceptLanguages[strings.ToUpper(host[i+1:])]; ok {
+			setDefault("Accept-Language", language)
+		}
+	}
+	setDefault("Referer", fmt.Sprintf("%s://%s/", req.URL.Scheme, req.URL.Host))
+
+	for key, value := range t.headers {
+		req.Header.Set(key, value)
+	}
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+const notifierHealthFile = "notifier_health.json"
+
+// Invii falliti consecutivi dopo i quali un canale viene segnalato (default 5)
+const defaultNotifierFailureThreshold = 5
+
+// Stato di salute di un canale di notifica (webhook Discord o topic ntfy), salvato su file:
+// un canale disattivato resta tale anche dopo un riavvio, finché non viene riattivato dal menu
+type channelHealth struct {
+	Successes           int       `json:"successes"`
+	Failures            int       `json:"failures"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	LastError           string    `json:"last_error,omitempty"`
+	LastSuccess         time.Time `json:"last_success,omitempty"`
+	LastFailure         time.Time `json:"last_failure,omitempty"`
+	Disabled            bool      `json:"disabled"`
+}
+
+var (
+	notifierHealthMu sync.Mutex
+	notifierHealth   = make(map[string]*channelHealth)
+)
+
+func notifierFailureThreshold() int {
+	if config.NotifierFailureThreshold > 0 {
+		return config.NotifierFailureThreshold
+	}
+	return defaultNotifierFailureThreshold
+}
+
+func (h *channelHealth) failing() bool {
+	return h.ConsecutiveFailures >= notifierFailureThreshold()
+}
+
+// Nome del canale di un webhook Discord: le rotte sono riconoscibili senza mostrare l'url
+func discordChannel(webhookurl string) string {
+	for storeID, routeURL := range config.WebhookRoutes.Stores {
+		if routeURL == webhookurl {
+			return "discord:store:" + storeID
+		}
+	}
+	for country, routeURL := range config.WebhookRoutes.Countries {
+		if routeURL == webhookurl {
+			return "discord:country:" + strings.ToUpper(country)
+		}
+	}
+	return "discord"
+}
+
+const ntfyChannel = "ntfy"
+
+// Funzione per leggere lo stato dei canali dal file all'avvio
+func loadNotifierHealth() {
+	loaded := make(map[string]*channelHealth)
+	if found, err := readStateFile(notifierHealthFile, &loaded); err != nil || !found {
+		if err != nil {
+			logger.Warn("Failed to read notifier health", logFields{"error": err})
+		}
+		return
+	}
+	notifierHealthMu.Lock()
+	notifierHealth = loaded
+	notifierHealthMu.Unlock()
+}
+
+// Funzione per salvare lo stato dei canali; da chiamare con notifierHealthMu acquisito
+func saveNotifierHealthLocked() {
+	if readOnlyMode || notifyStateReadOnly {
+		return
+	}
+	if err := writeStateFile(notifierHealthFile, notifierHealth); err != nil {
+		logger.Warn("Failed to save notifier health", logFields{"error": err})
+	}
+}
+
+func channelHealthLocked(channel string) *channelHealth {
+	health, ok := notifierHealth[channel]
+	if !ok {
+		health = &channelHealth{}
+		notifierHealth[channel] = health
+	}
+	return health
+}
+
+// Funzione per verificare se un canale è stato disattivato dopo troppi errori
+func channelDisabled(channel string) bool {
+	notifierHealthMu.Lock()
+	defer notifierHealthMu.Unlock()
+	health, ok := notifierHealth[channel]
+	return ok && health.Disabled
+}
+
+// Funzione per registrare l'esito di un invio. Al raggiungimento della soglia di errori
+// consecutivi viene mostrato un avviso e, con disable_failing_notifiers, il canale viene disattivato.
+func recordChannelResult(channel string, err error) {
+	notifierHealthMu.Lock()
+	defer notifierHealthMu.Unlock()
+
+	health := channelHealthLocked(channel)
+	now := time.Now()
+	if err == nil {
+		if health.failing() {
+			logger.Info("Notification channel recovered", logFields{"channel": channel, "failures": health.ConsecutiveFailures})
+		}
+		health.Successes++
+		health.ConsecutiveFailures = 0
+		health.LastSuccess = now
+		saveNotifierHealthLocked()
+		return
+	}
+
+	health.Failures++
+	health.ConsecutiveFailures++
+	health.LastError = err.Error()
+	health.LastFailure = now
+	if health.ConsecutiveFailures == notifierFailureThreshold() {
+		fields := logFields{"channel": channel, "failures": health.ConsecutiveFailures, "error": err}
+		if config.DisableFailingNotifiers {
+			health.Disabled = true
+			logger.Error("Notification channel disabled after repeated failures", fields)
+		} else {
+			logger.Error("Notification channel is failing repeatedly", fields)
+		}
+	}
+	saveNotifierHealthLocked()
+}
+
+// Funzione per elencare i canali in errore, in ordine alfabetico
+func failingChannels() []string {
+	notifierHealthMu.Lock()
+	defer notifierHealthMu.Unlock()
+	var failing []string
+	for channel, health := range notifierHealth {
+		if health.Disabled || health.failing() {
+			failing = append(failing, channel)
+		}
+	}
+	sort.Strings(failing)
+	return failing
+}
+
+// Funzione per evidenziare nel riepilogo del ciclo i canali che non ricevono più le notifiche
+func printNotifierWarnings() {
+	for _, channel := range failingChannels() {
+		if channelDisabled(channel) {
+			color.Red("⚠️  Notification channel %s is disabled after repeated failures: re-enable it from the menu.\n", channel)
+		} else {
+			color.Red("⚠️  Notification channel %s is failing: notifications are being lost.\n", channel)
+		}
+	}
+}
+
+// Funzione per mostrare lo stato dei canali e riattivarne uno disattivato (menu)
+func showNotifierHealth() {
+	loadNotifierHealth()
+
+	notifierHealthMu.Lock()
+	channels := make([]string, 0, len(notifierHealth))
+	for channel := range notifierHealth {
+		channels = append(channels, channel)
+	}
+	sort.Strings(channels)
+	var disabled []string
+	for _, channel := range channels {
+		health := notifierHealth[channel]
+		line := fmt.Sprintf("%s: %d sent, %d failed", channel, health.Successes, health.Failures)
+		if !health.LastSuccess.IsZero() {
+			line += ", last success " + formatTimestamp(health.LastSuccess)
+		}
+		switch {
+		case health.Disabled:
+			disabled = append(disabled, channel)
+			color.Red("%d) %s - DISABLED (%s)\n", len(disabled), line, health.LastError)
+		case health.failing():
+			color.Yellow("⚠️  %s - %d consecutive failures (%s)\n", line, health.ConsecutiveFailures, health.LastError)
+		default:
+			color.Green("✅ %s\n", line)
+		}
+	}
+	notifierHealthMu.Unlock()
+
+	if len(channels) == 0 {
+		fmt.Println("No notifications sent yet.")
+		return
+	}
+	if len(disabled) == 0 {
+		return
+	}
+
+	fmt.Println("Enter the number of the channel to re-enable (0 to cancel):")
+	var choice int
+	fmt.Sscan(readLine(), &choice)
+	if choice < 1 || choice > len(disabled) {
+		return
+	}
+	notifierHealthMu.Lock()
+	health := notifierHealth[disabled[choice-1]]
+	health.Disabled = false
+	health.ConsecutiveFailures = 0
+	saveNotifierHealthLocked()
+	notifierHealthMu.Unlock()
+	color.Green("%s re-enabled.\n", disabled[choice-1])
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+var htmlTagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// Funzione per ripulire i testi dello store locator, che possono contenere tag HTML
+func plainText(text string) string {
+	text = htmlTagPattern.ReplaceAllString(text, " ")
+	return strings.Join(strings.Fields(html.UnescapeString(text)), " ")
+}
+
+// Funzione per verificare se lo store ha orari eccezionali (festività). Il campo "exceptional"
+// non è documentato: lo consideriamo attivo se presente e diverso da vuoto, "false" o "0".
+func hasExceptionalSchedule(store Location) bool {
+	if store.Exceptional != nil {
+		switch strings.ToLower(strings.TrimSpace(*store.Exceptional)) {
+		case "", "false", "0", "null":
+		default:
+			return true
+		}
+	}
+	return strings.TrimSpace(store.ExceptionalClosingText) != "" || strings.TrimSpace(store.ExceptionalOpeningText) != ""
+}
+
+// Funzione per ricavare l'eventuale chiusura eccezionale dello store e il relativo testo
+func exceptionalClosure(store Location) (string, bool) {
+	if !hasExceptionalSchedule(store) {
+		return "", false
+	}
+	text := plainText(store.ExceptionalClosingText)
+	return text, text != ""
+}
+
+// Funzione per descrivere gli orari eccezionali dello store, vuota se non ce ne sono
+func exceptionalNote(store Location) string {
+	if text, closed := exceptionalClosure(store); closed {
+		return "Store closed today — " + text
+	}
+	if hasExceptionalSchedule(store) {
+		if text := plainText(store.ExceptionalOpeningText); text != "" {
+			return "Exceptional opening hours — " + text
+		}
+	}
+	return ""
+}
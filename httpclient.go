@@ -0,0 +1,80 @@
+package main
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Valori di default del pool di connessioni, pensati per il polling di un solo host:
+// MaxIdleConnsPerHost è quello che conta davvero, perché il default di net/http (2)
+// costringe a riaprire connessioni TLS quando più richieste verso lo stesso host si sovrappongono.
+const (
+	defaultMaxIdleConns        = 100
+	defaultMaxIdleConnsPerHost = 10
+	defaultIdleConnTimeout     = 90 * time.Second
+)
+
+var (
+	sharedClient     *http.Client
+	sharedClientOnce sync.Once
+)
+
+// Funzione per ottenere il client HTTP condiviso da tutte le richieste allo store locator.
+// Usare un solo Transport permette di riutilizzare le connessioni tra un ciclo e l'altro.
+func httpClient() *http.Client {
+	sharedClientOnce.Do(func() {
+		sharedClient = newHTTPClient()
+	})
+	return sharedClient
+}
+
+func newHTTPClient() *http.Client {
+	maxIdleConns := config.MaxIdleConns
+	if maxIdleConns <= 0 {
+		maxIdleConns = defaultMaxIdleConns
+	}
+	maxIdleConnsPerHost := config.MaxIdleConnsPerHost
+	if maxIdleConnsPerHost <= 0 {
+		maxIdleConnsPerHost = defaultMaxIdleConnsPerHost
+	}
+	idleConnTimeout := config.IdleConnTimeout.Duration
+	if idleConnTimeout <= 0 {
+		idleConnTimeout = defaultIdleConnTimeout
+	}
+
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second, // Timeout per la connessione
+	}
+	dialContext := dialer.DialContext
+	if config.DNSCache {
+		dialContext = newDNSCache(config.DNSCacheTTL.Duration).dialContext(dialer)
+	}
+
+	// Creazione di un client HTTP personalizzato con timeout
+	proxies = newProxyPool(config.Proxies)
+	customTransport := &http.Transport{
+		Proxy:                 proxyFromContext,
+		TLSClientConfig:       &tls.Config{InsecureSkipVerify: true, VerifyConnection: verifyCertPin},
+		DialContext:           dialContext,
+		ForceAttemptHTTP2:     false,
+		MaxIdleConns:          maxIdleConns,
+		MaxIdleConnsPerHost:   maxIdleConnsPerHost,
+		IdleConnTimeout:       idleConnTimeout,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+
+	headers, err := loadRequestHeaders()
+	if err != nil {
+		logger.Warn("Ignoring custom request headers", logFields{"error": err})
+	}
+
+	return &http.Client{
+		Transport: &headerTransport{base: customTransport, headers: headers},
+		Jar:       newCookieJar(),
+		Timeout:   15 * time.Second, // Timeout totale per la richiesta
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"sync"
+)
+
+// Limite globale alle operazioni HTTP in uscita contemporanee (store locator e notifiche),
+// implementato come semaforo su un canale bufferizzato.
+type inflightLimiter struct {
+	slots chan struct{}
+}
+
+var outboundLimiter = newInflightLimiter(4)
+
+func newInflightLimiter(max int) *inflightLimiter {
+	if max < 1 {
+		max = 1
+	}
+	return &inflightLimiter{slots: make(chan struct{}, max)}
+}
+
+// Funzione per eseguire una richiesta rispettando il limite globale.
+// Lo slot resta occupato finché il corpo della risposta non viene chiuso; se il contesto
+// della richiesta viene annullato durante l'attesa, la richiesta non parte.
+func doLimited(client *http.Client, req *http.Request) (*http.Response, error) {
+	select {
+	case outboundLimiter.slots <- struct{}{}:
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		<-outboundLimiter.slots
+		return nil, err
+	}
+
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: func() { <-outboundLimiter.slots }}
+	return resp, nil
+}
+
+// Corpo della risposta che libera lo slot del semaforo alla chiusura (una sola volta)
+type releasingBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestCheckIntervalFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		write   *time.Duration // scritto con writeCheckInterval
+		content *string        // scritto direttamente nel file
+		want    time.Duration
+		wantErr bool
+	}{
+		{name: "round trip 2h", write: durationPtr(2 * time.Hour), want: 2 * time.Hour},
+		{name: "missing file", want: 0},
+		{name: "malformed file", content: stringPtr("two hours"), wantErr: true},
+		{name: "duration 90m", content: stringPtr("90m"), want: 90 * time.Minute},
+		{name: "legacy whole hours", content: stringPtr("3\n"), want: 3 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chdirTemp(t)
+			t.Setenv(envInterval, "")
+
+			if tt.write != nil {
+				if err := writeCheckInterval(*tt.write); err != nil {
+					t.Fatalf("writeCheckInterval: %v", err)
+				}
+			}
+			if tt.content != nil {
+				if err := os.WriteFile(intervalFile, []byte(*tt.content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := readCheckInterval()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("readCheckInterval() = %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readCheckInterval: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("readCheckInterval() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func durationPtr(d time.Duration) *time.Duration { return &d }
+
+func stringPtr(s string) *string { return &s }
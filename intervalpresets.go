@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// Intervalli proposti nel menu, oltre a quello personalizzato
+var intervalPresets = []time.Duration{
+	30 * time.Second,
+	time.Minute,
+	5 * time.Minute,
+	15 * time.Minute,
+	time.Hour,
+}
+
+// Funzione per scegliere l'intervallo di controllo da un elenco di valori predefiniti,
+// evidenziando quello attuale. "Custom" accetta una durata ("90m", "2h") o un numero di ore.
+// Se lo standard input non è leggibile (es. chiuso) restituisce l'intervallo attuale.
+func promptInterval(current time.Duration) time.Duration {
+	fmt.Println("Select the check interval:")
+	isPreset := false
+	for i, preset := range intervalPresets {
+		if preset == current {
+			isPreset = true
+			color.Green("%d) %v  <- current\n", i+1, preset)
+			continue
+		}
+		fmt.Printf("%d) %v\n", i+1, preset)
+	}
+	custom := len(intervalPresets) + 1
+	if !isPreset && current > 0 {
+		color.Green("%d) Custom  <- current: %v\n", custom, current)
+	} else {
+		fmt.Printf("%d) Custom\n", custom)
+	}
+
+	for {
+		line, err := readLineErr()
+		if err != nil {
+			return current
+		}
+		choice, err := strconv.Atoi(line)
+		switch {
+		case err == nil && choice >= 1 && choice <= len(intervalPresets):
+			return intervalPresets[choice-1]
+		case err == nil && choice == custom:
+			return promptCustomInterval(current)
+		}
+		color.Red("Invalid option, enter a number between 1 and %d:\n", custom)
+	}
+}
+
+// Funzione per chiedere un intervallo personalizzato finché non è una durata valida e positiva
+func promptCustomInterval(current time.Duration) time.Duration {
+	fmt.Println("Enter the interval (e.g. 45s, 10m, 1h30m, or a number of hours):")
+	for {
+		line, err := readLineErr()
+		if err != nil {
+			return current
+		}
+		interval, err := parseInterval(line)
+		if err == nil && interval <= 0 {
+			err = fmt.Errorf("the interval must be positive")
+		}
+		if err == nil {
+			return interval
+		}
+		color.Red("%v, try again:\n", err)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// Dimensione massima di default di una risposta (10 MB)
+const defaultMaxResponseSize = 10 << 20
+
+// Oltre questa dimensione il tempo di decodifica viene registrato a livello info
+const largeResponseSize = 1 << 20
+
+// Errore restituito quando la risposta supera la dimensione massima configurata
+var errResponseTooLarge = fmt.Errorf("response exceeds the maximum allowed size")
+
+func maxResponseSize() int64 {
+	if config.MaxResponseSize > 0 {
+		return config.MaxResponseSize
+	}
+	return defaultMaxResponseSize
+}
+
+// Funzione per leggere il corpo della risposta senza superare la dimensione massima
+func readLimitedBody(body io.Reader, fields logFields) ([]byte, error) {
+	limit := maxResponseSize()
+	content, err := io.ReadAll(io.LimitReader(body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(content)) > limit {
+		logger.Warn("Response size limit hit, discarding response", fields.with("max_response_size", limit))
+		return nil, errResponseTooLarge
+	}
+	return content, nil
+}
+
+// Funzione per registrare il tempo di decodifica del JSON, più visibile per le risposte grandi
+func logParseTime(size int, started time.Time, fields logFields) {
+	parseFields := fields.with("bytes", size, "parse_time", time.Since(started).String())
+	if size >= largeResponseSize {
+		logger.Info("Large response parsed", parseFields)
+		return
+	}
+	logger.Debug("Response parsed", parseFields)
+}
+
+// Reader che conta i byte letti e restituisce errResponseTooLarge oltre la dimensione massima,
+// usato dalla decodifica in streaming dove il corpo non viene letto tutto in memoria
+type limitedReader struct {
+	r      io.Reader
+	limit  int64
+	n      int64
+	fields logFields
+}
+
+func newLimitedReader(r io.Reader, fields logFields) *limitedReader {
+	return &limitedReader{r: r, limit: maxResponseSize(), fields: fields}
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.n += int64(n)
+	if l.n > l.limit {
+		logger.Warn("Response size limit hit, discarding response", l.fields.with("max_response_size", l.limit))
+		return n, errResponseTooLarge
+	}
+	return n, err
+}
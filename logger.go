@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Livelli di log supportati dal logger strutturato
+type logLevel int
+
+const (
+	levelDebug logLevel = iota
+	levelInfo
+	levelWarn
+	levelError
+)
+
+func (l logLevel) String() string {
+	switch l {
+	case levelDebug:
+		return "debug"
+	case levelInfo:
+		return "info"
+	case levelWarn:
+		return "warn"
+	default:
+		return "error"
+	}
+}
+
+// Campi contestuali allegati ad ogni riga di log (country, store, product, http_status...)
+type logFields map[string]interface{}
+
+// Logger a livelli che scrive in formato testo oppure JSON (una riga per evento).
+// L'output colorato del menu resta su stdout, il log strutturato va su stderr.
+type leveledLogger struct {
+	mu       sync.Mutex
+	out      io.Writer
+	json     bool
+	minLevel logLevel
+}
+
+var logger = newLogger(stderrOutput, "text", levelInfo)
+
+func newLogger(out io.Writer, format string, minLevel logLevel) *leveledLogger {
+	return &leveledLogger{
+		out:      out,
+		json:     format == "json",
+		minLevel: minLevel,
+	}
+}
+
+// Funzione per convertire il valore del flag -log-level nel livello corrispondente
+func parseLogLevel(value string) (logLevel, error) {
+	switch strings.ToLower(value) {
+	case "debug":
+		return levelDebug, nil
+	case "info":
+		return levelInfo, nil
+	case "warn", "warning":
+		return levelWarn, nil
+	case "error":
+		return levelError, nil
+	}
+	return levelInfo, fmt.Errorf("unknown log level %q", value)
+}
+
+func (l *leveledLogger) log(level logLevel, msg string, fields logFields) {
+	if level < l.minLevel {
+		return
+	}
+
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.json {
+		entry := make(map[string]interface{}, len(fields)+3)
+		for k, v := range fields {
+			if err, ok := v.(error); ok {
+				v = err.Error()
+			}
+			entry[k] = v
+		}
+		entry["level"] = level.String()
+		// Il JSON usa sempre RFC3339 (leggibile dalle pipeline), nel fuso orario configurato
+		entry["timestamp"] = now.In(configuredLocation()).Format(time.RFC3339)
+		entry["message"] = msg
+
+		line, err := json.Marshal(entry)
+		if err != nil {
+			fmt.Fprintf(l.out, "{\"level\":\"error\",\"message\":\"failed to marshal log entry: %v\"}\n", err)
+			return
+		}
+		l.out.Write(append(line, '\n'))
+		return
+	}
+
+	// Formato testo: chiavi ordinate per avere un output stabile
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %-5s %s", formatTimestamp(now), strings.ToUpper(level.String()), msg)
+	for _, k := range keys {
+		fmt.Fprintf(&b, " %s=%v", k, fields[k])
+	}
+	b.WriteByte('\n')
+	io.WriteString(l.out, b.String())
+}
+
+func (l *leveledLogger) Debug(msg string, fields logFields) { l.log(levelDebug, msg, fields) }
+func (l *leveledLogger) Info(msg string, fields logFields)  { l.log(levelInfo, msg, fields) }
+func (l *leveledLogger) Warn(msg string, fields logFields)  { l.log(levelWarn, msg, fields) }
+func (l *leveledLogger) Error(msg string, fields logFields) { l.log(levelError, msg, fields) }
+
+// Fatal scrive l'evento a livello error e termina il programma con exitFatalError, distinto
+// dai codici delle modalità one-shot (1 = nessuno store disponibile)
+func (l *leveledLogger) Fatal(msg string, fields logFields) {
+	l.log(levelError, msg, fields)
+	os.Exit(exitFatalError)
+}
+
+// Funzione per ricavare country e product dall'url dell'endpoint, usati come contesto nei log
+func endpointFields(endpoint_url string) logFields {
+	fields := logFields{}
+	u, err := url.Parse(endpoint_url)
+	if err != nil {
+		return fields
+	}
+	if i := strings.LastIndex(u.Hostname(), "."); i >= 0 {
+		fields["country"] = strings.ToUpper(u.Hostname()[i+1:])
+	}
+	if pid := u.Query().Get("pid"); pid != "" {
+		fields["product"] = pid
+	}
+	return fields
+}
+
+// Restituisce una copia dei campi con le coppie chiave/valore aggiuntive
+func (f logFields) with(kv ...interface{}) logFields {
+	out := make(logFields, len(f)+len(kv)/2)
+	for k, v := range f {
+		out[k] = v
+	}
+	for i := 0; i+1 < len(kv); i += 2 {
+		if k, ok := kv[i].(string); ok {
+			out[k] = kv[i+1]
+		}
+	}
+	return out
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// Tentativi per scaricare l'elenco degli store nelle ricerche dal menu, con attesa crescente
+const (
+	lookupAttempts   = 3
+	lookupRetryDelay = 2 * time.Second
+)
+
+// Funzione per scaricare l'elenco degli store per le ricerche interattive (città, nome,
+// validazione), ripetendo la richiesta in caso di errori temporanei. In caso di successo
+// aggiorna storeResponse; in caso di errore lo lascia invariato.
+func loadStoreData(endpoint_url string) error {
+	fields := endpointFields(endpoint_url)
+	var err error
+	for attempt := 1; attempt <= lookupAttempts; attempt++ {
+		var response StoreResponse
+		response, err = fetchStoreResponse(endpoint_url)
+		if err == nil {
+			storeResponse = response
+			logger.Debug("Store list downloaded", fields.with("stores", len(storeResponse.Locations)))
+			return nil
+		}
+		if !retryableLookupError(err) || attempt == lookupAttempts {
+			break
+		}
+
+		delay := time.Duration(attempt) * lookupRetryDelay
+		logger.Warn("Store list download failed, retrying", errorFields(fields, err).with("attempt", attempt, "retry_in", delay.String()))
+		select {
+		case <-time.After(delay):
+		case <-appCtx.Done():
+			return appCtx.Err()
+		}
+	}
+	logger.Error("Store list download failed", errorFields(fields, err))
+	return err
+}
+
+// Un JSON non decodificabile non cambia ripetendo subito la richiesta
+func retryableLookupError(err error) bool {
+	var decodeErr *decodeError
+	return !errors.As(err, &decodeErr) && !errors.Is(err, errResponseTooLarge)
+}
+
+// Funzione per scaricare l'elenco degli store dal menu: in caso di errore stampa un messaggio
+// e ritorna false, così si torna al menu invece di terminare il programma
+func loadStoreDataForMenu(endpoint_url string) bool {
+	err := loadStoreData(endpoint_url)
+	if err == nil {
+		return true
+	}
+	printLookupError(err)
+	return false
+}
+
+func printLookupError(err error) {
+	color.Red("Couldn't download the store list: %v\n", lookupErrorHint(err))
+	fmt.Println("Please check your connection and try again.")
+}
+
+// Descrizione breve dell'errore per l'utente; i dettagli restano nel log
+func lookupErrorHint(err error) string {
+	var statusErr *httpStatusError
+	var decodeErr *decodeError
+	switch {
+	case errors.As(err, &statusErr):
+		return fmt.Sprintf("the server answered with HTTP %d", statusErr.StatusCode)
+	case errors.Is(err, errAntiBotChallenge):
+		return "the request was blocked by an anti-bot check"
+	case errors.As(err, &decodeErr):
+		return "the response isn't in the expected format"
+	case errors.Is(err, errResponseTooLarge):
+		return "the response is too large"
+	}
+	return err.Error()
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Waypoint GPX di uno store
+type gpxWaypoint struct {
+	Lat  string `xml:"lat,attr"`
+	Lon  string `xml:"lon,attr"`
+	Name string `xml:"name"`
+	Desc string `xml:"desc,omitempty"`
+}
+
+type gpxFile struct {
+	XMLName   xml.Name      `xml:"http://www.topografix.com/GPX/1/1 gpx"`
+	Version   string        `xml:"version,attr"`
+	Creator   string        `xml:"creator,attr"`
+	Waypoints []gpxWaypoint `xml:"wpt"`
+}
+
+// Segnaposto KML di uno store; le coordinate sono nell'ordine longitudine,latitudine
+type kmlPlacemark struct {
+	Name        string `xml:"name"`
+	Description string `xml:"description,omitempty"`
+	Coordinates string `xml:"Point>coordinates"`
+}
+
+type kmlFile struct {
+	XMLName    xml.Name       `xml:"http://www.opengis.net/kml/2.2 kml"`
+	Name       string         `xml:"Document>name"`
+	Placemarks []kmlPlacemark `xml:"Document>Placemark"`
+}
+
+// Funzione per esportare le coordinate degli store monitorati (Store ID configurati e store
+// delle città in monitor_cities) in un file GPX o KML (in base all'estensione), da aprire in
+// un'app di mappe per organizzare il giro dei ritiri.
+// Ritorna gli store esportati e quelli saltati perché senza coordinate o non trovati.
+func exportStoreMap(path string) (exported int, skipped []string, err error) {
+	format := strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+	if format != "gpx" && format != "kml" {
+		return 0, nil, fmt.Errorf("unsupported file extension %q: use .gpx or .kml", filepath.Ext(path))
+	}
+
+	country, err := readCountrySelection()
+	if err != nil {
+		return 0, nil, fmt.Errorf("no country selected")
+	}
+	ids, err := readStoreIDs()
+	if err != nil {
+		return 0, nil, err
+	}
+	if err := loadStoreData(endpointForCountry(strings.TrimSpace(country))); err != nil {
+		return 0, nil, err
+	}
+	labels, _ := readStoreLabels()
+
+	byID := make(map[string]Location, len(storeResponse.Locations))
+	cities := make(map[string]bool, len(config.MonitorCities))
+	for _, city := range config.MonitorCities {
+		cities[strings.ToUpper(strings.TrimSpace(city))] = true
+	}
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		seen[id] = true
+	}
+	for _, store := range storeResponse.Locations {
+		byID[store.ID] = store
+		// Store delle città monitorate, come in storeDirectory.monitoredIDs
+		if cities[strings.ToUpper(strings.TrimSpace(store.City))] && !seen[store.ID] {
+			seen[store.ID] = true
+			ids = append(ids, store.ID)
+		}
+	}
+
+	var stores []Location
+	for _, id := range ids {
+		store, ok := byID[id]
+		if !ok || (store.Latitude == 0 && store.Longitude == 0) {
+			skipped = append(skipped, id)
+			continue
+		}
+		if label := labels[id]; label != "" {
+			store.Name = fmt.Sprintf("%s (%s)", store.Name, label)
+		}
+		stores = append(stores, store)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return 0, skipped, err
+	}
+
+	if format == "gpx" {
+		err = writeGPX(file, stores)
+	} else {
+		err = writeKML(file, stores)
+	}
+	// Un errore di chiusura può significare che il file non è stato scritto per intero
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return len(stores), skipped, err
+}
+
+func storeMapDescription(store Location) string {
+	var parts []string
+	for _, part := range []string{store.Address1, strings.TrimSpace(store.Postal + " " + store.City)} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return fmt.Sprintf("%s (Store ID %s)", strings.Join(parts, ", "), store.ID)
+}
+
+func formatCoordinate(value FlexFloat64) string {
+	return strconv.FormatFloat(float64(value), 'f', -1, 64)
+}
+
+func writeGPX(w io.Writer, stores []Location) error {
+	doc := gpxFile{Version: "1.1", Creator: "Sephora Sniper"}
+	for _, store := range stores {
+		doc.Waypoints = append(doc.Waypoints, gpxWaypoint{
+			Lat:  formatCoordinate(store.Latitude),
+			Lon:  formatCoordinate(store.Longitude),
+			Name: store.Name,
+			Desc: storeMapDescription(store),
+		})
+	}
+	return writeXML(w, doc)
+}
+
+func writeKML(w io.Writer, stores []Location) error {
+	doc := kmlFile{Name: "Sephora stores"}
+	for _, store := range stores {
+		doc.Placemarks = append(doc.Placemarks, kmlPlacemark{
+			Name:        store.Name,
+			Description: storeMapDescription(store),
+			Coordinates: formatCoordinate(store.Longitude) + "," + formatCoordinate(store.Latitude),
+		})
+	}
+	return writeXML(w, doc)
+}
+
+func writeXML(w io.Writer, doc interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
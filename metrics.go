@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Contatori accumulati durante una esecuzione dello sniper, stampati all'uscita
+type runMetrics struct {
+	mu sync.Mutex
+
+	started       time.Time
+	cycles        int
+	cycleTime     time.Duration
+	requests      int
+	errors        map[string]int
+	notifications int
+	restocks      map[string]bool
+}
+
+var metrics = newRunMetrics()
+
+func newRunMetrics() *runMetrics {
+	return &runMetrics{
+		started:  time.Now(),
+		errors:   make(map[string]int),
+		restocks: make(map[string]bool),
+	}
+}
+
+// Funzione per classificare un errore in una categoria per il riepilogo
+func errorKind(err error) string {
+	var statusErr *httpStatusError
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return "canceled"
+	case errors.Is(err, errAntiBotChallenge):
+		return "anti_bot"
+	case errors.As(err, &statusErr):
+		return fmt.Sprintf("http_%d", statusErr.StatusCode)
+	case truncatedResponse(err):
+		return "truncated"
+	case isNetworkError(err):
+		return "network"
+	default:
+		return "other"
+	}
+}
+
+func (m *runMetrics) recordRequest() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests++
+}
+
+func (m *runMetrics) recordError(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.errors[errorKind(err)]++
+}
+
+func (m *runMetrics) recordNotification() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.notifications++
+}
+
+func (m *runMetrics) recordRestock(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.restocks[key] = true
+}
+
+func (m *runMetrics) recordCycle(duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cycles++
+	m.cycleTime += duration
+}
+
+// Funzione per stampare il riepilogo finale dell'esecuzione
+func (m *runMetrics) printSummary() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var average time.Duration
+	if m.cycles > 0 {
+		average = m.cycleTime / time.Duration(m.cycles)
+	}
+
+	totalErrors := 0
+	kinds := make([]string, 0, len(m.errors))
+	for kind, count := range m.errors {
+		kinds = append(kinds, kind)
+		totalErrors += count
+	}
+	sort.Strings(kinds)
+
+	outputln()
+	outputln("+-+-+-+-+ Run Summary +-+-+-+-+")
+	outputf("Run time:             %v\n", time.Since(m.started).Round(time.Second))
+	outputf("Cycles:               %d\n", m.cycles)
+	outputf("Requests:             %d\n", m.requests)
+	outputf("Errors:               %d\n", totalErrors)
+	for _, kind := range kinds {
+		outputf("  %-19s %d\n", kind+":", m.errors[kind])
+	}
+	outputf("Notifications sent:   %d\n", m.notifications)
+	outputf("Restocks detected:    %d\n", len(m.restocks))
+	outputf("Average cycle time:   %v\n", average.Round(time.Millisecond))
+	outputln("+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+")
+}
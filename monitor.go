@@ -0,0 +1,387 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Pianificazione dei controlli per un singolo paese
+type countrySchedule struct {
+	Country   string
+	URL       string
+	Interval  time.Duration
+	NextCheck time.Time
+	// Attesa aggiuntiva dopo un blocco anti-bot, azzerata al primo controllo riuscito
+	Backoff time.Duration
+
+	// Posizione nella coda di monitoraggio, numero di lotti e inizio del giro corrente
+	QueuePos  int
+	QueueLen  int
+	PassStart time.Time
+}
+
+// Intervallo effettivo: quello configurato, oppure il backoff se più lungo
+func (c *countrySchedule) effectiveInterval() time.Duration {
+	if c.Backoff > c.Interval {
+		return c.Backoff
+	}
+	return c.Interval
+}
+
+// Funzione per costruire la lista dei paesi da monitorare: quello selezionato più quelli
+// configurati in "countries", ognuno con il proprio intervallo (o quello globale)
+func monitoredCountries(selected string, defaultInterval time.Duration) []*countrySchedule {
+	countries := append([]string{selected}, config.Countries...)
+
+	seen := make(map[string]bool)
+	var schedules []*countrySchedule
+	for _, country := range countries {
+		country = strings.ToUpper(strings.TrimSpace(country))
+		if country == "" || seen[country] {
+			continue
+		}
+		seen[country] = true
+
+		interval := defaultInterval
+		if override, ok := config.CountryIntervals[country]; ok {
+			interval = override.Duration
+		}
+
+		schedules = append(schedules, &countrySchedule{
+			Country:  country,
+			URL:      endpointForCountry(country),
+			Interval: interval,
+		})
+	}
+	return schedules
+}
+
+// Ciclo principale dello sniper: ogni paese viene controllato secondo il proprio intervallo.
+// Con maxCycles > 0 la funzione ritorna dopo quel numero di cicli; ritorna anche su
+// SIGINT/SIGTERM o allo scadere di -max-runtime, stampando in ogni caso il riepilogo.
+// Il valore restituito è il codice di uscita per le modalità one-shot: uno store disponibile
+// ha la precedenza su eventuali errori di rete avvenuti per altri paesi o prodotti.
+func runSniper(settings runtimeSettings, maxCycles int) int {
+	ctx, stop := signal.NotifyContext(appCtx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// SIGHUP: ricarica dei file di configurazione, applicata all'inizio del ciclo successivo
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	defer signal.Stop(reload)
+
+	storeIDs := settings.StoreIDs
+	hookurl := settings.Webhook
+	schedules := monitoredCountries(settings.Country, settings.Interval)
+	if *maxRuntimeFlag > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *maxRuntimeFlag)
+		defer cancel()
+	}
+
+	// Le richieste in corso vengono annullate insieme al ciclo
+	previousCtx := appCtx
+	appCtx = ctx
+	defer func() { appCtx = previousCtx }()
+
+	printWatchListDiff(settings)
+	if maxCycles == 0 {
+		watchPauseKeys()
+	}
+
+	metrics = newRunMetrics()
+	defer metrics.printSummary()
+	defer proxies.logSummary()
+	defer saveStats()
+
+	// API di controllo opzionale, attiva solo mentre lo sniper è in esecuzione
+	var api *controlAPI
+	if *apiAddrFlag != "" {
+		var err error
+		if api, err = startControlAPI(ctx, *apiAddrFlag, reload); err != nil {
+			logger.Error("Failed to start control API", logFields{"addr": *apiAddrFlag, "error": err})
+			return exitConfigError
+		}
+	}
+
+	var watchdog connectivityWatchdog
+	var watchlist productWatchList
+	directory := newStoreDirectory()
+	foundInStock, hadError := false, false
+	// Riepilogo dell'ultimo ciclo completato, mostrato nella riga di stato compatta
+	statusStores, statusInStock := 0, 0
+	exitCode := func() int {
+		switch {
+		case foundInStock:
+			return exitInStock
+		case hadError:
+			return exitNetworkError
+		}
+		return exitNotInStock
+	}
+
+	for cycle := 1; ; cycle++ {
+		select {
+		case <-reload:
+			updated, err := loadRuntimeSettings()
+			if err != nil {
+				logger.Error("Configuration reload failed, keeping current settings", logFields{"error": err})
+				break
+			}
+			changes := describeSettingsChanges(settings, updated)
+			if len(changes) == 0 {
+				logger.Info("Configuration reloaded, nothing changed", nil)
+				break
+			}
+
+			settings = updated
+			config = updated.Config
+			messageTemplate = loadMessageTemplate()
+			storeIDs = updated.StoreIDs
+			hookurl = updated.Webhook
+			schedules = rescheduleCountries(schedules, updated)
+			logger.Info("Configuration reloaded", logFields{"changes": strings.Join(changes, "; ")})
+		default:
+		}
+
+		cycleStart := time.Now()
+		checkedAny := false
+		// Store controllati e disponibili nel ciclo, per la riga di stato compatta
+		cycleStores := make(map[string]bool)
+		cycleInStock := make(map[string]bool)
+		for _, schedule := range schedules {
+			if cycleStart.Before(schedule.NextCheck) {
+				continue
+			}
+			checkedAny = true
+
+			// Aggiornamento periodico dell'elenco store (città monitorate, nomi e indirizzi)
+			directory.refreshIfDue(schedule.Country, schedule.URL, hookurl)
+			countryStoreIDs := directory.monitoredIDs(schedule.Country, storeIDs)
+
+			// Con la coda attiva ogni turno controlla un solo lotto; le esecuzioni limitate
+			// (-once, -count) controllano sempre tutto
+			queued := config.MonitorQueue.enabled() && maxCycles == 0
+			units := queueUnits(checkURLs(schedule.URL, watchlist.products()), countryStoreIDs)
+			for _, unit := range schedule.nextUnits(units, config.MonitorQueue.BatchSize, queued) {
+				productURL := unit.URL
+
+				results, err := checkProductAvailability(unit.StoreIDs, productURL)
+				if ctx.Err() != nil {
+					logShutdown(ctx)
+					return exitCode()
+				}
+				recordStatsRequest(schedule.Country, err)
+				if err != nil {
+					metrics.recordError(err)
+					hadError = true
+				} else {
+					reportCheckResults(results, hookurl)
+					api.recordResults(results)
+					for _, result := range results {
+						foundInStock = foundInStock || result.Available
+						cycleStores[result.Country+result.StoreID] = true
+						if result.Available {
+							cycleInStock[result.Country+result.StoreID] = true
+						}
+					}
+				}
+
+				if errors.Is(err, errAntiBotChallenge) {
+					// Le esecuzioni limitate (-once, -count) mantengono l'intervallo richiesto
+					if maxCycles > 0 {
+						logger.Warn("Anti-bot challenge detected, keeping interval for bounded run", logFields{"country": schedule.Country})
+						break
+					}
+					schedule.Backoff = nextBlockBackoff(schedule.Backoff)
+					logger.Warn("Anti-bot challenge detected, slowing down", logFields{"country": schedule.Country, "backoff": schedule.Backoff.String(), "interval": schedule.effectiveInterval().String()})
+					break
+				} else if err != nil && isNetworkError(err) {
+					// Errore di rete: dopo troppi errori consecutivi sospendiamo i controlli
+					logger.Error("Network error during availability check", errorFields(endpointFields(productURL), err))
+					if watchdog.recordFailure() {
+						watchdog.waitForConnectivity(productURL)
+						if ctx.Err() != nil {
+							logShutdown(ctx)
+							return exitCode()
+						}
+						resumeAll(schedules)
+					}
+					break
+				} else if err != nil && truncatedResponse(err) && maxCycles == 0 {
+					// Risposta ancora troncata dopo le nuove richieste: si riprova al prossimo controllo
+					logger.Warn("Truncated response, skipping until the next check", errorFields(endpointFields(productURL), err).with("next_check", schedule.effectiveInterval().String()))
+					break
+				} else if err != nil {
+					// HTTP 403/429/5xx, risposta non valida o troppo grande: le esecuzioni limitate
+					// terminano con il codice di errore (dopo il riepilogo), lo sniper continua
+					logger.Error("Errore nel controllo della disponibilità", errorFields(endpointFields(productURL), err))
+					if maxCycles > 0 {
+						return exitNetworkError
+					}
+					break
+				} else {
+					if schedule.Backoff > 0 {
+						schedule.Backoff = recoverBlockBackoff(schedule.Backoff)
+						logger.Info("Check succeeded, recovering interval", logFields{"country": schedule.Country, "interval": schedule.effectiveInterval().String()})
+					}
+					watchdog.recordSuccess()
+				}
+			}
+
+			//Timestamp
+			if !compactOutput() {
+				timestamp := formatTimestamp(time.Now())
+				outputf("Cycle %d - Checked %s%s at: %s\n\n", cycle, schedule.Country, schedule.batchLabel(), timestamp)
+			}
+
+			schedule.scheduleNext()
+		}
+		if !checkedAny {
+			// Nessun paese in scadenza (es. dopo una ricarica): il ciclo non viene conteggiato
+			cycle--
+		} else {
+			metrics.recordCycle(time.Since(cycleStart))
+			api.recordCycle(cycle, schedules)
+			statusStores, statusInStock = len(cycleStores), len(cycleInStock)
+		}
+
+		// Riepilogo delle notifiche accodate se la fascia silenziosa è terminata
+		flushQuietDigest()
+
+		// Statistiche cumulative (stats.json)
+		saveStats()
+
+		// Cookie di sessione salvati per la prossima esecuzione (se persist_cookies è attivo)
+		saveCookies()
+
+		// Riconoscimenti tramite reazione agli avvisi su Discord (se configurato il bot)
+		pollDiscordAcks()
+
+		if checkedAny && maxCycles > 0 && cycle >= maxCycles {
+			logger.Info("Cycle limit reached, stopping", logFields{"cycles": cycle})
+			return exitCode()
+		}
+
+		// Inizializza il timer per l'output, fino al prossimo controllo in scadenza
+		for {
+			// In pausa il countdown si ferma finché il monitoraggio non viene ripreso
+			if paused, since := monitorPause.state(); paused {
+				printInPlace(yellowColor+"⏸  Monitoring paused since %s - type p and press Enter to resume"+resetColor, formatTimestamp(since))
+				select {
+				case <-ctx.Done():
+					endInPlace()
+					logShutdown(ctx)
+					return exitCode()
+				case <-monitorPause.changes():
+				case <-time.After(time.Second):
+				}
+				if paused, _ := monitorPause.state(); !paused {
+					// Alla ripresa i file modificati durante la pausa vengono riletti e i paesi
+					// controllati subito
+					select {
+					case reload <- syscall.SIGHUP:
+					default:
+					}
+					resumeAll(schedules)
+					break
+				}
+				continue
+			}
+
+			remaining := nextDue(schedules).Sub(time.Now())
+			if remaining <= 0 {
+				break
+			}
+			if compactOutput() {
+				printStatusLine(cycle, statusStores, statusInStock, schedules)
+			} else {
+				printInPlace(redColor+"Leave this Terminal Page open, next check: %s"+resetColor, countdownSummary(schedules))
+			}
+
+			select {
+			case <-ctx.Done():
+				endInPlace()
+				logShutdown(ctx)
+				return exitCode()
+			case <-api.checkRequests():
+				// Controllo immediato richiesto tramite l'API
+				resumeAll(schedules)
+			case <-monitorPause.changes():
+			case <-time.After(minDuration(time.Second, remaining)):
+			}
+			// Una ricarica in attesa viene applicata senza aspettare la fine del countdown
+			if len(reload) > 0 {
+				break
+			}
+		}
+		if !compactOutput() {
+			endInPlace()
+		}
+	}
+}
+
+// Funzione per registrare il motivo dell'arresto (segnale o durata massima raggiunta)
+func logShutdown(ctx context.Context) {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		logger.Info("Maximum run time reached, stopping", logFields{"max_runtime": maxRuntimeFlag.String()})
+		return
+	}
+	logger.Info("Shutdown requested, stopping", nil)
+}
+
+// Funzione per riprogrammare subito tutti i paesi (es. al ritorno della connessione)
+func resumeAll(schedules []*countrySchedule) {
+	for _, schedule := range schedules {
+		schedule.NextCheck = time.Time{}
+	}
+}
+
+// Funzione per trovare il primo controllo in scadenza tra tutti i paesi
+func nextDue(schedules []*countrySchedule) time.Time {
+	var next time.Time
+	for i, schedule := range schedules {
+		if i == 0 || schedule.NextCheck.Before(next) {
+			next = schedule.NextCheck
+		}
+	}
+	return next
+}
+
+// Funzione per descrivere il tempo mancante al prossimo controllo di ogni paese
+func countdownSummary(schedules []*countrySchedule) string {
+	sorted := make([]*countrySchedule, len(schedules))
+	copy(sorted, schedules)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].NextCheck.Before(sorted[j].NextCheck)
+	})
+
+	parts := make([]string, 0, len(sorted))
+	for _, schedule := range sorted {
+		remaining := time.Until(schedule.NextCheck)
+		if remaining < 0 {
+			remaining = 0
+		}
+		part := fmt.Sprintf("%s in %v seconds", schedule.Country, int(remaining.Seconds()))
+		if schedule.effectiveInterval() > schedule.Interval {
+			// Intervallo rallentato dopo un blocco anti-bot
+			part += fmt.Sprintf(" (slowed to %v)", schedule.effectiveInterval())
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func minDuration(a, b time.Duration) time.Duration {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Store noti per ogni città monitorata ("PAESE|CITTÀ" -> Store ID), salvati tra un'esecuzione
+// e l'altra per riconoscere l'apertura o la chiusura di uno store anche dopo un riavvio
+const knownStoresFile = "known_stores.json"
+
+// Chiave in known_stores.json con la richiesta allo store locator usata per ogni paese
+// ("#request|PAESE"): filtri come store_services o search_area cambiano gli store restituiti,
+// e gli store esclusi dal nuovo filtro non sono store chiusi
+const knownRequestPrefix = "#request|"
+
+const embedColorBlue = 0x3498DB
+
+func loadKnownStores() map[string][]string {
+	known := make(map[string][]string)
+	if _, err := readStateFile(knownStoresFile, &known); err != nil {
+		logger.Warn("Failed to read known stores", logFields{"error": err})
+	}
+	return known
+}
+
+func saveKnownStores(known map[string][]string) {
+	if readOnlyMode || notifyStateReadOnly {
+		return
+	}
+	if err := writeStateFile(knownStoresFile, known); err != nil {
+		logger.Warn("Failed to save known stores", logFields{"error": err})
+	}
+}
+
+// Funzione per confrontare gli store delle città monitorate con quelli noti e avvisare, con
+// notify_new_stores, quando uno store compare o scompare. Al primo controllo di una città gli
+// store vengono solo registrati.
+func (d *storeDirectory) detectStoreChanges(country string, webhookurl string) {
+	if !config.NotifyNewStores || len(config.MonitorCities) == 0 {
+		return
+	}
+	if d.knownStores == nil {
+		d.knownStores = loadKnownStores()
+	}
+
+	changed := false
+	request := []string{endpointForCountry(country)}
+	if previous, seen := d.knownStores[knownRequestPrefix+country]; !seen || len(previous) == 0 || previous[0] != request[0] {
+		if seen {
+			// Con un filtro diverso gli store vengono registrati di nuovo, senza avvisi
+			for key := range d.knownStores {
+				if strings.HasPrefix(key, country+"|") {
+					delete(d.knownStores, key)
+				}
+			}
+			logger.Info("Store locator request changed, recording the stores of monitored cities again", logFields{"country": country})
+		}
+		d.knownStores[knownRequestPrefix+country] = request
+		changed = true
+	}
+	for _, city := range config.MonitorCities {
+		city = strings.ToUpper(strings.TrimSpace(city))
+		key := country + "|" + city
+		ids, ok := d.cityStores[key]
+		if !ok {
+			continue
+		}
+		current := append([]string{}, ids...)
+		sort.Strings(current)
+
+		previous, seen := d.knownStores[key]
+		d.knownStores[key] = current
+		if !seen {
+			logger.Info("Recorded stores of monitored city", logFields{"country": country, "city": city, "stores": len(current)})
+			changed = true
+			continue
+		}
+		added, removed := diffStoreIDs(previous, current)
+		for _, id := range added {
+			d.notifyStoreChange(country, city, id, true, webhookurl)
+		}
+		for _, id := range removed {
+			d.notifyStoreChange(country, city, id, false, webhookurl)
+		}
+		if len(added) > 0 || len(removed) > 0 {
+			changed = true
+		}
+	}
+	if changed {
+		saveKnownStores(d.knownStores)
+	}
+}
+
+// Funzione per ottenere gli Store ID aggiunti e rimossi tra due elenchi ordinati
+func diffStoreIDs(previous []string, current []string) (added []string, removed []string) {
+	before := make(map[string]bool, len(previous))
+	for _, id := range previous {
+		before[id] = true
+	}
+	now := make(map[string]bool, len(current))
+	for _, id := range current {
+		now[id] = true
+		if !before[id] {
+			added = append(added, id)
+		}
+	}
+	for _, id := range previous {
+		if !now[id] {
+			removed = append(removed, id)
+		}
+	}
+	return added, removed
+}
+
+// Funzione per inviare l'avviso di un nuovo store (o di uno store non più presente), separato
+// dagli avvisi di disponibilità dei prodotti
+func (d *storeDirectory) notifyStoreChange(country string, city string, storeID string, opened bool, webhookurl string) {
+	store := d.stores[storeID]
+	fields := logFields{"country": country, "city": city, "store": storeID}
+
+	var title, message string
+	if opened {
+		title = fmt.Sprintf("%sNew Sephora store in %s", emojiPrefix("new_store"), city)
+		message = fmt.Sprintf("A new store appeared in the store locator: **%s** (%s)\nStore Address: %s", store.Name, storeID, store.Address1)
+		fields = fields.with("event", "store_added", "name", store.Name)
+	} else {
+		name := storeID
+		if store.Name != "" {
+			name = fmt.Sprintf("**%s** (%s)", store.Name, storeID)
+		}
+		title = fmt.Sprintf("%sSephora store removed in %s", emojiPrefix("removed_store"), city)
+		message = fmt.Sprintf("The store %s is no longer listed by the store locator.", name)
+		fields = fields.with("event", "store_removed")
+	}
+	logger.Info("Monitored city stores changed", fields)
+
+	var discordErr error
+	webhookurl = webhookFor(CheckResult{Country: country}, webhookurl)
+	if webhookurl != "" {
+		discordErr = dispatch(webhookurl, fmt.Sprintf("**%s**\n%s", title, message), fields, func() error {
+			return sendDiscordEmbed(webhookurl, title, message, embedColorBlue)
+		})
+	}
+	logDeliveryResult(fields, errors.Join(discordErr, dispatchNtfy(CheckResult{Country: country, StoreID: storeID}, ntfyMessage{
+		Title:    title,
+		Body:     message,
+		Priority: config.Ntfy.priority(),
+		Tags:     ntfyTags("department_store"),
+	})))
+}
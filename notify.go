@@ -0,0 +1,393 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Fascia oraria in cui le notifiche vengono soppresse (i controlli continuano).
+// Start ed End sono nel formato "15:04" e nel fuso orario configurato; se End è
+// prima di Start la fascia attraversa la mezzanotte (es. 23:00-07:00).
+type QuietHours struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+	// Se true, le notifiche soppresse vengono inviate in un unico riepilogo a fine fascia
+	Digest bool `json:"digest"`
+}
+
+func (q QuietHours) enabled() bool {
+	return q.Start != "" && q.End != ""
+}
+
+// Funzione per validare gli orari della fascia silenziosa
+func (q QuietHours) validate() error {
+	if !q.enabled() {
+		return nil
+	}
+	for _, value := range []string{q.Start, q.End} {
+		if _, err := time.Parse("15:04", value); err != nil {
+			return fmt.Errorf("invalid quiet hours time %q: use HH:MM", value)
+		}
+	}
+	return nil
+}
+
+// Funzione per verificare se un orario cade nella fascia silenziosa
+func (q QuietHours) active(t time.Time) bool {
+	if !q.enabled() {
+		return false
+	}
+	start, err1 := time.Parse("15:04", q.Start)
+	end, err2 := time.Parse("15:04", q.End)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+
+	local := t.In(configuredLocation())
+	minutes := local.Hour()*60 + local.Minute()
+	startMinutes := start.Hour()*60 + start.Minute()
+	endMinutes := end.Hour()*60 + end.Minute()
+
+	if startMinutes <= endMinutes {
+		return minutes >= startMinutes && minutes < endMinutes
+	}
+	return minutes >= startMinutes || minutes < endMinutes
+}
+
+// Instradamento delle notifiche su webhook diversi per store o per paese
+type WebhookRoutes struct {
+	Countries map[string]string `json:"countries"`
+	Stores    map[string]string `json:"stores"`
+}
+
+// Funzione per validare gli URL delle rotte configurate
+func (r WebhookRoutes) validate() error {
+	for _, routes := range []map[string]string{r.Countries, r.Stores} {
+		for key, webhookURL := range routes {
+			if err := validateWebhookURL(webhookURL); err != nil {
+				return fmt.Errorf("webhook_routes %q: %v", key, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Funzione per scegliere il webhook di un risultato: prima la rotta dello store, poi quella
+// del paese, altrimenti il webhook di default
+func webhookFor(result CheckResult, defaultURL string) string {
+	routes := config.WebhookRoutes
+	if webhookURL, ok := routes.Stores[result.StoreID]; ok {
+		return webhookURL
+	}
+	for country, webhookURL := range routes.Countries {
+		if strings.EqualFold(country, result.Country) {
+			return webhookURL
+		}
+	}
+	return defaultURL
+}
+
+// Notifica accodata durante la fascia silenziosa; Key identifica store, prodotto e modalità
+// di consegna (vuota per gli avvisi non legati a uno store)
+type quietDigestEntry struct {
+	Key     string
+	Message string
+}
+
+// Riepilogo della fascia silenziosa di una destinazione
+type quietDigestQueue struct {
+	Target  notifyTarget
+	Entries []quietDigestEntry
+}
+
+// Notifiche accodate durante la fascia silenziosa, per destinazione (url del webhook o del topic)
+var (
+	quietDigestMu sync.Mutex
+	quietDigest   = make(map[string]*quietDigestQueue)
+)
+
+// Destinazione di una notifica: url (webhook Discord o topic ntfy), nome del canale per lo
+// stato e i log, e funzione per inviare un messaggio in testo semplice, usata per il riepilogo
+type notifyTarget struct {
+	URL      string
+	Channel  string
+	Notifier string
+	SendText func(message string) error
+}
+
+func discordTarget(webhookurl string) notifyTarget {
+	return notifyTarget{
+		URL:      webhookurl,
+		Channel:  discordChannel(webhookurl),
+		Notifier: "Discord",
+		SendText: func(message string) error { return sendDiscordNotification(webhookurl, message) },
+	}
+}
+
+// Funzione per accodare un messaggio al riepilogo: un nuovo avviso per lo stesso store,
+// prodotto e modalità di consegna sostituisce il precedente, così un prodotto che resta
+// disponibile per tutta la fascia silenziosa compare una sola volta
+func queueQuietDigest(target notifyTarget, message string, fields logFields) {
+	var key string
+	if store, _ := fields["store"].(string); store != "" {
+		product, _ := fields["product"].(string)
+		variant, _ := fields["variant"].(string)
+		fulfillment, _ := fields["fulfillment"].(string)
+		key = strings.Join([]string{store, product, variant, fulfillment}, "|")
+	}
+
+	quietDigestMu.Lock()
+	defer quietDigestMu.Unlock()
+	queue, ok := quietDigest[target.URL]
+	if !ok {
+		queue = &quietDigestQueue{Target: target}
+		quietDigest[target.URL] = queue
+	}
+	if key != "" {
+		for i, entry := range queue.Entries {
+			if entry.Key == key {
+				queue.Entries[i].Message = message
+				return
+			}
+		}
+	}
+	queue.Entries = append(queue.Entries, quietDigestEntry{Key: key, Message: message})
+}
+
+// Funzione per inviare una notifica, rispettando la fascia silenziosa.
+// I log registrano comunque ogni evento, anche quando la notifica viene soppressa.
+func dispatchNotification(webhookurl string, message string, fields logFields) error {
+	return dispatch(webhookurl, message, fields, func() error {
+		return sendDiscordNotification(webhookurl, message)
+	})
+}
+
+// Funzione per avvisare che un prodotto non è più disponibile in uno store, con un embed arancione
+func dispatchOutOfStockNotification(webhookurl string, result CheckResult) error {
+	title := emojiPrefix("out_of_stock") + "Out of stock"
+	message := fmt.Sprintf("The product is no longer available in the store **%s**.\nStore Address: %s\nChecked at: %s",
+		result.Name, result.Address, formatTimestamp(result.CheckedAt))
+	return errors.Join(
+		dispatch(webhookurl, fmt.Sprintf("**%s**\n%s", title, message), result.fields().with("event", "out_of_stock"), func() error {
+			return sendDiscordEmbed(webhookurl, title, message, embedColorOrange)
+		}),
+		dispatchNtfy(result, ntfyMessage{
+			Title:    fmt.Sprintf("Sold out at %s", result.Name),
+			Body:     message,
+			Priority: "low",
+			Tags:     ntfyTags("x"),
+		}),
+	)
+}
+
+// Funzione per registrare l'esito complessivo di un avviso inviato su più canali. Ogni canale
+// viene tentato anche se un altro fallisce; err è l'unione (errors.Join) degli errori dei
+// canali che non hanno ricevuto l'avviso, mentre l'esito di ciascun canale è già nel log.
+func logDeliveryResult(fields logFields, err error) {
+	if err = deliveryFailures(err); err == nil {
+		return
+	}
+	logger.Warn("Notification not delivered to every channel", fields.with("error", err))
+}
+
+// Avviso non inviato per scelta (canale disattivato o non configurato, fascia silenziosa,
+// chiusura in corso): non è un errore del canale, ma l'avviso non è arrivato a nessuno
+var errNotSent = errors.New("notification not sent")
+
+// Funzione per togliere da un errore, anche combinato con errors.Join, gli invii non avvenuti
+// per scelta; restano solo i canali che hanno fallito
+func deliveryFailures(err error) error {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var failures []error
+		for _, channelErr := range joined.Unwrap() {
+			if channelErr = deliveryFailures(channelErr); channelErr != nil {
+				failures = append(failures, channelErr)
+			}
+		}
+		return errors.Join(failures...)
+	}
+	if errors.Is(err, errNotSent) {
+		return nil
+	}
+	return err
+}
+
+// Funzione per verificare se almeno un canale ha effettivamente ricevuto l'avviso
+func deliveredAnywhere(channelErrs ...error) bool {
+	for _, err := range channelErrs {
+		if err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Limite di frequenza delle notifiche, separato dal limite globale delle richieste HTTP:
+// tra due invii passano almeno 1/notify_rate secondi, così i restock simultanei non
+// superano i rate limit di Discord
+var (
+	notifyThrottleMu sync.Mutex
+	notifyNextSend   time.Time
+)
+
+// Funzione per attendere il prossimo invio consentito; ritorna false se l'esecuzione
+// viene interrotta durante l'attesa
+func waitNotifySlot() bool {
+	if config.NotifyRate <= 0 {
+		return true
+	}
+	spacing := time.Duration(float64(time.Second) / config.NotifyRate)
+
+	notifyThrottleMu.Lock()
+	now := time.Now()
+	sendAt := notifyNextSend
+	if sendAt.Before(now) {
+		sendAt = now
+	}
+	notifyNextSend = sendAt.Add(spacing)
+	notifyThrottleMu.Unlock()
+
+	wait := time.Until(sendAt)
+	if wait <= 0 {
+		return true
+	}
+	logger.Debug("Notification throttled", logFields{"wait": wait.String()})
+	select {
+	case <-time.After(wait):
+		return true
+	case <-appCtx.Done():
+		return false
+	}
+}
+
+// Funzione comune di invio su Discord: durante la fascia silenziosa il messaggio in testo
+// semplice viene accodato al riepilogo (o scartato), altrimenti viene chiamata send. Ritorna
+// l'errore di invio, con il nome del canale; un avviso soppresso o accodato non è un errore.
+func dispatch(webhookurl string, message string, fields logFields, send func() error) error {
+	return dispatchTo(discordTarget(webhookurl), message, fields, send)
+}
+
+// Come dispatch, per una destinazione qualsiasi: ogni notificatore passa dalla stessa
+// fascia silenziosa, dallo stesso limite di frequenza e dalle stesse statistiche
+func dispatchTo(target notifyTarget, message string, fields logFields, send func() error) error {
+	channel := target.Channel
+	fields = fields.with("channel", channel)
+	if channelDisabled(channel) {
+		logger.Warn("Notification skipped, channel disabled", fields)
+		return fmt.Errorf("%s: %w (channel disabled)", channel, errNotSent)
+	}
+	if config.QuietHours.active(time.Now()) {
+		if config.QuietHours.Digest {
+			queueQuietDigest(target, message, fields)
+			logger.Info("Notification queued for quiet hours digest", fields)
+		} else {
+			logger.Info("Notification suppressed during quiet hours", fields)
+		}
+		return fmt.Errorf("%s: %w (quiet hours)", channel, errNotSent)
+	}
+
+	if !waitNotifySlot() {
+		logger.Warn("Notification dropped, shutting down", fields)
+		return fmt.Errorf("%s: %w (shutting down)", channel, errNotSent)
+	}
+	err := send()
+	recordChannelResult(channel, err)
+	if err != nil {
+		logNotifyError("Failed to send "+target.Notifier+" notification", fields, err)
+		return fmt.Errorf("%s: %w", channel, err)
+	}
+	metrics.recordNotification()
+	country, _ := fields["country"].(string)
+	recordStatsNotification(country)
+	logger.Info(target.Notifier+" notification sent", fields)
+	return nil
+}
+
+// Funzione per misurare il ritardo tra il controllo che ha rilevato un restock e l'invio
+// dell'avviso (conferme, limite di frequenza e tentativi compresi): send viene eseguita e,
+// se l'invio riesce, il ritardo viene registrato nel log e nelle statistiche
+func withLatency(result CheckResult, send func() error) func() error {
+	return func() error {
+		if err := send(); err != nil {
+			return err
+		}
+		sentAt := time.Now()
+		latency := sentAt.Sub(result.CheckedAt)
+		logger.Info("Restock notification latency", result.fields().with(
+			"checked_at", formatTimestamp(result.CheckedAt),
+			"sent_at", formatTimestamp(sentAt),
+			"latency", latency.Round(time.Millisecond).String(),
+		))
+		if !result.Simulated {
+			recordStatsLatency(result.Country, latency)
+		}
+		return nil
+	}
+}
+
+// Tempo massimo per l'invio di una notifica se non configurato diversamente
+const defaultNotifyTimeout = 10 * time.Second
+
+func notifyTimeout() time.Duration {
+	if config.NotifyTimeout.Duration > 0 {
+		return config.NotifyTimeout.Duration
+	}
+	return defaultNotifyTimeout
+}
+
+// Client HTTP per i notificatori (Discord, ntfy), con un timeout proprio e separato da quello
+// dello store locator: un webhook lento non può bloccare i controlli
+func notifierClient() *http.Client {
+	return &http.Client{Timeout: notifyTimeout()}
+}
+
+// Funzione per riconoscere un errore di timeout
+func isTimeout(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, context.DeadlineExceeded)
+}
+
+// Funzione per registrare un errore di invio, distinguendo i timeout dagli altri errori
+func logNotifyError(msg string, fields logFields, err error) {
+	if isTimeout(err) {
+		logger.Error("Notification timed out", fields.with("timeout", notifyTimeout().String(), "error", err))
+		return
+	}
+	logger.Error(msg, fields.with("error", err))
+}
+
+// Funzione per inviare il riepilogo delle notifiche accodate, una volta terminata la fascia silenziosa
+func flushQuietDigest() {
+	if config.QuietHours.active(time.Now()) {
+		return
+	}
+
+	quietDigestMu.Lock()
+	pending := quietDigest
+	quietDigest = make(map[string]*quietDigestQueue)
+	quietDigestMu.Unlock()
+
+	for _, queue := range pending {
+		if len(queue.Entries) == 0 {
+			continue
+		}
+		messages := make([]string, len(queue.Entries))
+		for i, entry := range queue.Entries {
+			messages[i] = entry.Message
+		}
+		digest := fmt.Sprintf("**Quiet hours digest: %d notifications**\n\n%s", len(messages), strings.Join(messages, "\n\n"))
+		target := queue.Target
+		dispatchTo(target, digest, logFields{"digest": len(messages)}, func() error {
+			return target.SendText(digest)
+		})
+	}
+}
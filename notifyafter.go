@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Formato delle date di store_notify_after (giorno nel fuso orario configurato)
+const notifyAfterLayout = "2006-01-02"
+
+// Funzione per leggere la data di attivazione di uno store: una data (2006-01-02), che vale
+// dalla mezzanotte nel fuso orario configurato, oppure un orario RFC 3339
+func parseNotifyAfter(value string, loc *time.Location) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if t, err := time.ParseInLocation(notifyAfterLayout, value, loc); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q: use YYYY-MM-DD or an RFC 3339 time", value)
+}
+
+// Funzione per validare le date di attivazione configurate
+func validateNotifyAfter(dates map[string]string) error {
+	for storeID, value := range dates {
+		if !validStoreID(storeID) {
+			return fmt.Errorf("store_notify_after: invalid store ID %q", storeID)
+		}
+		if _, err := parseNotifyAfter(value, time.Local); err != nil {
+			return fmt.Errorf("store_notify_after %q: %v", storeID, err)
+		}
+	}
+	return nil
+}
+
+// Funzione per verificare se gli avvisi di uno store sono ancora rimandati: lo store viene
+// controllato normalmente, ma fino alla data configurata non parte nessuna notifica.
+// Ritorna la data di attivazione e true se non è ancora passata.
+func notifyDeferred(storeID string, now time.Time) (time.Time, bool) {
+	value, ok := config.StoreNotifyAfter[storeID]
+	if !ok {
+		return time.Time{}, false
+	}
+	after, err := parseNotifyAfter(value, configuredLocation())
+	if err != nil {
+		return time.Time{}, false
+	}
+	return after, now.Before(after)
+}
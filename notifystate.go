@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const notifyStateFile = "notify_state.json"
+
+// Stato delle notifiche per una coppia store/prodotto, salvato su file tra un ciclo e l'altro.
+// Acknowledged è impostato dall'utente e sopprime gli avvisi finché il prodotto non torna
+// esaurito; CooldownUntil è automatico e limita la frequenza degli avvisi ripetuti.
+type notifyEntry struct {
+	StoreID       string    `json:"store_id"`
+	ProductID     string    `json:"product_id"`
+	Country       string    `json:"country"`
+	Available     bool      `json:"available"`
+	LastNotified  time.Time `json:"last_notified,omitempty"`
+	CooldownUntil time.Time `json:"cooldown_until,omitempty"`
+	Acknowledged  bool      `json:"acknowledged"`
+	// Vuoto per click & collect, "delivery" per la consegna in negozio
+	Fulfillment string `json:"fulfillment,omitempty"`
+	// Inizio del periodo di disponibilità continua, per i promemoria "still available"
+	AvailableSince time.Time `json:"available_since,omitempty"`
+}
+
+// Tipo di avviso deciso dallo stato per un risultato
+type notifyAction int
+
+const (
+	notifyNone notifyAction = iota
+	notifyRestock
+	// Promemoria per un prodotto disponibile da tempo, inviato anche durante il cooldown
+	notifyStillAvailable
+)
+
+type notifyState map[string]*notifyEntry
+
+func notifyStateKey(country string, productID string, storeID string, fulfillment string) string {
+	key := fmt.Sprintf("%s|%s|%s", country, productID, storeID)
+	// Le voci click & collect mantengono la chiave storica
+	if fulfillment == fulfillmentDelivery {
+		key += "|" + fulfillment
+	}
+	return key
+}
+
+// Funzione per leggere lo stato delle notifiche dal file
+func loadNotifyState() (notifyState, error) {
+	state := make(notifyState)
+	if _, err := readStateFile(notifyStateFile, &state); err != nil {
+		return make(notifyState), err
+	}
+	return state, nil
+}
+
+// Funzione per ripristinare all'avvio le disponibilità note dallo stato salvato: dopo un riavvio
+// un prodotto ancora disponibile e già notificato non viene considerato un nuovo restock
+func restoreLastAvailability() {
+	state, err := loadNotifyState()
+	if err != nil {
+		logger.Warn("Failed to read notification state, restocks may be reported again", logFields{"error": err})
+		return
+	}
+	restored := 0
+	for _, entry := range state {
+		if !entry.Available {
+			continue
+		}
+		// La chiave dello stato coincide con quella usata per rilevare i cambi di disponibilità
+		lastAvailability[notifyStateKey(entry.Country, entry.ProductID, entry.StoreID, entry.Fulfillment)] = true
+		restored++
+	}
+	if restored > 0 {
+		logger.Debug("Restored availability from notification state", logFields{"entries": restored})
+	}
+}
+
+// Se true lo stato non viene salvato (simulazioni senza -persist)
+var notifyStateReadOnly bool
+
+// Funzione per scrivere lo stato delle notifiche nel file
+func saveNotifyState(state notifyState) error {
+	if notifyStateReadOnly {
+		return nil
+	}
+	return writeStateFile(notifyStateFile, state)
+}
+
+// Funzione per ottenere (o creare) la voce di stato di un risultato
+func (s notifyState) entry(result CheckResult) *notifyEntry {
+	key := notifyStateKey(result.Country, result.ProductID, result.StoreID, result.Fulfillment)
+	entry, ok := s[key]
+	if !ok {
+		entry = &notifyEntry{StoreID: result.StoreID, ProductID: result.ProductID, Country: result.Country}
+		if result.Fulfillment == fulfillmentDelivery {
+			entry.Fulfillment = result.Fulfillment
+		}
+		s[key] = entry
+	}
+	return entry
+}
+
+// Funzione per aggiornare lo stato con un risultato e decidere quale avviso inviare.
+// Ritorna anche il motivo dell'eventuale soppressione, per il log.
+func (s notifyState) update(result CheckResult, now time.Time) (notifyAction, string) {
+	entry := s.entry(result)
+
+	if !result.Available {
+		// Esaurito: riconoscimento e cooldown vengono azzerati
+		entry.Available = false
+		entry.Acknowledged = false
+		entry.CooldownUntil = time.Time{}
+		entry.AvailableSince = time.Time{}
+		return notifyNone, ""
+	}
+
+	if !entry.Available || entry.AvailableSince.IsZero() {
+		entry.AvailableSince = now
+	}
+	entry.Available = true
+	if entry.Acknowledged {
+		return notifyNone, "acknowledged"
+	}
+	if now.Before(entry.CooldownUntil) {
+		// Il promemoria non rinnova il cooldown: conta solo l'ultimo avviso inviato
+		reminder := config.StillAvailableInterval.Duration
+		if reminder > 0 && now.Sub(entry.LastNotified) >= reminder {
+			entry.LastNotified = now
+			return notifyStillAvailable, ""
+		}
+		return notifyNone, "cooldown"
+	}
+
+	entry.LastNotified = now
+	if config.NotifyCooldown.Duration > 0 {
+		entry.CooldownUntil = now.Add(config.NotifyCooldown.Duration)
+	}
+	return notifyRestock, ""
+}
+
+// Funzione per segnare come visti gli avvisi di uno store (e opzionalmente di un solo prodotto).
+// Ritorna il numero di voci riconosciute.
+func acknowledgeRestock(storeID string, productID string) (int, error) {
+	state, err := loadNotifyState()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, entry := range state {
+		if !strings.EqualFold(entry.StoreID, storeID) || !entry.Available {
+			continue
+		}
+		if productID != "" && entry.ProductID != productID {
+			continue
+		}
+		entry.Acknowledged = true
+		count++
+	}
+	if count == 0 {
+		return 0, nil
+	}
+	return count, saveNotifyState(state)
+}
+
+// Funzione per elencare le voci attualmente disponibili, ordinate per store e prodotto
+func availableEntries(state notifyState) []*notifyEntry {
+	var entries []*notifyEntry
+	for _, entry := range state {
+		if entry.Available {
+			entries = append(entries, entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].StoreID != entries[j].StoreID {
+			return entries[i].StoreID < entries[j].StoreID
+		}
+		return entries[i].ProductID < entries[j].ProductID
+	})
+	return entries
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// Riavvio con stato salvato: un prodotto già notificato e ancora disponibile non deve
+// essere considerato un nuovo restock né notificato di nuovo
+func TestNoDuplicateNotificationAfterRestart(t *testing.T) {
+	for _, format := range []string{stateFormatJSON, stateFormatGob} {
+		t.Run(format, func(t *testing.T) {
+			chdirTemp(t)
+			previousConfig, previousAvailability := config, lastAvailability
+			t.Cleanup(func() { config, lastAvailability = previousConfig, previousAvailability })
+			config.StateFormat = format
+			config.NotifyCooldown = Duration{time.Hour}
+
+			result := CheckResult{StoreID: "1234", ProductID: "P123456", Country: "IT", Available: true}
+			now := time.Date(2024, 5, 13, 10, 0, 0, 0, time.UTC)
+
+			// Prima esecuzione: il restock viene notificato e lo stato salvato
+			state := make(notifyState)
+			if action, _ := state.update(result, now); action != notifyRestock {
+				t.Fatalf("first check: action = %v, want a restock alert", action)
+			}
+			if err := saveNotifyState(state); err != nil {
+				t.Fatal(err)
+			}
+
+			// Riavvio: la memoria del processo è vuota
+			lastAvailability = make(map[string]bool)
+			restoreLastAvailability()
+			key := availabilityKey(result.StoreID, logFields{"country": result.Country, "product": result.ProductID})
+			if !lastAvailability[key] {
+				t.Errorf("availability of %s not restored: the check would report a new restock", key)
+			}
+
+			restored, err := loadNotifyState()
+			if err != nil {
+				t.Fatal(err)
+			}
+			action, reason := restored.update(result, now.Add(5*time.Minute))
+			if action != notifyNone {
+				t.Errorf("check after restart: action = %v, want no alert", action)
+			}
+			if reason != "cooldown" {
+				t.Errorf("check after restart: reason = %q, want cooldown", reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Server ntfy usato se non configurato diversamente
+const defaultNtfyServer = "https://ntfy.sh"
+
+// Notifiche push tramite ntfy (https://ntfy.sh), senza bisogno di un bot
+type NtfyConfig struct {
+	// Server ntfy (default https://ntfy.sh) e nome del topic; il topic può essere anche un url completo
+	Server string `json:"server"`
+	Topic  string `json:"topic"`
+	// Token di accesso per i topic protetti (opzionale)
+	Token string `json:"token"`
+	// Priorità degli avvisi di restock: min, low, default, high (default) o urgent
+	Priority string `json:"priority"`
+}
+
+func (n NtfyConfig) enabled() bool {
+	return strings.TrimSpace(n.Topic) != ""
+}
+
+// Url completo del topic
+func (n NtfyConfig) topicURL() string {
+	topic := strings.TrimSpace(n.Topic)
+	if strings.HasPrefix(topic, "http://") || strings.HasPrefix(topic, "https://") {
+		return topic
+	}
+	server := strings.TrimRight(strings.TrimSpace(n.Server), "/")
+	if server == "" {
+		server = defaultNtfyServer
+	}
+	return server + "/" + topic
+}
+
+func (n NtfyConfig) priority() string {
+	if n.Priority == "" {
+		return "high"
+	}
+	return n.Priority
+}
+
+// Funzione per validare topic e priorità configurati
+func (n NtfyConfig) validate() error {
+	if !n.enabled() {
+		return nil
+	}
+	if u, err := url.Parse(n.topicURL()); err != nil || u.Host == "" || strings.Trim(u.Path, "/") == "" {
+		return fmt.Errorf("invalid ntfy topic URL %q", n.topicURL())
+	}
+	switch n.Priority {
+	case "", "min", "low", "default", "high", "urgent":
+	default:
+		return fmt.Errorf("invalid ntfy priority %q: use min, low, default, high or urgent", n.Priority)
+	}
+	return nil
+}
+
+// Messaggio ntfy: il corpo è il testo della notifica, gli altri campi diventano header
+type ntfyMessage struct {
+	Title    string
+	Body     string
+	Priority string
+	Tags     []string
+	// Url aperto toccando la notifica (es. il link Google Maps dello store)
+	Click string
+}
+
+// Funzione per inviare una notifica semplice a un topic ntfy
+func sendNtfyNotification(topicURL string, message string) error {
+	return sendNtfyMessage(topicURL, ntfyMessage{
+		Title:    "Sephora Sniper",
+		Body:     message,
+		Priority: config.Ntfy.priority(),
+		Tags:     ntfyTags("shopping_bags"),
+	})
+}
+
+func sendNtfyMessage(topicURL string, msg ntfyMessage) error {
+	req, err := http.NewRequestWithContext(appCtx, "POST", topicURL, strings.NewReader(msg.Body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+
+	// Le notifiche usano il markdown di Discord (**grassetto**), supportato anche da ntfy
+	req.Header.Set("Markdown", "yes")
+	if msg.Title != "" {
+		req.Header.Set("Title", msg.Title)
+	}
+	if msg.Priority != "" {
+		req.Header.Set("Priority", msg.Priority)
+	}
+	if len(msg.Tags) > 0 {
+		req.Header.Set("Tags", strings.Join(msg.Tags, ","))
+	}
+	if msg.Click != "" {
+		req.Header.Set("Click", msg.Click)
+	}
+	if config.Ntfy.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+config.Ntfy.Token)
+	}
+
+	resp, err := doLimited(notifierClient(), req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("received non-200 response status: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func ntfyTarget() notifyTarget {
+	topicURL := config.Ntfy.topicURL()
+	return notifyTarget{
+		URL:      topicURL,
+		Channel:  ntfyChannel,
+		Notifier: "ntfy",
+		SendText: func(message string) error { return sendNtfyNotification(topicURL, message) },
+	}
+}
+
+// Funzione per inviare su ntfy l'avviso di un risultato, se configurato. L'invio passa da
+// dispatchTo come per Discord: durante la fascia silenziosa il corpo del messaggio finisce
+// nel riepilogo del topic.
+func dispatchNtfy(result CheckResult, msg ntfyMessage) error {
+	if !config.Ntfy.enabled() {
+		return fmt.Errorf("%s: %w (not configured)", ntfyChannel, errNotSent)
+	}
+	target := ntfyTarget()
+	return dispatchTo(target, msg.Body, result.fields().with("notifier", "ntfy"), func() error {
+		return sendNtfyMessage(target.URL, msg)
+	})
+}
+
+// Funzione per inviare su ntfy l'avviso di restock, con il nome dello store nel titolo
+// e il link Google Maps nel corpo
+func dispatchNtfyRestock(result CheckResult, message string, priority string) error {
+	return dispatchNtfy(result, ntfyRestockMessage(result, message, priority))
+}
+
+func ntfyRestockMessage(result CheckResult, message string, priority string) ntfyMessage {
+	link := mapsURL(result.Store)
+	if link != "" && !strings.Contains(message, link) {
+		message += "\nMap: " + link
+	}
+	return ntfyMessage{
+		Title:    fmt.Sprintf("In stock at %s", result.Name),
+		Body:     message,
+		Priority: priority,
+		Tags:     ntfyTags("shopping_bags", "tada"),
+		Click:    link,
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Nomi dei giorni usati negli orari dello store locator (italiano, francese, tedesco, inglese)
+// e abbreviazioni di schema.org (scheduleForJsonLD, es. "Mo-Sa 10:00-20:00")
+var weekdayNames = map[string]time.Weekday{
+	"lunedì": time.Monday, "lunedi": time.Monday, "lundi": time.Monday, "montag": time.Monday, "monday": time.Monday, "mo": time.Monday,
+	"martedì": time.Tuesday, "martedi": time.Tuesday, "mardi": time.Tuesday, "dienstag": time.Tuesday, "tuesday": time.Tuesday, "tu": time.Tuesday,
+	"mercoledì": time.Wednesday, "mercoledi": time.Wednesday, "mercredi": time.Wednesday, "mittwoch": time.Wednesday, "wednesday": time.Wednesday, "we": time.Wednesday,
+	"giovedì": time.Thursday, "giovedi": time.Thursday, "jeudi": time.Thursday, "donnerstag": time.Thursday, "thursday": time.Thursday, "th": time.Thursday,
+	"venerdì": time.Friday, "venerdi": time.Friday, "vendredi": time.Friday, "freitag": time.Friday, "friday": time.Friday, "fr": time.Friday,
+	"sabato": time.Saturday, "samedi": time.Saturday, "samstag": time.Saturday, "saturday": time.Saturday, "sa": time.Saturday,
+	"domenica": time.Sunday, "dimanche": time.Sunday, "sonntag": time.Sunday, "sunday": time.Sunday, "su": time.Sunday,
+}
+
+// Parole che indicano un giorno di chiusura
+var closedWords = []string{"closed", "fermé", "ferme", "chiuso", "geschlossen"}
+
+var (
+	// Fascia oraria: "10:00 - 20:00", "9h30-19h30", "10.00 – 13.00"
+	openingRangePattern = regexp.MustCompile(`(\d{1,2})(?:[:h.](\d{2}))?\s*[-–]\s*(\d{1,2})(?:[:h.](\d{2}))?`)
+	// Giorni di schema.org: "Mo-Fr", "Sa", "Mo,We"
+	jsonLDDaysPattern = regexp.MustCompile(`^([A-Za-z]{2})(?:-([A-Za-z]{2}))?$`)
+)
+
+// Fascia di apertura, in minuti dalla mezzanotte; end può superare 24h se la fascia
+// attraversa la mezzanotte
+type openingRange struct {
+	start, end int
+}
+
+// Funzione per leggere le fasce orarie di un testo come "10:00 - 13:00 / 14:00 - 19:00"
+func parseOpeningRanges(text string) []openingRange {
+	var ranges []openingRange
+	for _, match := range openingRangePattern.FindAllStringSubmatch(text, -1) {
+		start, ok1 := clockMinutes(match[1], match[2])
+		end, ok2 := clockMinutes(match[3], match[4])
+		if !ok1 || !ok2 {
+			continue
+		}
+		if end <= start {
+			end += 24 * 60
+		}
+		ranges = append(ranges, openingRange{start: start, end: end})
+	}
+	return ranges
+}
+
+func clockMinutes(hours string, minutes string) (int, bool) {
+	h, err := strconv.Atoi(hours)
+	if err != nil || h > 24 {
+		return 0, false
+	}
+	m := 0
+	if minutes != "" {
+		if m, err = strconv.Atoi(minutes); err != nil || m > 59 {
+			return 0, false
+		}
+	}
+	return h*60 + m, true
+}
+
+func isClosedText(text string) bool {
+	text = strings.ToLower(text)
+	for _, word := range closedWords {
+		if strings.Contains(text, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// Funzione per ricavare gli orari di un giorno: prima da "schedule", poi da "scheduleForJsonLD".
+// known è false se il giorno non compare o il testo non è interpretabile.
+func openingHours(store Location, day time.Weekday) (ranges []openingRange, known bool) {
+	for _, entry := range store.Schedule {
+		// Il giorno può essere seguito dalla data ("Lundi 12/05")
+		name := ""
+		if fields := strings.Fields(strings.ToLower(entry.Day)); len(fields) > 0 {
+			name = strings.Trim(fields[0], ".:,")
+		}
+		weekday, ok := weekdayNames[name]
+		if !ok || weekday != day {
+			continue
+		}
+		if ranges := parseOpeningRanges(entry.Time); len(ranges) > 0 {
+			return ranges, true
+		}
+		return nil, isClosedText(entry.Time)
+	}
+
+	for _, spec := range store.ScheduleForJsonLD {
+		days, hours, found := strings.Cut(strings.TrimSpace(spec), " ")
+		if !found || !jsonLDDaysInclude(days, day) {
+			continue
+		}
+		if ranges := parseOpeningRanges(hours); len(ranges) > 0 {
+			return ranges, true
+		}
+		return nil, isClosedText(hours)
+	}
+	return nil, false
+}
+
+// Funzione per verificare se un elenco di giorni di schema.org ("Mo-Fr,Su") comprende il giorno
+func jsonLDDaysInclude(days string, day time.Weekday) bool {
+	for _, part := range strings.Split(days, ",") {
+		match := jsonLDDaysPattern.FindStringSubmatch(strings.TrimSpace(part))
+		if match == nil {
+			continue
+		}
+		first, ok := weekdayNames[strings.ToLower(match[1])]
+		if !ok {
+			continue
+		}
+		last := first
+		if match[2] != "" {
+			if last, ok = weekdayNames[strings.ToLower(match[2])]; !ok {
+				continue
+			}
+		}
+		// Gli intervalli possono passare dalla domenica (es. "Sa-Mo")
+		for d := first; ; d = (d + 1) % 7 {
+			if d == day {
+				return true
+			}
+			if d == last {
+				break
+			}
+		}
+	}
+	return false
+}
+
+// Funzione per verificare se lo store è aperto all'ora indicata, nel fuso orario configurato.
+// Ritorna anche l'orario di chiusura della fascia in corso; known è false se gli orari mancano
+// o non sono interpretabili, e in quel caso lo store va considerato aperto.
+func storeOpenNow(store Location, now time.Time) (open bool, closes time.Time, known bool) {
+	now = now.In(configuredLocation())
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	minutes := now.Hour()*60 + now.Minute()
+
+	ranges, known := openingHours(store, now.Weekday())
+	for _, r := range ranges {
+		if minutes >= r.start && minutes < r.end {
+			return true, midnight.Add(time.Duration(r.end) * time.Minute), true
+		}
+	}
+	// Fascia del giorno prima che prosegue dopo la mezzanotte
+	previous, _ := openingHours(store, (now.Weekday()+6)%7)
+	for _, r := range previous {
+		if r.end > 24*60 && minutes < r.end-24*60 {
+			return true, midnight.Add(time.Duration(r.end-24*60) * time.Minute), true
+		}
+	}
+	return false, time.Time{}, known
+}
+
+// Funzione per verificare se lo store è sicuramente chiuso: con orari mancanti o ambigui
+// lo store viene considerato aperto, così l'avviso non va perso
+func storeClosedNow(store Location, now time.Time) bool {
+	open, _, known := storeOpenNow(store, now)
+	return known && !open
+}
+
+// Orario di chiusura dello store mostrato negli avvisi ("" se è chiuso o gli orari non sono noti)
+func closingTime(store Location, now time.Time) string {
+	open, closes, _ := storeOpenNow(store, now)
+	if !open {
+		return ""
+	}
+	return closes.Format("15:04")
+}
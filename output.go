@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/fatih/color"
+)
+
+// Output del terminale serializzato: le righe colorate, il log e la riga del countdown passano
+// tutti dallo stesso lock, così le scritture di goroutine diverse non si mescolano
+var (
+	outputMu sync.Mutex
+	// true se l'ultima scrittura è una riga aggiornata sul posto (countdown o stato compatto)
+	inPlaceLine bool
+	// Testo dell'ultima riga aggiornata sul posto
+	inPlaceText string
+)
+
+// Writer che prima di ogni scrittura chiude l'eventuale riga aggiornata sul posto
+type serializedWriter struct {
+	out io.Writer
+	// Riscrive la riga aggiornata sul posto dopo ogni scrittura, così le righe di log
+	// compaiono sopra la riga di stato invece di lasciarla cancellata fino al ciclo successivo
+	restore bool
+}
+
+func (w *serializedWriter) Write(p []byte) (int, error) {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	hadLine := inPlaceLine
+	breakInPlaceLine()
+	n, err := w.out.Write(p)
+	if w.restore && hadLine && isTerminal(os.Stdout) {
+		io.WriteString(os.Stdout, "\r\033[K"+inPlaceText)
+		inPlaceLine = true
+	}
+	return n, err
+}
+
+// Il log strutturato passa dallo stesso lock e riscrive la riga di stato (-compact)
+var stderrOutput io.Writer = &serializedWriter{out: os.Stderr, restore: true}
+
+func init() {
+	color.Output = &serializedWriter{out: color.Output}
+}
+
+// Standard output serializzato, per le stampe che possono avvenire mentre il countdown o la
+// riga di stato sono attivi
+var stdoutOutput io.Writer = &serializedWriter{out: os.Stdout}
+
+// Come fmt.Printf e fmt.Println, ma passando dal lock dell'output: da usare durante il
+// monitoraggio al posto delle stampe dirette su stdout
+func outputf(format string, args ...interface{}) {
+	fmt.Fprintf(stdoutOutput, format, args...)
+}
+
+func outputln(args ...interface{}) {
+	fmt.Fprintln(stdoutOutput, args...)
+}
+
+// Funzione per liberare la riga aggiornata sul posto prima di un'altra scrittura: su un
+// terminale la riga viene cancellata (verrà riscritta al prossimo aggiornamento), altrimenti
+// si va a capo. La riga è sempre su stdout, anche quando la scrittura successiva va su
+// stderr. Da chiamare con outputMu acquisito.
+func breakInPlaceLine() {
+	if !inPlaceLine {
+		return
+	}
+	inPlaceLine = false
+	if isTerminal(os.Stdout) {
+		io.WriteString(os.Stdout, "\r\033[K")
+	} else {
+		io.WriteString(os.Stdout, "\n")
+	}
+}
+
+// Funzione per riscrivere sul posto la riga del countdown o dello stato compatto
+func printInPlace(format string, args ...interface{}) {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	inPlaceText = fmt.Sprintf(format, args...)
+	io.WriteString(os.Stdout, "\r\033[K"+inPlaceText)
+	inPlaceLine = true
+}
+
+// Funzione per cancellare la riga aggiornata sul posto
+func clearInPlace() {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	breakInPlaceLine()
+}
+
+// Funzione per lasciare visibile la riga aggiornata sul posto e andare a capo
+func endInPlace() {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	if inPlaceLine {
+		inPlaceLine = false
+		fmt.Fprintln(os.Stdout)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Pausa di default tra due richieste consecutive a Sephora nello stesso ciclo
+const defaultRequestSpacing = 500 * time.Millisecond
+
+// Le richieste allo store locator, all'endpoint di stock e alle pagine categoria vengono
+// distanziate di request_spacing più un ritardo casuale fino a request_jitter, così un ciclo
+// con molti store o prodotti non invia richieste a raffica. Non influisce sull'intervallo tra i cicli.
+var (
+	requestPaceMu   sync.Mutex
+	requestNextSlot time.Time
+)
+
+// Funzione per attendere il turno della prossima richiesta; ritorna false se l'esecuzione
+// viene interrotta durante l'attesa
+func waitRequestSlot() bool {
+	spacing, jitter := config.RequestSpacing.Duration, config.RequestJitter.Duration
+	if spacing <= 0 && jitter <= 0 {
+		return true
+	}
+	if jitter > 0 {
+		spacing += time.Duration(rand.Int63n(int64(jitter)))
+	}
+
+	// Il turno viene prenotato subito, così richieste concorrenti restano comunque distanziate
+	requestPaceMu.Lock()
+	now := time.Now()
+	sendAt := requestNextSlot
+	if sendAt.Before(now) {
+		sendAt = now
+	}
+	requestNextSlot = sendAt.Add(spacing)
+	requestPaceMu.Unlock()
+
+	wait := time.Until(sendAt)
+	if wait <= 0 {
+		return true
+	}
+	logger.Debug("Request spaced out", logFields{"wait": wait.Round(time.Millisecond).String()})
+	select {
+	case <-time.After(wait):
+		return true
+	case <-appCtx.Done():
+		return false
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const yellowColor = "\033[33m"
+
+// Pausa del ciclo di controllo, attivata da tastiera (p + Invio durante il countdown) o dall'API
+// (POST /pause, POST /resume). In pausa non partono controlli né notifiche; stato, statistiche
+// e pianificazione restano in memoria e alla ripresa i paesi vengono controllati subito.
+type pauseControl struct {
+	mu      sync.Mutex
+	paused  bool
+	since   time.Time
+	changed chan struct{}
+}
+
+var monitorPause = &pauseControl{changed: make(chan struct{}, 1)}
+
+// Funzione per mettere in pausa o riprendere il monitoraggio; ritorna false se lo stato
+// era già quello richiesto
+func (p *pauseControl) set(paused bool, source string) bool {
+	p.mu.Lock()
+	if p.paused == paused {
+		p.mu.Unlock()
+		return false
+	}
+	p.paused = paused
+	p.since = time.Now()
+	p.mu.Unlock()
+
+	if paused {
+		logger.Info("Monitoring paused", logFields{"source": source})
+	} else {
+		logger.Info("Monitoring resumed", logFields{"source": source})
+	}
+	select {
+	case p.changed <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+func (p *pauseControl) state() (bool, time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.paused, p.since
+}
+
+// Canale notificato ad ogni pausa o ripresa, per interrompere l'attesa del countdown
+func (p *pauseControl) changes() <-chan struct{} {
+	return p.changed
+}
+
+// Funzione per leggere i comandi da tastiera mentre lo sniper è in esecuzione: "p" mette in
+// pausa o riprende, "r" riprende. Attiva solo se l'input è un terminale.
+func watchPauseKeys() {
+	if !isTerminal(os.Stdin) {
+		return
+	}
+	outputln("Type p and press Enter to pause monitoring.")
+	go func() {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			switch strings.ToLower(strings.TrimSpace(scanner.Text())) {
+			case "p", "pause":
+				paused, _ := monitorPause.state()
+				monitorPause.set(!paused, "keyboard")
+			case "r", "resume":
+				monitorPause.set(false, "keyboard")
+			}
+		}
+	}()
+}
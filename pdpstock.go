@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Sorgenti di disponibilità selezionabili con "availability_source"
+const (
+	sourceLocator = "locator"
+	sourcePDP     = "pdp"
+)
+
+// Chiavi JSON riconosciute come disponibilità nella risposta dell'endpoint di stock della
+// pagina prodotto, il cui formato non è documentato
+var pdpAvailabilityKeys = []string{"product_availability", "available", "isAvailable", "inStock", "in_stock"}
+
+// Funzione per costruire l'url dell'endpoint di stock sostituendo {pid}, {store} e {country}
+func pdpStockURL(template string, pid string, storeID string, country string) string {
+	return strings.NewReplacer(
+		"{pid}", pid,
+		"{store}", storeID,
+		"{country}", strings.ToLower(country),
+	).Replace(template)
+}
+
+// Funzione per interrogare l'endpoint di stock della pagina prodotto per uno store,
+// ripetendo la richiesta se la risposta arriva troncata
+func fetchPDPAvailability(pid string, storeID string, country string) (bool, error) {
+	var available bool
+	err := refetchIfTruncated(logFields{"store": storeID, "product": pid}, func() (err error) {
+		available, err = fetchPDPAvailabilityOnce(pid, storeID, country)
+		return err
+	})
+	return available, err
+}
+
+func fetchPDPAvailabilityOnce(pid string, storeID string, country string) (bool, error) {
+	replacer := strings.NewReplacer("{pid}", pid, "{store}", storeID, "{country}", strings.ToLower(country))
+	req, err := newEndpointRequest(config.PDPStockRequest, pdpStockURL(config.PDPStockEndpoint, pid, storeID, country), replacer)
+	if err != nil {
+		return false, fmt.Errorf("errore nel creare la richiesta: %w", err)
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/92.0.4515.131 Safari/537.36")
+	req.Header.Set("Accept", "application/json")
+	config.PDPStockRequest.applyHeaders(req)
+
+	if !waitRequestSlot() {
+		return false, appCtx.Err()
+	}
+	metrics.recordRequest()
+	resp, err := doLimited(httpClient(), req)
+	if err != nil {
+		return false, fmt.Errorf("errore nel fare la richiesta: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, &httpStatusError{StatusCode: resp.StatusCode}
+	}
+
+	body, err := readLimitedBody(resp.Body, logFields{"store": storeID, "product": pid})
+	if err != nil {
+		return false, fmt.Errorf("errore nel leggere il corpo della risposta: %w", err)
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		return false, newDecodeError(resp, body, err)
+	}
+
+	available, found := findAvailabilityFlag(decoded)
+	if !found {
+		return false, fmt.Errorf("no availability field found in PDP stock response")
+	}
+	return available, nil
+}
+
+// Funzione per cercare il booleano di disponibilità nella risposta. La ricerca procede per
+// livelli (vince il campo meno annidato), a ogni livello le chiavi vengono provate nell'ordine
+// di pdpAvailabilityKeys e gli oggetti annidati in ordine alfabetico di chiave, così con più
+// campi candidati il risultato non dipende dall'ordine casuale delle mappe
+func findAvailabilityFlag(value interface{}) (bool, bool) {
+	level := []interface{}{value}
+	for len(level) > 0 {
+		var next []interface{}
+		for _, node := range level {
+			switch v := node.(type) {
+			case map[string]interface{}:
+				for _, key := range pdpAvailabilityKeys {
+					if flag, ok := v[key].(bool); ok {
+						return flag, true
+					}
+				}
+				keys := make([]string, 0, len(v))
+				for key := range v {
+					keys = append(keys, key)
+				}
+				sort.Strings(keys)
+				for _, key := range keys {
+					next = append(next, v[key])
+				}
+			case []interface{}:
+				next = append(next, v...)
+			}
+		}
+		level = next
+	}
+	return false, false
+}
+
+// Funzione per confrontare la disponibilità del locator con quella della pagina prodotto.
+// Ritorna il valore della sorgente scelta come autorevole; in caso di errore usa il locator.
+func reconcilePDPAvailability(locatorAvailable bool, pid string, storeID string, country string, fields logFields) bool {
+	if config.PDPStockEndpoint == "" {
+		return locatorAvailable
+	}
+
+	pdpAvailable, err := fetchPDPAvailability(pid, storeID, country)
+	if err != nil {
+		logger.Warn("PDP stock check failed, using locator availability", errorFields(fields, err))
+		return locatorAvailable
+	}
+
+	if pdpAvailable != locatorAvailable {
+		logger.Warn("Locator and PDP stock disagree", fields.with("locator_available", locatorAvailable, "pdp_available", pdpAvailable, "source", config.AvailabilitySource))
+	}
+
+	if config.AvailabilitySource == sourcePDP {
+		return pdpAvailable
+	}
+	return locatorAvailable
+}
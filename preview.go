@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// Store di esempio usato dall'anteprima quando non viene indicato uno Store ID
+func sampleStore(country string) Location {
+	return Location{
+		ID:                    country + "SAMPLE",
+		Name:                  "Sephora Sample Store",
+		City:                  "SAMPLE CITY",
+		Address1:              "1 Sample Street",
+		Postal:                "00000",
+		CountryCode:           country,
+		Latitude:              45.4642,
+		Longitude:             9.19,
+		EnableClickCollect:    true,
+		EnableDeliveryToStore: true,
+		StoreServices:         []StoreService{{ID: "click-collect", Name: "Click & Collect"}},
+	}
+}
+
+// Funzione per mostrare la notifica di restock che verrebbe inviata per uno store, senza inviarla:
+// il messaggio come appare, i payload JSON di Discord e la richiesta ntfy, se configurato.
+// Senza storeID viene usato uno store di esempio, altrimenti i dati reali dello store.
+func previewNotification(storeID string, productID string) error {
+	country, err := readCountrySelection()
+	if err != nil {
+		country = "IT"
+	}
+	country = strings.TrimSpace(country)
+	if productID == "" {
+		productID = defaultProductID
+	}
+
+	store := sampleStore(country)
+	if storeID != "" {
+		if !validStoreID(storeID) {
+			return fmt.Errorf("invalid store ID %q", storeID)
+		}
+		placeholder := sampleStore(country)
+		placeholder.ID = storeID
+		store = lookupStore(country, placeholder)
+	}
+	result := restockResult(store, productID, country)
+
+	message, err := restockMessage(result)
+	if err != nil {
+		return fmt.Errorf("message template: %v", err)
+	}
+
+	webhookurl, _ := readWebhookURL()
+	fmt.Printf("Store %s (%s), product %s\n", result.StoreID, result.Name, result.ProductID)
+	fmt.Printf("Discord channel: %s\n\n", discordChannel(webhookFor(result, webhookurl)))
+
+	color.Magenta("--- Message preview ---")
+	fmt.Println(message)
+
+	fmt.Println()
+	color.Magenta("--- Discord payload ---")
+	chunks := splitDiscordMessage(message, discordMessageLimit)
+	if len(chunks) > 1 {
+		fmt.Printf("The message is split into %d webhook requests.\n", len(chunks))
+	}
+	for _, chunk := range chunks {
+		if err := printPreviewJSON(DiscordWebhookPayload{Content: chunk}); err != nil {
+			return err
+		}
+	}
+	if discordAckEnabled() {
+		fmt.Printf("The bot then adds the %s reaction to the last message.\n", ackReaction())
+	}
+
+	if config.Ntfy.enabled() {
+		msg := ntfyRestockMessage(result, message, config.Ntfy.priority())
+		fmt.Println()
+		color.Magenta("--- ntfy request ---")
+		fmt.Printf("POST %s\n", config.Ntfy.topicURL())
+		fmt.Printf("Title: %s\nPriority: %s\nTags: %s\n", msg.Title, msg.Priority, strings.Join(msg.Tags, ","))
+		if msg.Click != "" {
+			fmt.Printf("Click: %s\n", msg.Click)
+		}
+		fmt.Printf("\n%s\n", msg.Body)
+	}
+
+	fmt.Println("\nNothing was sent.")
+	return nil
+}
+
+func printPreviewJSON(payload interface{}) error {
+	content, err := json.MarshalIndent(payload, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(content))
+	return nil
+}
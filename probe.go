@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Header il cui valore viene nascosto nell'output di -probe, da poter condividere nei bug report
+var probeRedactedHeaders = map[string]bool{
+	"Set-Cookie":    true,
+	"Cookie":        true,
+	"Authorization": true,
+}
+
+// Funzione per interrogare un url dello store locator con il client configurato e stampare
+// stato HTTP, header e la risposta decodificata (o il JSON grezzo se la decodifica fallisce).
+// Ritorna il codice di uscita.
+func probeEndpoint(endpoint_url string, out io.Writer) int {
+	req, err := newEndpointRequest(config.LocatorRequest, endpoint_url, locatorReplacer(endpoint_url, nil))
+	if err != nil {
+		fmt.Fprintf(out, "Invalid request: %v\n", err)
+		return exitConfigError
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/92.0.4515.131 Safari/537.36")
+	config.LocatorRequest.applyHeaders(req)
+
+	resp, err := doLimited(httpClient(), req)
+	if err != nil {
+		fmt.Fprintf(out, "Request failed: %v\n", err)
+		return exitNetworkError
+	}
+	defer resp.Body.Close()
+
+	fmt.Fprintf(out, "%s %s\n", req.Method, endpoint_url)
+	fmt.Fprintf(out, "%s %s\n\n", resp.Proto, resp.Status)
+	printProbeHeaders(out, resp.Header)
+
+	body, err := readLimitedBody(resp.Body, endpointFields(endpoint_url))
+	if err != nil {
+		fmt.Fprintf(out, "\nFailed to read the body: %v\n", err)
+		return exitNetworkError
+	}
+	fmt.Fprintln(out)
+
+	var decoded StoreResponse
+	if decodeErr := json.Unmarshal(body, &decoded); decodeErr == nil {
+		pretty, err := json.MarshalIndent(decoded, "", "  ")
+		if err == nil {
+			fmt.Fprintf(out, "Decoded StoreResponse (%d stores):\n%s\n", len(decoded.Locations), pretty)
+			return exitCodeForStatus(resp.StatusCode)
+		}
+	} else {
+		fmt.Fprintf(out, "Decoding as StoreResponse failed: %v\n", decodeErr)
+	}
+
+	// Risposta non decodificabile: JSON grezzo indentato se possibile, altrimenti il corpo così com'è
+	var indented bytes.Buffer
+	if json.Indent(&indented, body, "", "  ") == nil {
+		fmt.Fprintf(out, "Raw JSON:\n%s\n", indented.String())
+	} else {
+		fmt.Fprintf(out, "Raw body (%d bytes):\n%s\n", len(body), body)
+	}
+	return exitCodeForStatus(resp.StatusCode)
+}
+
+func printProbeHeaders(out io.Writer, header http.Header) {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := strings.Join(header[name], ", ")
+		if probeRedactedHeaders[name] {
+			value = "[redacted]"
+		}
+		fmt.Fprintf(out, "%s: %s\n", name, value)
+	}
+}
+
+func exitCodeForStatus(status int) int {
+	if status != http.StatusOK {
+		return exitNetworkError
+	}
+	return 0
+}
+
+// Funzione per eseguire -probe e terminare il programma
+func runProbe(endpoint_url string) {
+	os.Exit(probeEndpoint(endpoint_url, os.Stdout))
+}
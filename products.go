@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"time"
+)
+
+// Prodotto monitorato di default, quello presente negli url degli endpoint
+const defaultProductID = "735577"
+
+// Numero massimo di prodotti monitorati se non configurato diversamente
+const defaultMaxProducts = 20
+
+// Le pagine categoria di Demandware riportano il PID di ogni prodotto nell'attributo data-pid
+var categoryPIDPattern = regexp.MustCompile(`data-pid="([^"]+)"`)
+
+// Lista dei prodotti monitorati: quelli configurati più quelli delle categorie osservate,
+// con le categorie aggiornate periodicamente
+type productWatchList struct {
+	categoryPIDs []string
+	refreshed    time.Time
+
+	// PID delle varianti configurate e varianti trovate per ogni pagina prodotto
+	variantPIDs      []string
+	pageVariants     map[string][]ProductVariant
+	variantsResolved time.Time
+
+	// Numero di prodotti per cui è stato registrato l'ultimo avviso max_products, così
+	// l'avviso compare una volta per ogni cambio e non a ogni ciclo
+	excessWarned int
+}
+
+// Funzione per ottenere l'url dell'endpoint per un prodotto specifico
+func endpointForProduct(endpoint_url string, pid string) string {
+	u, err := url.Parse(endpoint_url)
+	if err != nil {
+		return endpoint_url
+	}
+	query := u.Query()
+	query.Set("pid", pid)
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// Funzione per ottenere la lista aggiornata dei prodotti da controllare
+func (w *productWatchList) products() []string {
+	if len(config.Categories) > 0 && time.Since(w.refreshed) >= w.refreshInterval() {
+		w.refreshCategories()
+	}
+	if len(config.Variants) > 0 && time.Since(w.variantsResolved) >= w.refreshInterval() {
+		w.variantsResolved = time.Now()
+		w.refreshVariants()
+	}
+
+	configured := config.Products
+	if len(configured) == 0 && len(w.categoryPIDs) == 0 && len(w.variantPIDs) == 0 {
+		configured = []string{defaultProductID}
+	}
+
+	seen := make(map[string]bool)
+	var products []string
+	for _, pid := range append(append(append([]string{}, configured...), w.variantPIDs...), w.categoryPIDs...) {
+		if pid == "" || seen[pid] {
+			continue
+		}
+		seen[pid] = true
+		products = append(products, pid)
+	}
+	// Prodotti già trovati in modalità first_available_wins
+	products = activeProducts(products)
+
+	maxProducts := config.MaxProducts
+	if maxProducts <= 0 {
+		maxProducts = defaultMaxProducts
+	}
+	if len(products) > maxProducts {
+		if w.excessWarned != len(products) {
+			w.excessWarned = len(products)
+			logger.Warn("Too many products to watch, ignoring the excess", logFields{"products": len(products), "max_products": maxProducts})
+		}
+		products = products[:maxProducts]
+	} else {
+		w.excessWarned = 0
+	}
+	return products
+}
+
+func (w *productWatchList) refreshInterval() time.Duration {
+	if config.CategoryRefresh.Duration > 0 {
+		return config.CategoryRefresh.Duration
+	}
+	return 6 * time.Hour
+}
+
+// Funzione per aggiornare i PID delle categorie; in caso di errore si mantiene la lista precedente
+func (w *productWatchList) refreshCategories() {
+	w.refreshed = time.Now()
+
+	seen := make(map[string]bool)
+	var pids []string
+	for _, categoryURL := range config.Categories {
+		categoryPIDs, err := resolveCategoryPIDs(categoryURL)
+		if err != nil {
+			logger.Warn("Failed to resolve category products", logFields{"category": categoryURL, "error": err})
+			return
+		}
+		for _, pid := range categoryPIDs {
+			if !seen[pid] {
+				seen[pid] = true
+				pids = append(pids, pid)
+			}
+		}
+	}
+
+	if len(pids) != len(w.categoryPIDs) {
+		logger.Info("Category products refreshed", logFields{"categories": len(config.Categories), "products": len(pids)})
+	}
+	w.categoryPIDs = pids
+}
+
+// Funzione per ricavare i PID dei prodotti elencati in una pagina categoria
+func resolveCategoryPIDs(categoryURL string) ([]string, error) {
+	body, err := fetchPage(categoryURL, logFields{"category": categoryURL})
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var pids []string
+	for _, match := range categoryPIDPattern.FindAllSubmatch(body, -1) {
+		pid := string(match[1])
+		if !seen[pid] {
+			seen[pid] = true
+			pids = append(pids, pid)
+		}
+	}
+	if len(pids) == 0 {
+		return nil, fmt.Errorf("no products found in category page")
+	}
+	return pids, nil
+}
+
+// Funzione per scaricare una pagina del sito (categoria o prodotto)
+func fetchPage(pageURL string, fields logFields) ([]byte, error) {
+	client := httpClient()
+
+	req, err := http.NewRequestWithContext(appCtx, "GET", pageURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("errore nel creare la richiesta: %w", err)
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/92.0.4515.131 Safari/537.36")
+
+	if !waitRequestSlot() {
+		return nil, appCtx.Err()
+	}
+	metrics.recordRequest()
+	resp, err := doLimited(client, req)
+	if err != nil {
+		return nil, fmt.Errorf("errore nel fare la richiesta: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &httpStatusError{StatusCode: resp.StatusCode}
+	}
+
+	body, err := readLimitedBody(resp.Body, fields)
+	if err != nil {
+		return nil, fmt.Errorf("errore nel leggere il corpo della risposta: %w", err)
+	}
+	return body, nil
+}
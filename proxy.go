@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Blocchi consecutivi dopo i quali un proxy viene messo da parte, e per quanto tempo
+const (
+	proxyMaxConsecutiveBlocks = 3
+	proxyBenchTime            = 15 * time.Minute
+)
+
+// Un proxy del pool con le statistiche dell'esecuzione corrente
+type proxyEntry struct {
+	URL               *url.URL
+	Successes         int
+	Blocks            int
+	Failures          int
+	consecutiveBlocks int
+	benchedUntil      time.Time
+}
+
+// Punteggio di salute: quota di richieste riuscite, con una stima neutra per i proxy mai usati
+func (p *proxyEntry) score() float64 {
+	return float64(p.Successes+1) / float64(p.Successes+p.Blocks+p.Failures+2)
+}
+
+// Pool di proxy per le richieste allo store locator: ogni richiesta usa il proxy più sano e,
+// se viene bloccata, viene ripetuta subito con un altro proxy
+type proxyPool struct {
+	mu      sync.Mutex
+	entries []*proxyEntry
+}
+
+// Pool costruito dalla configurazione insieme al client HTTP; nil = nessun proxy
+var proxies *proxyPool
+
+// Chiave di contesto con cui una richiesta indica il proxy da usare
+type proxyCtxKey struct{}
+
+// Funzione per validare gli url dei proxy configurati
+func validateProxies(list []string) error {
+	for _, raw := range list {
+		u, err := url.Parse(raw)
+		if err != nil || u.Host == "" {
+			return fmt.Errorf("invalid proxy %q", raw)
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return fmt.Errorf("invalid proxy %q: use an http, https or socks5 URL", raw)
+		}
+	}
+	return nil
+}
+
+func newProxyPool(list []string) *proxyPool {
+	if len(list) == 0 {
+		return nil
+	}
+	pool := &proxyPool{}
+	for _, raw := range list {
+		u, err := url.Parse(raw)
+		if err != nil {
+			continue
+		}
+		pool.entries = append(pool.entries, &proxyEntry{URL: u})
+	}
+	return pool
+}
+
+func (p *proxyPool) size() int {
+	if p == nil {
+		return 0
+	}
+	return len(p.entries)
+}
+
+// Funzione per scegliere il proxy più sano tra quelli non ancora provati per questa richiesta.
+// I proxy messi da parte vengono usati solo se non ce ne sono altri.
+func (p *proxyPool) pick(tried map[*proxyEntry]bool) *proxyEntry {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	var candidates []*proxyEntry
+	for _, entry := range p.entries {
+		if !tried[entry] {
+			candidates = append(candidates, entry)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		benchedI, benchedJ := now.Before(candidates[i].benchedUntil), now.Before(candidates[j].benchedUntil)
+		if benchedI != benchedJ {
+			return !benchedI
+		}
+		if benchedI {
+			return candidates[i].benchedUntil.Before(candidates[j].benchedUntil)
+		}
+		return candidates[i].score() > candidates[j].score()
+	})
+	return candidates[0]
+}
+
+// Funzione per registrare l'esito di una richiesta fatta tramite un proxy
+func (p *proxyPool) report(entry *proxyEntry, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	switch {
+	case err == nil:
+		entry.Successes++
+		entry.consecutiveBlocks = 0
+	case isBlockError(err):
+		entry.Blocks++
+		entry.consecutiveBlocks++
+		if entry.consecutiveBlocks >= proxyMaxConsecutiveBlocks {
+			entry.benchedUntil = time.Now().Add(proxyBenchTime)
+			entry.consecutiveBlocks = 0
+			logger.Warn("Proxy blocked repeatedly, rotating away from it", logFields{"proxy": entry.URL.Redacted(), "until": formatTimestamp(entry.benchedUntil)})
+		}
+	default:
+		entry.Failures++
+	}
+}
+
+// Funzione per riepilogare la salute dei proxy a fine esecuzione
+func (p *proxyPool) logSummary() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, entry := range p.entries {
+		logger.Info("Proxy statistics", logFields{
+			"proxy":     entry.URL.Redacted(),
+			"successes": entry.Successes,
+			"blocks":    entry.Blocks,
+			"failures":  entry.Failures,
+			"score":     fmt.Sprintf("%.2f", entry.score()),
+		})
+	}
+}
+
+// Funzione usata come Proxy del Transport: il proxy scelto viaggia nel contesto della richiesta;
+// le altre richieste (conferme, elenco store) usano il proxy più sano del pool
+func proxyFromContext(req *http.Request) (*url.URL, error) {
+	if entry, ok := req.Context().Value(proxyCtxKey{}).(*proxyEntry); ok {
+		return entry.URL, nil
+	}
+	if proxies.size() > 0 {
+		return proxies.pick(nil).URL, nil
+	}
+	return nil, nil
+}
+
+func withProxy(req *http.Request, entry *proxyEntry) *http.Request {
+	if entry == nil {
+		return req
+	}
+	return req.WithContext(context.WithValue(req.Context(), proxyCtxKey{}, entry))
+}
+
+// Funzione per riconoscere un blocco anti-bot: challenge, 403 o 429
+func isBlockError(err error) bool {
+	if errors.Is(err, errAntiBotChallenge) {
+		return true
+	}
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusForbidden || statusErr.StatusCode == http.StatusTooManyRequests
+	}
+	return false
+}
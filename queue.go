@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Coda di monitoraggio per molti prodotti: invece di inviare tutte le richieste ad ogni
+// intervallo, i controlli vengono divisi in lotti distribuiti nell'arco dell'intervallo.
+// Una richiesta allo store locator restituisce già tutti gli store, quindi i lotti sono fatti
+// di richieste (prodotto e tipo di ritiro), non di store.
+type MonitorQueue struct {
+	// Richieste per lotto (0 = coda disattivata, tutte le richieste ad ogni controllo)
+	BatchSize int `json:"batch_size"`
+	// Finestra in cui distribuire i lotti; vuoto o più lungo dell'intervallo = l'intervallo intero
+	Spread Duration `json:"spread"`
+}
+
+func (q MonitorQueue) enabled() bool {
+	return q.BatchSize > 0
+}
+
+// Funzione per validare la configurazione della coda
+func (q MonitorQueue) validate() error {
+	if q.BatchSize < 0 {
+		return fmt.Errorf("monitor_queue batch_size must not be negative")
+	}
+	if q.Spread.Duration < 0 {
+		return fmt.Errorf("monitor_queue spread must not be negative")
+	}
+	return nil
+}
+
+// Un controllo della coda: un url (prodotto e tipo di ritiro) per tutti gli store del paese
+type queueUnit struct {
+	URL      string
+	StoreIDs []string
+}
+
+// Funzione per elencare i controlli di un paese, uno per url: ogni richiesta copre tutti gli
+// store, perché dividerli in lotti ripeterebbe la stessa richiesta allo store locator
+func queueUnits(urls []string, storeIDs []string) []queueUnit {
+	units := make([]queueUnit, 0, len(urls))
+	for _, u := range urls {
+		units = append(units, queueUnit{URL: u, StoreIDs: storeIDs})
+	}
+	return units
+}
+
+// Funzione per scegliere i controlli del prossimo turno. Senza coda si controlla tutto;
+// con la coda attiva un lotto di al massimo size controlli, proseguendo da dove si era rimasti.
+func (c *countrySchedule) nextUnits(units []queueUnit, size int, queued bool) []queueUnit {
+	if !queued || size <= 0 || len(units) <= size {
+		c.QueuePos, c.QueueLen = 0, 0
+		return units
+	}
+	batches := (len(units) + size - 1) / size
+	// La lista può cambiare tra un turno e l'altro (ricarica, nuovi prodotti): si riparte da capo
+	if c.QueuePos >= batches || c.QueueLen != batches {
+		c.QueuePos = 0
+	}
+	if c.QueuePos == 0 {
+		c.PassStart = time.Now()
+	}
+	c.QueueLen = batches
+	start := c.QueuePos * size
+	end := start + size
+	if end > len(units) {
+		end = len(units)
+	}
+	c.QueuePos++
+	return units[start:end]
+}
+
+// Funzione per calcolare il prossimo controllo: con la coda i lotti sono distribuiti in modo
+// uniforme nella finestra, e il giro successivo parte un intervallo dopo l'inizio di questo,
+// così ogni store viene controllato almeno una volta per intervallo
+func (c *countrySchedule) scheduleNext() {
+	interval := c.effectiveInterval()
+	if c.QueueLen == 0 || c.QueuePos >= c.QueueLen {
+		if c.QueueLen == 0 {
+			c.NextCheck = time.Now().Add(interval)
+		} else {
+			c.NextCheck = c.PassStart.Add(interval)
+		}
+		return
+	}
+
+	window := config.MonitorQueue.Spread.Duration
+	if window <= 0 || window > interval {
+		window = interval
+	}
+	c.NextCheck = c.PassStart.Add(window * time.Duration(c.QueuePos) / time.Duration(c.QueueLen))
+}
+
+// Descrizione del lotto appena controllato, per l'output del ciclo
+func (c *countrySchedule) batchLabel() string {
+	if c.QueueLen == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (batch %d/%d)", c.QueuePos, c.QueueLen)
+}
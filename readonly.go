@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// Variabili d'ambiente che sostituiscono i file di configurazione, utili quando la cartella
+// di lavoro non è scrivibile (es. container con filesystem in sola lettura)
+const (
+	envStoreIDs = "SEPHORA_STORE_IDS"
+	envInterval = "SEPHORA_INTERVAL"
+	envCountry  = "SEPHORA_COUNTRY"
+	envWebhook  = "SEPHORA_WEBHOOK_URL"
+)
+
+// Modalità in sola lettura: il monitoraggio continua ma configurazione e stato non vengono salvati
+var readOnlyMode bool
+
+var errReadOnly = errors.New("working directory is read-only, changes are kept in memory only")
+
+// Funzione per verificare all'avvio se la cartella di lavoro è scrivibile, creando un file temporaneo
+func detectReadOnly() bool {
+	file, err := os.CreateTemp(".", ".sephora-write-test-*")
+	if err != nil {
+		return true
+	}
+	name := file.Name()
+	file.Close()
+	os.Remove(name)
+	return false
+}
+
+// Funzione per attivare la modalità in sola lettura se la cartella di lavoro non è scrivibile
+func enableReadOnlyIfNeeded() {
+	if !detectReadOnly() {
+		return
+	}
+	readOnlyMode = true
+	notifyStateReadOnly = true
+
+	wd, _ := os.Getwd()
+	logger.Warn("Working directory is not writable: running in read-only mode", logFields{"dir": wd})
+	color.Yellow("Warning: %s is not writable. Monitoring works, but settings, notification state, statistics and cookies will not be saved.", wd)
+	color.Yellow("Provide the configuration with %s, %s, %s and %s, or a read-only config.json.", envCountry, envStoreIDs, envInterval, envWebhook)
+}
+
+// Funzione per gestire un errore di scrittura dal menu: in sola lettura la modifica resta
+// in memoria per la sessione corrente, altrimenti il programma termina come prima
+func persistFailed(format string, err error) {
+	if readOnlyMode || errors.Is(err, errReadOnly) {
+		color.Yellow("Warning: %v", errReadOnly)
+		return
+	}
+	fatalError(format, err)
+}
+
+// Funzione per leggere gli Store ID da SEPHORA_STORE_IDS (separati da virgola), se impostata
+func envStoreIDList() ([]string, bool) {
+	value := strings.TrimSpace(os.Getenv(envStoreIDs))
+	if value == "" {
+		return nil, false
+	}
+	var ids []string
+	for _, id := range strings.Split(value, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids, true
+}
+
+// Funzione per leggere l'intervallo da SEPHORA_INTERVAL: ore intere oppure una durata ("90m")
+func envCheckInterval() (time.Duration, bool, error) {
+	value := strings.TrimSpace(os.Getenv(envInterval))
+	if value == "" {
+		return 0, false, nil
+	}
+	interval, err := parseInterval(value)
+	return interval, true, err
+}
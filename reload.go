@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Impostazioni usate dal ciclo dello sniper, ricaricabili a caldo con SIGHUP
+type runtimeSettings struct {
+	StoreIDs []string
+	Interval time.Duration
+	Webhook  string
+	Country  string
+	Config   Config
+}
+
+// Funzione per rileggere tutti i file di configurazione. Ritorna un errore (senza modificare
+// nulla) se uno dei file è illeggibile o non valido, così una ricarica parziale non viene applicata.
+func loadRuntimeSettings() (runtimeSettings, error) {
+	var settings runtimeSettings
+	var err error
+
+	if settings.StoreIDs, err = readStoreIDs(); err != nil {
+		return settings, fmt.Errorf("store IDs: %v", err)
+	}
+	for _, id := range settings.StoreIDs {
+		if !validStoreID(id) {
+			return settings, fmt.Errorf("store IDs: invalid ID %q", id)
+		}
+	}
+
+	if settings.Interval, err = readCheckInterval(); err != nil {
+		return settings, fmt.Errorf("check interval: %v", err)
+	}
+
+	if settings.Webhook, err = readWebhookURL(); err != nil && !os.IsNotExist(err) {
+		return settings, fmt.Errorf("webhook URL: %v", err)
+	}
+	if settings.Webhook != "" {
+		if err := validateWebhookURL(settings.Webhook); err != nil {
+			return settings, err
+		}
+	}
+
+	if settings.Country, err = readCountrySelection(); err != nil {
+		return settings, fmt.Errorf("country: %v", err)
+	}
+	if settings.Country != "IT" && settings.Country != "DE" && settings.Country != "FR" {
+		return settings, fmt.Errorf("country: invalid selection %q", settings.Country)
+	}
+
+	if settings.Config, err = loadConfig(); err != nil {
+		return settings, fmt.Errorf("config: %v", err)
+	}
+
+	return settings, nil
+}
+
+// Funzione per descrivere le differenze tra due configurazioni, per il log della ricarica
+func describeSettingsChanges(old runtimeSettings, updated runtimeSettings) []string {
+	var changes []string
+	if strings.Join(old.StoreIDs, ",") != strings.Join(updated.StoreIDs, ",") {
+		changes = append(changes, fmt.Sprintf("store IDs: %v -> %v", old.StoreIDs, updated.StoreIDs))
+	}
+	if old.Interval != updated.Interval {
+		changes = append(changes, fmt.Sprintf("interval: %v -> %v", old.Interval, updated.Interval))
+	}
+	if old.Webhook != updated.Webhook {
+		changes = append(changes, "webhook URL changed")
+	}
+	if old.Country != updated.Country {
+		changes = append(changes, fmt.Sprintf("country: %s -> %s", old.Country, updated.Country))
+	}
+	if fmt.Sprintf("%+v", old.Config) != fmt.Sprintf("%+v", updated.Config) {
+		changes = append(changes, configFile+" changed")
+	}
+	return changes
+}
+
+// Funzione per ricostruire la pianificazione dei paesi mantenendo i tempi dei paesi già monitorati
+func rescheduleCountries(previous []*countrySchedule, settings runtimeSettings) []*countrySchedule {
+	byCountry := make(map[string]*countrySchedule, len(previous))
+	for _, schedule := range previous {
+		byCountry[schedule.Country] = schedule
+	}
+
+	schedules := monitoredCountries(settings.Country, settings.Interval)
+	for _, schedule := range schedules {
+		if old, ok := byCountry[schedule.Country]; ok {
+			schedule.Backoff = old.Backoff
+			// Se l'intervallo è cambiato il prossimo controllo viene ricalcolato dall'ultimo
+			schedule.NextCheck = old.NextCheck.Add(schedule.Interval - old.Interval)
+		}
+	}
+	return schedules
+}
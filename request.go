@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Metodo, header e corpo JSON di una richiesta verso un endpoint, configurabili nel caso in cui
+// Sephora sposti i controlli di disponibilità su richieste POST. Vuoto = GET senza corpo.
+type RequestSpec struct {
+	Method  string            `json:"method"`
+	Headers map[string]string `json:"headers"`
+	// Corpo JSON inviato così com'è, con i segnaposto {pid}, {store} e {country} sostituiti
+	Body json.RawMessage `json:"body,omitempty"`
+}
+
+// Funzione per verificare se è configurato un corpo; "body": null equivale a nessun corpo
+func (s RequestSpec) hasBody() bool {
+	body := bytes.TrimSpace(s.Body)
+	return len(body) > 0 && string(body) != "null"
+}
+
+func (s RequestSpec) method() string {
+	if s.Method == "" {
+		return http.MethodGet
+	}
+	return strings.ToUpper(s.Method)
+}
+
+// Funzione per validare metodo e corpo della richiesta; name è la chiave in config.json
+func (s RequestSpec) validate(name string) error {
+	switch s.method() {
+	case http.MethodGet, http.MethodPost, http.MethodPut:
+	default:
+		return fmt.Errorf("invalid %s.method %q: use GET, POST or PUT", name, s.Method)
+	}
+	if !s.hasBody() {
+		return nil
+	}
+	if s.method() == http.MethodGet {
+		return fmt.Errorf("%s.body requires method POST or PUT", name)
+	}
+	if !json.Valid(s.Body) {
+		return fmt.Errorf("%s.body is not valid JSON", name)
+	}
+	return nil
+}
+
+// Funzione per creare la richiesta verso un endpoint secondo la configurazione.
+// I segnaposto del corpo vengono sostituiti con replacer (può essere nil).
+func newEndpointRequest(spec RequestSpec, endpoint string, replacer *strings.Replacer) (*http.Request, error) {
+	var body io.Reader
+	if spec.hasBody() {
+		content := string(spec.Body)
+		if replacer != nil {
+			content = replacer.Replace(content)
+		}
+		body = bytes.NewBufferString(content)
+	}
+
+	req, err := http.NewRequestWithContext(appCtx, spec.method(), endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return req, nil
+}
+
+// Funzione per applicare gli header configurati, che hanno la precedenza su quelli impostati dal
+// chiamante (headers.json resta comunque prioritario)
+func (s RequestSpec) applyHeaders(req *http.Request) {
+	for name, value := range s.Headers {
+		req.Header.Set(name, value)
+	}
+}
+
+// Segnaposto per il corpo delle richieste allo store locator, ricavati dall'url dell'endpoint
+func locatorReplacer(endpoint_url string, storeIDs []string) *strings.Replacer {
+	fields := endpointFields(endpoint_url)
+	country, _ := fields["country"].(string)
+	product, _ := fields["product"].(string)
+	return strings.NewReplacer(
+		"{pid}", product,
+		"{store}", strings.Join(storeIDs, ","),
+		"{country}", strings.ToLower(country),
+	)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"strings"
+	"sync"
+)
+
+// Paesi per cui è già stato segnalato un cambio di formato, per non ripetere l'avviso ad ogni ciclo
+var schemaWarned sync.Map
+
+// Funzione per verificare che la risposta contenga ancora i campi su cui si basa lo sniper.
+// Se nessuno store ha ID, città, nome o indirizzo valorizzati, probabilmente l'API di Sephora
+// ha cambiato formato e i controlli di disponibilità non sono più affidabili.
+func checkResponseSchema(response StoreResponse, fields logFields) {
+	if len(response.Locations) == 0 {
+		return
+	}
+
+	present := map[string]bool{}
+	for _, store := range response.Locations {
+		present["id"] = present["id"] || store.ID != ""
+		present["city"] = present["city"] || store.City != ""
+		present["name"] = present["name"] || store.Name != ""
+		present["address1"] = present["address1"] || store.Address1 != ""
+	}
+
+	var missing []string
+	for _, field := range []string{"id", "city", "name", "address1"} {
+		if !present[field] {
+			missing = append(missing, field)
+		}
+	}
+	if len(missing) == 0 {
+		return
+	}
+
+	country, _ := fields["country"].(string)
+	if _, warned := schemaWarned.LoadOrStore(country, true); warned {
+		return
+	}
+	logger.Warn("Store locator response is missing expected fields, the API format may have changed", fields.with("missing", strings.Join(missing, ","), "stores", len(response.Locations)))
+}
@@ -0,0 +1,42 @@
+package main
+
+import "testing"
+
+// Se una risposta aggiornata in testdata/ non supera questi controlli, il formato
+// dell'API è cambiato e i campi usati dallo sniper vanno rivisti
+func TestResponseFixtureFields(t *testing.T) {
+	for _, country := range []string{"it", "de", "fr"} {
+		t.Run(country, func(t *testing.T) {
+			response := loadResponseFixture(t, "locator_"+country+".json")
+			if !response.Success {
+				t.Error("success is false")
+			}
+			if len(response.Locations) == 0 {
+				t.Fatal("no locations")
+			}
+
+			available := 0
+			for i, store := range response.Locations {
+				for field, value := range map[string]string{
+					"id":       store.ID,
+					"city":     store.City,
+					"name":     store.Name,
+					"address1": store.Address1,
+				} {
+					if value == "" {
+						t.Errorf("location %d: %s is empty", i, field)
+					}
+				}
+				if store.Latitude == 0 || store.Longitude == 0 {
+					t.Errorf("location %d (%s): missing coordinates", i, store.ID)
+				}
+				if store.ProductAvailability {
+					available++
+				}
+			}
+			if available == 0 {
+				t.Error("no location has product_availability set: the field may have been renamed")
+			}
+		})
+	}
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// Area di ricerca dello store locator, al posto di coordinate e raggio predefiniti degli endpoint.
+// I campi non indicati mantengono il valore dell'endpoint. Le aree sono configurate per paese:
+// le coordinate di una città non hanno senso per gli endpoint degli altri paesi.
+type SearchArea struct {
+	Latitude  *float64 `json:"latitude"`
+	Longitude *float64 `json:"longitude"`
+	// Raggio di ricerca in metri (parametro searchedRadius dell'endpoint)
+	Radius int `json:"radius"`
+}
+
+// Funzione per validare i valori senza contattare l'endpoint
+func (a SearchArea) validate(country string) error {
+	if a.Latitude != nil && (*a.Latitude < -90 || *a.Latitude > 90) {
+		return fmt.Errorf("search_area.%s.latitude %v: must be between -90 and 90", country, *a.Latitude)
+	}
+	if a.Longitude != nil && (*a.Longitude < -180 || *a.Longitude > 180) {
+		return fmt.Errorf("search_area.%s.longitude %v: must be between -180 and 180", country, *a.Longitude)
+	}
+	if a.Radius < 0 {
+		return fmt.Errorf("search_area.%s.radius %d: must be a positive number of meters", country, a.Radius)
+	}
+	return nil
+}
+
+// Funzione per validare le aree di ricerca, indicate per codice paese ("IT", "DE", "FR")
+func validateSearchAreas(areas map[string]SearchArea) error {
+	for country, area := range areas {
+		if !isSupportedCountry(country) {
+			return fmt.Errorf("search_area: unknown country %q, use one of %s", country, strings.Join(supportedCountries, ", "))
+		}
+		if err := area.validate(country); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Area di ricerca configurata per un paese (vuota = valori dell'endpoint)
+func searchAreaFor(country string) SearchArea {
+	return config.SearchArea[country]
+}
+
+// Parametri dell'url corrispondenti all'area, con il nome usato dall'endpoint
+func (a SearchArea) params() map[string]string {
+	params := make(map[string]string)
+	if a.Latitude != nil {
+		params["latitude"] = strconv.FormatFloat(*a.Latitude, 'f', -1, 64)
+	}
+	if a.Longitude != nil {
+		params["longitude"] = strconv.FormatFloat(*a.Longitude, 'f', -1, 64)
+	}
+	if a.Radius > 0 {
+		params["searchedRadius"] = strconv.Itoa(a.Radius)
+	}
+	return params
+}
+
+// Funzione per applicare l'area di ricerca all'url dell'endpoint
+func applySearchArea(endpoint_url string, area SearchArea) string {
+	params := area.params()
+	if len(params) == 0 {
+		return endpoint_url
+	}
+	u, err := url.Parse(endpoint_url)
+	if err != nil {
+		return endpoint_url
+	}
+	query := u.Query()
+	for key, value := range params {
+		query.Set(key, value)
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// Nome del campo di configurazione corrispondente a un parametro dell'url
+var searchAreaParamNames = map[string]string{
+	"latitude":       "latitude",
+	"longitude":      "longitude",
+	"searchedRadius": "radius",
+	"pid":            "product PID",
+}
+
+// Funzione per provare un'area di ricerca prima di salvarla: costruisce l'url, esegue una
+// richiesta di prova e controlla che la risposta sia un JSON con success:true. Se l'endpoint
+// rifiuta la richiesta, ogni parametro personalizzato viene riportato al valore predefinito
+// per capire quale ha causato il rifiuto.
+func checkSearchArea(country string, area SearchArea, pid string) error {
+	if err := area.validate(country); err != nil {
+		return err
+	}
+	base := baseEndpointForCountry(country)
+	params := area.params()
+	params["pid"] = pid
+	testURL := endpointForProduct(applySearchArea(base, area), pid)
+
+	rejected, err := testEndpointURL(testURL)
+	if err == nil {
+		return nil
+	}
+	if !rejected {
+		return fmt.Errorf("the test request failed, the values could not be checked: %v", lookupErrorHint(err))
+	}
+
+	defaults, _ := url.Parse(base)
+	for _, key := range []string{"latitude", "longitude", "searchedRadius", "pid"} {
+		value, custom := params[key]
+		if !custom || value == defaults.Query().Get(key) {
+			continue
+		}
+		u, parseErr := url.Parse(testURL)
+		if parseErr != nil {
+			break
+		}
+		query := u.Query()
+		query.Set(key, defaults.Query().Get(key))
+		u.RawQuery = query.Encode()
+		if retryRejected, retryErr := testEndpointURL(u.String()); retryErr == nil {
+			return fmt.Errorf("the store locator rejected the request (%v), most likely because of %s %q", err, searchAreaParamNames[key], value)
+		} else if !retryRejected {
+			break
+		}
+	}
+	return fmt.Errorf("the store locator rejected the request (%v): check latitude, longitude, radius and product PID together", err)
+}
+
+// Funzione per eseguire una richiesta di prova; rejected indica che l'endpoint ha risposto
+// ma ha rifiutato i parametri (HTTP 4xx, JSON non valido o success:false), a differenza
+// di un errore di rete o di un blocco anti-bot
+func testEndpointURL(endpoint_url string) (rejected bool, err error) {
+	response, err := fetchStoreResponse(endpoint_url)
+	if err != nil {
+		var statusErr *httpStatusError
+		var decodeErr *decodeError
+		if errors.As(err, &statusErr) && statusErr.StatusCode >= 400 && statusErr.StatusCode < 500 && !isBlockError(err) {
+			return true, err
+		}
+		return errors.As(err, &decodeErr), err
+	}
+	if !response.Success {
+		return true, fmt.Errorf("the response has success:false")
+	}
+	return false, nil
+}
+
+// Funzione per impostare dal menu un'area di ricerca personalizzata per il paese selezionato,
+// salvata solo dopo una richiesta di prova riuscita
+func editSearchArea() {
+	country, err := readCountrySelection()
+	if err != nil {
+		color.Red("Select a country first.\n")
+		return
+	}
+	country = strings.TrimSpace(country)
+
+	cfg, err := readConfigFile()
+	if err != nil {
+		color.Red("Cannot edit the search area: %v\n", err)
+		return
+	}
+	area := cfg.SearchArea[country]
+	fmt.Printf("Search area for %s:\n", country)
+
+	readFloat := func(prompt string, current *float64) (*float64, bool) {
+		if current != nil {
+			prompt += fmt.Sprintf(" [%v]", *current)
+		}
+		fmt.Println(prompt + " (leave empty to keep it, \"-\" for the default):")
+		value := readOptionalLine()
+		switch value {
+		case "":
+			return current, true
+		case "-":
+			return nil, true
+		}
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			color.Red("%q is not a number.\n", value)
+			return nil, false
+		}
+		return &f, true
+	}
+
+	var ok bool
+	if area.Latitude, ok = readFloat("Latitude", area.Latitude); !ok {
+		return
+	}
+	if area.Longitude, ok = readFloat("Longitude", area.Longitude); !ok {
+		return
+	}
+	fmt.Printf("Search radius in meters [%d] (leave empty to keep it, 0 for the default):\n", area.Radius)
+	if value := readOptionalLine(); value != "" {
+		radius, err := strconv.Atoi(value)
+		if err != nil {
+			color.Red("%q is not a whole number.\n", value)
+			return
+		}
+		area.Radius = radius
+	}
+
+	pid := defaultProductID
+	if len(cfg.Products) > 0 {
+		pid = cfg.Products[0]
+	}
+	fmt.Printf("Product PID for the test request [%s]:\n", pid)
+	if value := readOptionalLine(); value != "" {
+		pid = value
+	}
+
+	fmt.Println("Testing the store locator with these values...")
+	if err := checkSearchArea(country, area, pid); err != nil {
+		color.Red("Not saved: %v\n", err)
+		return
+	}
+	if cfg.SearchArea == nil {
+		cfg.SearchArea = make(map[string]SearchArea)
+	}
+	if area.Latitude == nil && area.Longitude == nil && area.Radius == 0 {
+		delete(cfg.SearchArea, country)
+	} else {
+		cfg.SearchArea[country] = area
+	}
+	if err := saveConfig(cfg); err != nil {
+		color.Red("Not saved: %v\n", err)
+		return
+	}
+	if updated, err := loadConfig(); err == nil {
+		config = updated
+	}
+	color.Green("Search area for %s saved.\n", country)
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/zalando/go-keyring"
+)
+
+// Servizio con cui i segreti vengono salvati nel portachiavi del sistema operativo
+const keyringService = "sephora-sniper"
+
+// Nomi dei segreti nel portachiavi
+const (
+	secretWebhookURL      = "webhook_url"
+	secretDiscordBotToken = "discord_bot_token"
+	secretAPIToken        = "api_token"
+	secretNtfyToken       = "ntfy_token"
+	// Elenco dei proxy in JSON: gli url possono contenere utente e password
+	secretProxies = "proxies"
+)
+
+// Funzione per leggere un segreto dal portachiavi. Ritorna ok=false se il portachiavi non è
+// abilitato, non è disponibile o non contiene il segreto: il chiamante usa allora i file.
+func readSecret(name string) (string, bool) {
+	if !config.UseKeyring {
+		return "", false
+	}
+	return lookupSecret(name)
+}
+
+func lookupSecret(name string) (string, bool) {
+	value, err := keyring.Get(keyringService, name)
+	if err != nil {
+		if !errors.Is(err, keyring.ErrNotFound) {
+			logger.Debug("Keyring unavailable, using file storage", logFields{"secret": name, "error": err})
+		}
+		return "", false
+	}
+	return value, true
+}
+
+// Funzione per salvare un segreto nel portachiavi; ritorna false se non è possibile
+func writeSecret(name string, value string) bool {
+	if !config.UseKeyring {
+		return false
+	}
+	if err := keyring.Set(keyringService, name, value); err != nil {
+		logger.Warn("Keyring unavailable, using file storage", logFields{"secret": name, "error": err})
+		return false
+	}
+	return true
+}
+
+// Funzione per completare la configurazione con i token salvati nel portachiavi
+func applyKeyringSecrets(cfg *Config) {
+	if !cfg.UseKeyring {
+		return
+	}
+	if cfg.DiscordBotToken == "" {
+		if value, ok := lookupSecret(secretDiscordBotToken); ok {
+			cfg.DiscordBotToken = value
+		}
+	}
+	if cfg.APIToken == "" {
+		if value, ok := lookupSecret(secretAPIToken); ok {
+			cfg.APIToken = value
+		}
+	}
+	if cfg.Ntfy.Token == "" {
+		if value, ok := lookupSecret(secretNtfyToken); ok {
+			cfg.Ntfy.Token = value
+		}
+	}
+	if len(cfg.Proxies) == 0 {
+		if value, ok := lookupSecret(secretProxies); ok {
+			if err := json.Unmarshal([]byte(value), &cfg.Proxies); err != nil {
+				logger.Warn("Invalid proxy list in the keyring, ignoring it", logFields{"error": err})
+			}
+		}
+	}
+}
+
+// Funzione per verificare se almeno un proxy contiene credenziali (utente o password)
+func proxiesHaveCredentials(proxies []string) bool {
+	for _, proxy := range proxies {
+		if u, err := url.Parse(proxy); err == nil && u.User != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Funzione per spostare nel portachiavi i segreti salvati in chiaro (webhook_url.txt, i token
+// di config.json e i proxy con credenziali). I file vengono salvati nei backup prima di essere modificati, poi i segreti
+// vengono tolti anche dai backup.
+// Ritorna i nomi dei segreti migrati.
+func migrateSecretsToKeyring() ([]string, error) {
+	// Verifica preliminare: meglio fallire subito che lasciare la migrazione a metà
+	if err := keyring.Set(keyringService, "probe", "ok"); err != nil {
+		return nil, fmt.Errorf("keyring unavailable: %v", err)
+	}
+	keyring.Delete(keyringService, "probe")
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	var migrated []string
+	if content, err := os.ReadFile("webhook_url.txt"); err == nil && strings.TrimSpace(string(content)) != "" {
+		if err := keyring.Set(keyringService, secretWebhookURL, strings.TrimSpace(string(content))); err != nil {
+			return migrated, err
+		}
+		if err := backupConfig(); err != nil {
+			return migrated, err
+		}
+		if err := os.Remove("webhook_url.txt"); err != nil {
+			return migrated, err
+		}
+		migrated = append(migrated, secretWebhookURL)
+	}
+
+	for _, secret := range []struct {
+		name  string
+		value *string
+	}{
+		{secretDiscordBotToken, &cfg.DiscordBotToken},
+		{secretAPIToken, &cfg.APIToken},
+		{secretNtfyToken, &cfg.Ntfy.Token},
+	} {
+		if *secret.value == "" {
+			continue
+		}
+		if err := keyring.Set(keyringService, secret.name, *secret.value); err != nil {
+			return migrated, err
+		}
+		*secret.value = ""
+		migrated = append(migrated, secret.name)
+	}
+
+	// I proxy vengono spostati solo se contengono credenziali, come elenco unico
+	if proxiesHaveCredentials(cfg.Proxies) {
+		value, err := json.Marshal(cfg.Proxies)
+		if err != nil {
+			return migrated, err
+		}
+		if err := keyring.Set(keyringService, secretProxies, string(value)); err != nil {
+			return migrated, err
+		}
+		cfg.Proxies = nil
+		migrated = append(migrated, secretProxies)
+	}
+
+	cfg.UseKeyring = true
+	if err := saveConfig(cfg); err != nil {
+		return migrated, err
+	}
+	config.UseKeyring = true
+
+	// Le copie in chiaro nei backup (compresi quelli appena creati) vengono rimosse,
+	// così un ripristino non riporta i segreti nei file
+	if err := scrubBackupSecrets(migrated); err != nil {
+		return migrated, fmt.Errorf("secrets moved, but cleaning the backups failed: %v", err)
+	}
+	return migrated, nil
+}
+
+// Posizione dei segreti in config.json, per nome del segreto
+var secretConfigPaths = map[string][]string{
+	secretDiscordBotToken: {"discord_bot_token"},
+	secretAPIToken:        {"api_token"},
+	secretNtfyToken:       {"ntfy", "token"},
+	secretProxies:         {"proxies"},
+}
+
+// Funzione per eliminare dai backup i segreti indicati: webhook_url.txt viene cancellato e
+// i token vengono tolti dalle copie di config.json
+func scrubBackupSecrets(names []string) error {
+	backups, err := listBackups()
+	if err != nil {
+		return err
+	}
+	for _, backup := range backups {
+		dir := filepath.Join(backupDir, backup)
+		for _, name := range names {
+			if name == secretWebhookURL {
+				if err := os.Remove(filepath.Join(dir, "webhook_url.txt")); err != nil && !os.IsNotExist(err) {
+					return err
+				}
+			}
+		}
+		if err := scrubConfigFile(filepath.Join(dir, configFile), names); err != nil {
+			return fmt.Errorf("%s: %v", backup, err)
+		}
+	}
+	return nil
+}
+
+// Funzione per togliere i segreti indicati da una copia di config.json, lasciando invariate
+// le altre impostazioni
+func scrubConfigFile(path string, names []string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	var raw map[string]interface{}
+	if err := json.Unmarshal(content, &raw); err != nil {
+		return err
+	}
+
+	changed := false
+	for _, name := range names {
+		keys, ok := secretConfigPaths[name]
+		if !ok {
+			continue
+		}
+		parent := raw
+		for _, key := range keys[:len(keys)-1] {
+			if parent, ok = parent[key].(map[string]interface{}); !ok {
+				break
+			}
+		}
+		if parent == nil {
+			continue
+		}
+		if _, found := parent[keys[len(keys)-1]]; found {
+			delete(parent, keys[len(keys)-1])
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+
+	scrubbed, err := json.MarshalIndent(raw, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(scrubbed, '\n'), 0600)
+}
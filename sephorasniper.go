@@ -3,15 +3,17 @@ package main
 import (
 	"bufio"
 	"bytes"
-	"crypto/tls"
+	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
-	"log"
-	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -30,6 +32,41 @@ const endpoint_url_de = "https://www.sephora.de/on/demandware.store/Sites-Sephor
 
 var storeResponse StoreResponse
 
+// Flag da riga di comando
+var (
+	logFormatFlag = flag.String("log-format", "text", "log output format: text or json")
+	logLevelFlag  = flag.String("log-level", "info", "minimum log level: debug, info, warn, error")
+
+	onlyAvailableFlag = flag.Bool("only-available", false, "print only in-stock stores during checks")
+	compactFlag       = flag.Bool("compact", false, "show a single status line updated in place, printing details only on restocks")
+	maxInflightFlag   = flag.Int("max-inflight", 4, "maximum concurrent outbound HTTP requests (locator and notifications)")
+
+	snipeFlag = flag.Bool("snipe", false, "start the sniper immediately, skipping the menu")
+	countFlag = flag.Int("count", 0, "stop after this many check cycles (0 = run forever)")
+	onceFlag  = flag.Bool("once", false, "run a single check cycle and exit (same as -snipe -count 1)")
+
+	maxRuntimeFlag = flag.Duration("max-runtime", 0, "stop the sniper after this long (e.g. 2h; 0 = no limit)")
+
+	ackFlag = flag.String("ack", "", "acknowledge a restock (STOREID or STOREID:PRODUCTID) to stop further alerts, then exit")
+
+	apiAddrFlag = flag.String("api-addr", "", "serve the local control API on this address while sniping (e.g. 127.0.0.1:8787)")
+
+	// Debug: iniezione di un finto restock nella catena delle notifiche
+	simulateRestockFlag = flag.String("simulate-restock", "", "debug: send a fake restock for STOREID[:PRODUCTID] through the notification pipeline, then exit")
+	persistFlag         = flag.Bool("persist", false, "with -simulate-restock, save the resulting notification state")
+
+	validateConfigFlag = flag.Bool("validate-config", false, "check config.json and the settings files, print a report and exit (non-zero on problems)")
+
+	resetStatsFlag = flag.Bool("reset-stats", false, "delete the cumulative statistics in stats.json, then exit")
+
+	recordPinsFlag = flag.Bool("record-pins", false, "fetch the current certificate pins of the endpoints, save them in config.json, then exit")
+
+	probeFlag = flag.String("probe", "", "debug: fetch this store locator URL, print status, headers and the decoded response, then exit")
+)
+
+// Contesto comune a tutte le richieste in uscita; annullandolo si interrompono le attese
+var appCtx = context.Background()
+
 type WorkingStatus struct {
 	Status  string `json:"status"`
 	Message string `json:"message"`
@@ -64,6 +101,34 @@ func (s *ScheduleForJsonLD) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// Numero che l'endpoint può restituire anche come stringa ("45.46"): una stringa vuota vale 0
+type FlexFloat64 float64
+
+// Override per accettare sia un numero JSON che una stringa contenente un numero
+func (f *FlexFloat64) UnmarshalJSON(data []byte) error {
+	var number float64
+	if err := json.Unmarshal(data, &number); err == nil {
+		*f = FlexFloat64(number)
+		return nil
+	}
+
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return fmt.Errorf("expected a number or a numeric string, got %s", data)
+	}
+	text = strings.TrimSpace(text)
+	if text == "" {
+		*f = 0
+		return nil
+	}
+	number, err := strconv.ParseFloat(strings.Replace(text, ",", ".", 1), 64)
+	if err != nil {
+		return fmt.Errorf("invalid numeric string %q: %v", text, err)
+	}
+	*f = FlexFloat64(number)
+	return nil
+}
+
 type Location struct {
 	ID                     string            `json:"id"`
 	OMSID                  string            `json:"omsId"`
@@ -78,13 +143,13 @@ type Location struct {
 	Address3               string            `json:"address3"`
 	Phone                  string            `json:"phone"`
 	WorkingStatus          WorkingStatus     `json:"working_status"`
-	Latitude               float64           `json:"latitude"`
-	Longitude              float64           `json:"longitude"`
+	Latitude               FlexFloat64       `json:"latitude"`
+	Longitude              FlexFloat64       `json:"longitude"`
 	Favorite               bool              `json:"favorite"`
 	Schedule               []Schedule        `json:"schedule"`
 	ScheduleForJsonLD      ScheduleForJsonLD `json:"scheduleForJsonLD"` // Modificato in tipo personalizzato
 	Image                  string            `json:"image"`
-	Distance               float64           `json:"distance"`
+	Distance               FlexFloat64       `json:"distance"`
 	StoreServices          []StoreService    `json:"store_services"`
 	Exceptional            *string           `json:"exceptional"` // Usare *string per permettere il valore null
 	ExceptionalOpeningText string            `json:"exceptionalOpeningText"`
@@ -95,7 +160,116 @@ type Location struct {
 	BookingAPIKey          string            `json:"bookingAPIKey"`
 	EnableDeliveryToStore  bool              `json:"enableDeliveryToStore"`
 	EnableClickCollect     bool              `json:"enableClickCollect"`
-	ProductAvailability    bool              `json:"product_availability"` // Assicurati che questo campo esista nel JSON
+	// L'endpoint espone un solo booleano di disponibilità (pdpstock e clickcollect sono parametri
+	// della richiesta, non campi separati): i flag EnableClickCollect/EnableDeliveryToStore
+	// indicano solo se lo store offre il servizio, non la disponibilità del prodotto per quel servizio.
+	ProductAvailability bool `json:"product_availability"` // Assicurati che questo campo esista nel JSON
+	// Unità disponibili, se l'endpoint le riporta (nil = solo il booleano, vedi stockQuantity).
+	// Il campo non compare nelle risposte attuali: "quantity" è il nome previsto nel caso venga
+	// aggiunto, e finché manca min_quantity non ha effetto
+	Quantity *FlexFloat64 `json:"quantity,omitempty"`
+}
+
+// Funzione per unire le voci duplicate (stesso ID) restituite dallo store locator, ad esempio
+// una per servizio. I flag di disponibilità e dei servizi vengono combinati in OR.
+// Ritorna la lista senza duplicati e il numero di voci unite.
+func dedupeLocations(locations []Location) ([]Location, int) {
+	index := make(map[string]int, len(locations))
+	deduped := make([]Location, 0, len(locations))
+	collapsed := 0
+
+	for _, location := range locations {
+		i, seen := index[location.ID]
+		if !seen {
+			index[location.ID] = len(deduped)
+			deduped = append(deduped, location)
+			continue
+		}
+
+		merged := &deduped[i]
+		merged.ProductAvailability = merged.ProductAvailability || location.ProductAvailability
+		merged.EnableClickCollect = merged.EnableClickCollect || location.EnableClickCollect
+		merged.EnableDeliveryToStore = merged.EnableDeliveryToStore || location.EnableDeliveryToStore
+		merged.HasBookable = merged.HasBookable || location.HasBookable
+		if location.Quantity != nil && (merged.Quantity == nil || *location.Quantity > *merged.Quantity) {
+			merged.Quantity = location.Quantity
+		}
+		for _, service := range location.StoreServices {
+			if !hasService(merged.StoreServices, service.ID) {
+				merged.StoreServices = append(merged.StoreServices, service)
+			}
+		}
+		collapsed++
+	}
+
+	return deduped, collapsed
+}
+
+func hasService(services []StoreService, id string) bool {
+	for _, service := range services {
+		if service.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Funzione per preparare i servizi di uno store alla visualizzazione: rimuove i duplicati
+// (stesso ID, vale il primo) e ordina per nome, così l'output resta stabile
+func normalizeServices(services []StoreService) []StoreService {
+	normalized := make([]StoreService, 0, len(services))
+	for _, service := range services {
+		if !hasService(normalized, service.ID) {
+			normalized = append(normalized, service)
+		}
+	}
+	sort.SliceStable(normalized, func(i, j int) bool {
+		return normalized[i].Name < normalized[j].Name
+	})
+	return normalized
+}
+
+// Funzione per elencare i nomi dei servizi di uno store, separati da virgola
+func serviceNames(services []StoreService) string {
+	var names []string
+	for _, service := range normalizeServices(services) {
+		if name := strings.TrimSpace(service.Name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// Funzione per generare un link Google Maps verso lo store: usa le coordinate se presenti,
+// altrimenti l'indirizzo
+func mapsURL(store Location) string {
+	var query string
+	if store.Latitude != 0 || store.Longitude != 0 {
+		query = fmt.Sprintf("%f,%f", store.Latitude, store.Longitude)
+	} else {
+		parts := []string{}
+		for _, part := range []string{store.Address1, store.Postal, store.City, store.Country} {
+			if strings.TrimSpace(part) != "" {
+				parts = append(parts, strings.TrimSpace(part))
+			}
+		}
+		if len(parts) == 0 {
+			return ""
+		}
+		query = strings.Join(parts, ", ")
+	}
+	return "https://www.google.com/maps/search/?api=1&query=" + url.QueryEscape(query)
+}
+
+// Funzione per descrivere i servizi di ritiro offerti dallo store
+func fulfillmentSummary(store Location) string {
+	yesNo := func(enabled bool) string {
+		if enabled {
+			return "yes"
+		}
+		return "no"
+	}
+	return fmt.Sprintf("Click & Collect: %s, Delivery to Store: %s", yesNo(store.EnableClickCollect), yesNo(store.EnableDeliveryToStore))
 }
 
 type StoreResponse struct {
@@ -108,30 +282,89 @@ type StoreResponse struct {
 }
 
 type DiscordWebhookPayload struct {
-	Content string `json:"content"`
+	Content string         `json:"content,omitempty"`
+	Embeds  []DiscordEmbed `json:"embeds,omitempty"`
+	Flags   int            `json:"flags,omitempty"`
+}
+
+// Embed di Discord, usato per i messaggi che devono distinguersi dagli avvisi di disponibilità
+type DiscordEmbed struct {
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	Color       int    `json:"color,omitempty"`
+}
+
+// Colore (RGB) degli embed per i prodotti tornati esauriti
+const embedColorOrange = 0xF28C28
+
+// Lunghezza massima del campo content accettata da Discord
+const discordMessageLimit = 2000
+
+// Funzione per dividere un messaggio troppo lungo in più parti entro il limite di Discord,
+// spezzando preferibilmente sugli a capo
+func splitDiscordMessage(message string, limit int) []string {
+	var chunks []string
+	runes := []rune(message)
+	for len(runes) > limit {
+		cut := limit
+		for i := limit; i > 0; i-- {
+			if runes[i-1] == '\n' {
+				cut = i
+				break
+			}
+		}
+		// Discord rifiuta i messaggi vuoti: le parti fatte solo di a capo vengono saltate
+		if chunk := strings.TrimRight(string(runes[:cut]), "\n"); chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+		runes = runes[cut:]
+	}
+	if strings.TrimSpace(string(runes)) != "" {
+		chunks = append(chunks, string(runes))
+	}
+	return chunks
 }
 
 func sendDiscordNotification(webhookURL string, message string) error {
-	payload := DiscordWebhookPayload{
-		Content: message,
+	// I messaggi oltre il limite di Discord vengono inviati in più parti
+	for _, chunk := range splitDiscordMessage(message, discordMessageLimit) {
+		if err := sendDiscordMessage(webhookURL, chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sendDiscordMessage(webhookURL string, message string) error {
+	return postDiscordPayload(webhookURL, DiscordWebhookPayload{Content: message})
+}
+
+// Funzione per inviare un messaggio come embed colorato (la descrizione ha un limite di 4096 caratteri)
+func sendDiscordEmbed(webhookURL string, title string, description string, color int) error {
+	if runes := []rune(description); len(runes) > 4096 {
+		description = string(runes[:4096])
 	}
+	return postDiscordPayload(webhookURL, DiscordWebhookPayload{
+		Embeds: []DiscordEmbed{{Title: title, Description: description, Color: color}},
+	})
+}
 
+func postDiscordPayload(webhookURL string, payload DiscordWebhookPayload) error {
 	jsonPayload, err := json.Marshal(payload)
 	if err != nil {
 		return fmt.Errorf("failed to marshal JSON payload: %v", err)
 	}
 
-	req, err := http.NewRequest("POST", webhookURL, bytes.NewBuffer(jsonPayload))
+	req, err := http.NewRequestWithContext(appCtx, "POST", webhookURL, bytes.NewBuffer(jsonPayload))
 	if err != nil {
 		return fmt.Errorf("failed to create request: %v", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := doLimited(notifierClient(), req)
 	if err != nil {
-		return fmt.Errorf("failed to send request: %v", err)
+		return fmt.Errorf("failed to send request: %w", err)
 	}
 	defer resp.Body.Close()
 
@@ -142,126 +375,498 @@ func sendDiscordNotification(webhookURL string, message string) error {
 	return nil
 }
 
-func downloadStoreData(endpoint_url string) {
-	customTransport := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		DialContext: (&net.Dialer{
-			Timeout: 10 * time.Second,
-		}).DialContext,
+// Errore restituito quando l'endpoint risponde con uno stato HTTP diverso da 200
+type httpStatusError struct {
+	StatusCode int
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("risposta HTTP %d ricevuta", e.StatusCode)
+}
+
+// Funzione per aggiungere ai campi di log l'errore ed eventualmente lo stato HTTP
+func errorFields(fields logFields, err error) logFields {
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) {
+		return fields.with("error", err, "http_status", statusErr.StatusCode)
+	}
+	var decodeErr *decodeError
+	if errors.As(err, &decodeErr) {
+		return fields.with("error", decodeErr.Err, "http_status", decodeErr.StatusCode, "decode_error", decodeErr.kind(),
+			"content_type", decodeErr.ContentType, "bytes", decodeErr.Length, "body", decodeErr.Snippet, "body_file", decodeErr.BodyFile)
+	}
+	// Corpo interrotto durante la lettura o la decodifica in streaming
+	if truncatedResponse(err) {
+		return fields.with("error", err, "decode_error", "truncated")
+	}
+	return fields.with("error", err)
+}
+
+// Funzione per scaricare e decodificare la risposta dello store locator
+func fetchStoreResponse(endpoint_url string) (StoreResponse, error) {
+	return fetchStoreResponseFor(endpoint_url, nil)
+}
+
+// Come fetchStoreResponse, ma le risposte grandi decodificate in streaming conservano solo
+// gli store indicati (nil = tutti)
+func fetchStoreResponseFor(endpoint_url string, storeIDs []string) (StoreResponse, error) {
+	// Il pool di proxy viene creato insieme al client condiviso
+	httpClient()
+	// Una risposta troncata viene richiesta di nuovo, anche tramite lo stesso proxy
+	fields := endpointFields(endpoint_url)
+	if proxies.size() == 0 {
+		var storeResponse StoreResponse
+		err := refetchIfTruncated(fields, func() (err error) {
+			storeResponse, err = fetchStoreResponseVia(endpoint_url, storeIDs, nil)
+			return err
+		})
+		return storeResponse, err
 	}
 
-	client := &http.Client{
-		Transport: customTransport,
-		Timeout:   15 * time.Second,
+	// Con un pool di proxy, una richiesta bloccata viene ripetuta subito con un altro proxy
+	// prima di essere contata come errore
+	tried := make(map[*proxyEntry]bool)
+	var storeResponse StoreResponse
+	var err error
+	for {
+		proxy := proxies.pick(tried)
+		if proxy == nil {
+			return storeResponse, err
+		}
+		tried[proxy] = true
+
+		err = refetchIfTruncated(fields, func() (err error) {
+			storeResponse, err = fetchStoreResponseVia(endpoint_url, storeIDs, proxy)
+			return err
+		})
+		proxies.report(proxy, err)
+		if !isBlockError(err) || appCtx.Err() != nil {
+			return storeResponse, err
+		}
+		logger.Warn("Request blocked, retrying through another proxy", errorFields(fields, err).with("proxy", proxy.URL.Redacted()))
 	}
+}
+
+// Funzione per eseguire la richiesta allo store locator, tramite il proxy indicato (nil = nessuno)
+func fetchStoreResponseVia(endpoint_url string, storeIDs []string, proxy *proxyEntry) (StoreResponse, error) {
+	var storeResponse StoreResponse
 
-	req, err := http.NewRequest("GET", endpoint_url, nil)
+	// I dati filtrati vengono tenuti in cache separatamente da quelli completi
+	cacheKey := responseCacheKey(endpoint_url)
+	var keep func(Location) bool
+	if storeIDs != nil {
+		cacheKey += "#" + strings.Join(storeIDs, ",")
+		wanted := make(map[string]bool, len(storeIDs))
+		for _, id := range storeIDs {
+			wanted[id] = true
+		}
+		keep = func(store Location) bool { return wanted[store.ID] }
+	}
+
+	client := httpClient()
+
+	// Creazione di una nuova richiesta HTTP
+	// Metodo e corpo configurabili (default GET senza corpo)
+	req, err := newEndpointRequest(config.LocatorRequest, endpoint_url, locatorReplacer(endpoint_url, storeIDs))
 	if err != nil {
-		log.Fatalf("Errore nel creare la richiesta: %v", err)
+		return storeResponse, fmt.Errorf("errore nel creare la richiesta: %w", err)
 	}
 
-	req.Header.Set("User-Agent", "Mozilla/5.0")
+	// Aggiunta dell'header User-Agent
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/92.0.4515.131 Safari/537.36")
+	config.LocatorRequest.applyHeaders(req)
+	req = withProxy(req, proxy)
+
+	// Se abbiamo già una risposta con ETag chiediamo al server se è cambiata
+	cached, hasCached := cachedResponse(cacheKey)
+	if hasCached {
+		req.Header.Set("If-None-Match", cached.ETag)
+	}
 
-	resp, err := client.Do(req)
+	// Richiesta HTTP, distanziata dalla precedente
+	if !waitRequestSlot() {
+		return storeResponse, appCtx.Err()
+	}
+	metrics.recordRequest()
+	resp, err := doLimited(client, req)
 	if err != nil {
-		log.Fatalf("Errore nel fare la richiesta: %v", err)
+		return storeResponse, fmt.Errorf("errore nel fare la richiesta: %w", err)
 	}
 	defer resp.Body.Close()
 
+	// Nessuna modifica dall'ultima richiesta: riusiamo i dati già decodificati
+	if resp.StatusCode == http.StatusNotModified && hasCached {
+		logger.Debug("Response not modified, using cached data", endpointFields(endpoint_url).with("etag", cached.ETag))
+		return cached.Response, nil
+	}
+
+	// Controllo dello stato HTTP
 	if resp.StatusCode != http.StatusOK {
-		log.Fatalf("Errore: risposta HTTP %d ricevuta", resp.StatusCode)
+		return storeResponse, &httpStatusError{StatusCode: resp.StatusCode}
 	}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		log.Fatalf("Errore nel leggere il corpo della risposta: %v", err)
+	if useStreamingDecode(resp.ContentLength) {
+		storeResponse, err = decodeStreamingBody(resp, endpoint_url, keep)
+		if err != nil {
+			return storeResponse, err
+		}
+	} else {
+		// Lettura del corpo della risposta, entro la dimensione massima consentita
+		body, err := readLimitedBody(resp.Body, endpointFields(endpoint_url))
+		if err != nil {
+			return storeResponse, fmt.Errorf("errore nel leggere il corpo della risposta: %w", err)
+		}
+
+		// Pagina di challenge anti-bot al posto del JSON: blocco temporaneo, non un errore fatale
+		if isChallengeResponse(resp.Header.Get("Content-Type"), body) {
+			reportChallenge(body)
+			return storeResponse, errAntiBotChallenge
+		}
+
+		// Decodifica del JSON nella struct StoreResponse
+		parseStart := time.Now()
+		if err := json.Unmarshal(body, &storeResponse); err != nil {
+			return storeResponse, newDecodeError(resp, body, err)
+		}
+		logParseTime(len(body), parseStart, endpointFields(endpoint_url))
+	}
+	checkResponseSchema(storeResponse, endpointFields(endpoint_url))
+	checkStoreServices(storeResponse, endpointFields(endpoint_url))
+
+	// Lo stesso store può comparire più volte: lo processiamo una sola volta per evitare doppie notifiche
+	var collapsed int
+	storeResponse.Locations, collapsed = dedupeLocations(storeResponse.Locations)
+	if collapsed > 0 {
+		logger.Info("Duplicate store entries collapsed", endpointFields(endpoint_url).with("duplicates", collapsed))
 	}
 
-	err = json.Unmarshal(body, &storeResponse)
-	if err != nil {
-		log.Fatalf("Errore nel decodificare il JSON: %v", err)
+	storeCachedResponse(cacheKey, resp.Header.Get("ETag"), storeResponse)
+
+	return storeResponse, nil
+}
+
+// Funzione per aggiungere all'url un parametro casuale, così da evitare risposte in cache
+func cacheBustedURL(endpoint_url string) string {
+	separator := "&"
+	if !strings.Contains(endpoint_url, "?") {
+		separator = "?"
 	}
+	return fmt.Sprintf("%s%s_=%d", endpoint_url, separator, time.Now().UnixNano())
 }
 
-func checkProductAvailability(storeIDs []string, endpoint_url string, webhookurl string) {
-	// Creazione di un client HTTP personalizzato con timeout
-	customTransport := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		DialContext: (&net.Dialer{
-			Timeout: 10 * time.Second, // Timeout per la connessione
-		}).DialContext,
-		ForceAttemptHTTP2:     false,
-		MaxIdleConns:          100,
-		IdleConnTimeout:       90 * time.Second,
-		TLSHandshakeTimeout:   10 * time.Second,
-		ExpectContinueTimeout: 1 * time.Second,
+// Funzione per confermare una disponibilità appena rilevata con ulteriori richieste alla
+// sorgente scelta con availability_source (store locator o pagina prodotto).
+// Ritorna true solo se tutti i tentativi di conferma riportano il prodotto disponibile.
+func confirmAvailability(storeID string, endpoint_url string, fields logFields) bool {
+	for attempt := 1; attempt <= config.ConfirmAttempts; attempt++ {
+		// L'attesa si interrompe con Ctrl+C o SIGTERM: la disponibilità resta non confermata
+		select {
+		case <-appCtx.Done():
+			return false
+		case <-time.After(config.ConfirmDelay.Duration):
+		}
+
+		confirmed, err := fetchConfirmation(storeID, endpoint_url, fields)
+		if err != nil {
+			logger.Warn("Confirmation request failed", errorFields(fields, err).with("attempt", attempt))
+			return false
+		}
+		if !confirmed {
+			logger.Warn("Availability flip failed confirmation", fields.with("attempt", attempt))
+			return false
+		}
 	}
+	return true
+}
 
-	client := &http.Client{
-		Transport: customTransport,
-		Timeout:   15 * time.Second, // Timeout totale per la richiesta
+// Funzione per eseguire una richiesta di conferma per uno store
+func fetchConfirmation(storeID string, endpoint_url string, fields logFields) (bool, error) {
+	if config.AvailabilitySource == sourcePDP && config.PDPStockEndpoint != "" {
+		product, _ := fields["product"].(string)
+		country, _ := fields["country"].(string)
+		return fetchPDPAvailability(product, storeID, country)
 	}
 
-	// Creazione di una nuova richiesta HTTP
-	req, err := http.NewRequest("GET", endpoint_url, nil)
+	response, err := fetchStoreResponse(cacheBustedURL(endpoint_url))
 	if err != nil {
-		log.Fatalf("Errore nel creare la richiesta: %v", err)
+		return false, err
+	}
+	for _, store := range response.Locations {
+		if store.ID == storeID {
+			return isAvailable(store), nil
+		}
 	}
+	return false, nil
+}
 
-	// Aggiunta dell'header User-Agent
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/92.0.4515.131 Safari/537.36")
+// Ultima disponibilità nota per ogni store/prodotto, usata per rilevare i cambi di stato
+var lastAvailability = make(map[string]bool)
 
-	// Richiesta HTTP
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Fatalf("Errore nel fare la richiesta: %v", err)
+func availabilityKey(storeID string, fields logFields) string {
+	key := fmt.Sprintf("%v|%v|%s", fields["country"], fields["product"], storeID)
+	if fulfillment, ok := fields["fulfillment"]; ok {
+		key += fmt.Sprintf("|%v", fulfillment)
 	}
-	defer resp.Body.Close()
+	return key
+}
 
-	// Controllo dello stato HTTP
-	if resp.StatusCode != http.StatusOK {
-		log.Fatalf("Errore: risposta HTTP %d ricevuta", resp.StatusCode)
+// Risultato del controllo di disponibilità per uno store e un prodotto
+type CheckResult struct {
+	StoreID   string         `json:"store_id"`
+	ProductID string         `json:"product_id"`
+	Country   string         `json:"country"`
+	Name      string         `json:"name"`
+	Address   string         `json:"address"`
+	City      string         `json:"city"`
+	Available bool           `json:"available"`
+	Services  []StoreService `json:"services"`
+	CheckedAt time.Time      `json:"checked_at"`
+	// true se lo store è appena passato da non disponibile a disponibile (confermato)
+	Restocked bool `json:"restocked"`
+	// Distanza in km (dall'endpoint o dalla posizione configurata), 0 se sconosciuta
+	Distance float64 `json:"distance_km,omitempty"`
+	// Tipo di ritiro controllato: click_collect o delivery
+	Fulfillment string `json:"fulfillment"`
+	// true per i risultati iniettati con -simulate-restock
+	Simulated bool `json:"simulated,omitempty"`
+	// Affidabilità del risultato in base ai cambi di stato degli ultimi controlli (0-1) e livello
+	ConfidenceScore float64 `json:"confidence_score"`
+	Confidence      string  `json:"confidence,omitempty"`
+	// Nome della variante ("Prodotto - Tonalità") se il prodotto è una variante configurata
+	Variant string `json:"variant,omitempty"`
+
+	// Dati completi dello store, per link, orari e distanza
+	Store Location `json:"-"`
+}
+
+// Campi di contesto per i log relativi a un risultato
+func (r CheckResult) fields() logFields {
+	fields := logFields{"country": r.Country, "product": r.ProductID, "store": r.StoreID, "available": r.Available}
+	if r.Variant != "" {
+		fields["variant"] = r.Variant
+	}
+	if r.Fulfillment == fulfillmentDelivery {
+		fields["fulfillment"] = r.Fulfillment
 	}
+	return fields
+}
 
-	// Lettura del corpo della risposta
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		log.Fatalf("Errore nel leggere il corpo della risposta: %v", err)
+// Funzione per controllare la disponibilità del prodotto negli store indicati.
+// Non stampa e non notifica: il chiamante decide cosa fare dei risultati.
+func checkProductAvailability(storeIDs []string, endpoint_url string) ([]CheckResult, error) {
+	// Campi di contesto per il log strutturato
+	fields := endpointFields(endpoint_url)
+	fulfillment := fulfillmentOfURL(endpoint_url)
+	if fulfillment == fulfillmentDelivery {
+		fields = fields.with("fulfillment", fulfillment)
 	}
 
-	// Decodifica del JSON nella struct StoreResponse
-	var storeResponse StoreResponse
-	err = json.Unmarshal(body, &storeResponse)
+	storeResponse, err := fetchStoreResponseFor(endpoint_url, storeIDs)
 	if err != nil {
-		log.Fatalf("Errore nel decodificare il JSON: %v", err)
+		return nil, err
 	}
 
+	country, _ := fields["country"].(string)
+	product, _ := fields["product"].(string)
+	checkedAt := time.Now()
+
+	var results []CheckResult
+
 	// Controllo della disponibilità del prodotto negli Store ID specificati
 	for _, store := range storeResponse.Locations {
 		for _, storeID := range storeIDs {
 			if store.ID == storeID {
-				if store.ProductAvailability {
-					// Usa il colore verde se disponibile
-					color.Green("Store ID: %s, Name and Address: %s %s, Availability: %t\n", store.ID, store.Name, store.Address1, store.ProductAvailability)
+				storeFields := fields.with("store", store.ID, "available", isAvailable(store))
+				logger.Info("Store checked", storeFields)
 
-					message := fmt.Sprintf("**🛍️ SEPHORA SNIPER 🏪** \n 🛒 The Product is available in the store **%s**! \nStore Address: %s", store.Name, store.Address1)
-					err := sendDiscordNotification(webhookurl, message)
-					if err != nil {
-						fmt.Printf("Errore nell'invio del messaggio su Discord: %v\n", err)
-					}
+				// Confronto opzionale con l'endpoint di stock della pagina prodotto
+				available := reconcilePDPAvailability(isAvailable(store), product, store.ID, country, storeFields)
 
-				} else {
-					// Altrimenti stampa in giallo
-					color.Yellow("Store ID: %s, Name and Address: %s %s, Availability: %t\n", store.ID, store.Name, store.Address1, store.ProductAvailability)
+				// Se lo store è appena diventato disponibile, chiediamo una conferma prima di notificare
+				key := availabilityKey(store.ID, fields)
+				if available && !lastAvailability[key] && config.ConfirmAttempts > 0 {
+					available = confirmAvailability(store.ID, endpoint_url, storeFields)
+				}
+				restocked := available && !lastAvailability[key]
+				if restocked {
+					metrics.recordRestock(key)
+					recordStatsRestock(country)
 				}
+				lastAvailability[key] = available
+				score, level := recordAvailability(key, available)
+
+				results = append(results, CheckResult{
+					StoreID:     store.ID,
+					ProductID:   product,
+					Country:     country,
+					Name:        store.Name,
+					Address:     store.Address1,
+					City:        store.City,
+					Available:   available,
+					Services:    normalizeServices(store.StoreServices),
+					CheckedAt:   checkedAt,
+					Restocked:   restocked,
+					Distance:    storeDistance(store),
+					Fulfillment: fulfillment,
+					Store:       store,
+
+					ConfidenceScore: score,
+					Confidence:      level,
+					Variant:         variantLabel(product),
+				})
 				break
 			}
 		}
 	}
+
+	return results, nil
+}
+
+// Funzione per creare il messaggio di restock: il template e le intestazioni per il tipo di ritiro,
+// le chiusure per festività, gli store preferiti e le simulazioni
+func restockMessage(result CheckResult) (string, error) {
+	message, err := renderMessage(result)
+	if err != nil {
+		return "", err
+	}
+	if config.Fulfillment == fulfillmentDelivery || config.Fulfillment == fulfillmentBoth {
+		message = fmt.Sprintf("**%s**\n%s", fulfillmentLabel(result.Fulfillment), message)
+	}
+	if note := exceptionalNote(result.Store); note != "" {
+		message = fmt.Sprintf("%s**%s**\n%s", emojiPrefix("closed"), note, message)
+	}
+	if isFavorite(result.Store) {
+		message = emojiPrefix("favorite") + "**Favorite store**\n" + message
+	}
+	if result.Simulated {
+		message = emojiPrefix("simulated") + "**SIMULATED RESTOCK (test)**\n" + message
+	}
+	// Avviso dello store (servizio sospeso, trasferimento): può impedire il ritiro
+	if notice := attentionMessage(result.Store); notice != "" {
+		message += fmt.Sprintf("\n%s**Store notice:** %s", emojiPrefix("notice"), notice)
+	}
+	return message, nil
+}
+
+// Funzione per stampare i risultati di un controllo e inviare le notifiche per gli store disponibili
+func reportCheckResults(results []CheckResult, webhookurl string) {
+	// Lo stato viene riletto ad ogni ciclo, così i riconoscimenti fatti da un altro
+	// processo (-ack o menu) vengono applicati subito
+	state, err := loadNotifyState()
+	if err != nil {
+		logger.Warn("Failed to read notification state, starting fresh", logFields{"error": err})
+	}
+	now := time.Now()
+
+	// Gli store più vicini vengono stampati e notificati per primi
+	sortByDistance(results)
+	if config.PrioritizeFavorites {
+		prioritizeFavorites(results)
+	}
+
+	inStock := 0
+	for _, result := range results {
+		store := result.Store
+		soldOut := !result.Available && state.entry(result).Available
+		logAttentionMessage(result)
+
+		// Prima della data di attivazione dello store lo stato non viene aggiornato, così alla
+		// data un prodotto ancora disponibile viene notificato come un nuovo restock
+		var action notifyAction
+		var reason string
+		if after, deferred := notifyDeferred(result.StoreID, now); deferred && result.Available {
+			action, reason = notifyNone, "notify_after "+formatTimestamp(after)
+		} else if config.OnlyOpenStores && result.Available && storeClosedNow(store, now) {
+			// Come sopra: all'apertura dello store il prodotto ancora disponibile viene notificato
+			action, reason = notifyNone, "store_closed"
+		} else if config.SuppressAttentionStores && result.Available && attentionMessage(store) != "" {
+			// Store con un avviso attivo (servizio forse sospeso): quando l'avviso viene rimosso,
+			// il prodotto ancora disponibile viene notificato
+			action, reason = notifyNone, "attention_message"
+		} else if _, closed := exceptionalClosure(store); closed && config.SuppressExceptionalClosures && result.Available {
+			// Chiusura per festività: come sopra, alla riapertura il prodotto ancora disponibile
+			// viene notificato (senza soppressione la chiusura viene annotata nel messaggio)
+			action, reason = notifyNone, "exceptional_closure"
+		} else if result.Available && productWon(result.ProductID) {
+			// Un solo avviso per prodotto: gli altri store dello stesso ciclo vengono ignorati
+			action, reason = notifyNone, "first_available_wins"
+		} else {
+			action, reason = state.update(result, now)
+		}
+
+		if result.Available {
+			inStock++
+
+			// In modalità compatta il dettaglio viene stampato solo quando parte un avviso
+			if compactOutput() && action == notifyNone {
+				continue
+			}
+			clearStatusLine()
+
+			// Usa il colore verde se disponibile
+			color.Green("%sStore ID: %s, Name and Address: %s %s, Availability: %t (%s)%s\n", favoriteMark(store), result.StoreID, result.Name, result.Address, result.Available, fulfillmentSummary(store), variantSuffix(result)+confidenceSuffix(result)+distanceTag(result.Distance))
+
+			if action == notifyNone {
+				logger.Info("Notification suppressed", result.fields().with("reason", reason))
+				continue
+			}
+
+			message, err := restockMessage(result)
+			if err != nil {
+				logger.Error("Errore nella creazione del messaggio", result.fields().with("error", err))
+				continue
+			}
+			if action == notifyStillAvailable {
+				since := state.entry(result).AvailableSince
+				message = fmt.Sprintf("%s**Still available** since %s (%s)\n%s", emojiPrefix("still_available"), formatTimestamp(since), now.Sub(since).Round(time.Minute), message)
+			}
+			// Gli avvisi poco affidabili (stato che cambia spesso) vengono inviati in forma attenuata
+			if result.Confidence == confidenceLow {
+				logDeliveryResult(result.fields(), errors.Join(
+					dispatchLowConfidenceNotification(webhookFor(result, webhookurl), message, result),
+					dispatchNtfyRestock(result, message, "default"),
+				))
+				continue
+			}
+			// Ogni canale riceve l'avviso anche se un altro fallisce
+			discordErr := dispatchRestockNotification(webhookFor(result, webhookurl), message, result)
+			ntfyErr := dispatchNtfyRestock(result, message, config.Ntfy.priority())
+			logDeliveryResult(result.fields(), errors.Join(discordErr, ntfyErr))
+			// Il prodotto viene considerato vinto solo se qualcuno ha ricevuto l'avviso
+			if deliveredAnywhere(discordErr, ntfyErr) {
+				markProductWon(result)
+			}
+
+		} else {
+			if !*onlyAvailableFlag && !compactOutput() {
+				// Altrimenti stampa in giallo (soppresso in modalità -only-available)
+				color.Yellow("%sStore ID: %s, Name and Address: %s %s, Availability: %t%s\n", favoriteMark(store), result.StoreID, result.Name, result.Address, result.Available, variantSuffix(result)+distanceTag(result.Distance))
+			}
+
+			// Avviso opzionale quando un prodotto disponibile torna esaurito
+			if soldOut && config.NotifyOutOfStock {
+				logDeliveryResult(result.fields(), dispatchOutOfStockNotification(webhookFor(result, webhookurl), result))
+			}
+		}
+	}
+
+	if err := saveNotifyState(state); err != nil {
+		logger.Warn("Failed to save notification state", logFields{"error": err})
+	}
+
+	// Riepilogo del ciclo, stampato anche in modalità -only-available (non in quella compatta)
+	if compactOutput() {
+		return
+	}
+	outputf("Summary: %d stores checked, %d in stock, %d out of stock\n", len(results), inStock, len(results)-inStock)
+	printNotifierWarnings()
 }
 
 // Funzione per leggere gli ID dei negozi dal file
 func readStoreIDs() ([]string, error) {
+	if ids, ok := envStoreIDList(); ok {
+		return ids, nil
+	}
 	file, err := os.Open(storeIDFile)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -286,6 +891,9 @@ func readStoreIDs() ([]string, error) {
 
 // Funzione per scrivere gli ID dei negozi nel file
 func writeStoreID(id string) error {
+	if readOnlyMode {
+		return errReadOnly
+	}
 	file, err := os.OpenFile(storeIDFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 
 	if err != nil {
@@ -300,62 +908,161 @@ func writeStoreID(id string) error {
 	return nil
 }
 
+// Funzione per riscrivere l'intero elenco degli ID dei negozi nel file
+func writeStoreIDs(ids []string) error {
+	if err := backupConfig(); err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	for _, id := range ids {
+		b.WriteString(id + "\n")
+	}
+	return os.WriteFile(storeIDFile, []byte(b.String()), 0644)
+}
+
+// Esito della validazione di uno Store ID rispetto all'elenco scaricato
+type storeIDStatus int
+
+const (
+	storeIDValid storeIDStatus = iota
+	storeIDUnknown
+	storeIDOtherCountry
+)
+
+// Funzione per verificare uno Store ID rispetto agli elenchi dei paesi monitorati, indicizzati
+// per paese: valido se presente nell'elenco di uno di essi (restituito insieme allo store), di
+// un altro paese se il prefisso indica un paese non monitorato, sconosciuto altrimenti
+func validateStoreID(id string, country string, lists map[string]map[string]Location) (storeIDStatus, string, Location) {
+	// Prima il paese selezionato, poi gli altri in ordine fisso
+	for _, code := range append([]string{country}, supportedCountries...) {
+		if store, ok := lists[code][id]; ok {
+			return storeIDValid, code, store
+		}
+	}
+	for _, other := range supportedCountries {
+		if _, monitored := lists[other]; !monitored && strings.HasPrefix(strings.ToUpper(id), other) {
+			return storeIDOtherCountry, other, Location{}
+		}
+	}
+	return storeIDUnknown, "", Location{}
+}
+
+// Funzione per scaricare gli elenchi degli store del paese selezionato e degli altri paesi
+// configurati in "countries", indicizzati per paese e Store ID
+func monitoredStoreLists(country string, endpoint_url string) (map[string]map[string]Location, error) {
+	lists := make(map[string]map[string]Location)
+	for _, schedule := range monitoredCountries(country, 0) {
+		var response StoreResponse
+		if schedule.Country == country {
+			if err := loadStoreData(endpoint_url); err != nil {
+				return nil, err
+			}
+			response = storeResponse
+		} else {
+			var err error
+			if response, err = fetchStoreResponse(schedule.URL); err != nil {
+				return nil, fmt.Errorf("%s: %w", schedule.Country, err)
+			}
+		}
+
+		stores := make(map[string]Location, len(response.Locations))
+		for _, store := range response.Locations {
+			stores[store.ID] = store
+		}
+		lists[schedule.Country] = stores
+	}
+	return lists, nil
+}
+
 // Funzione per leggere l'intervallo dal file
 func readCheckInterval() (time.Duration, error) {
-	file, err := os.Open(intervalFile)
+	if interval, ok, err := envCheckInterval(); ok {
+		return interval, err
+	}
+	content, err := os.ReadFile(intervalFile)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return 0, nil // Se il file non esiste, ritorna 0
 		}
 		return 0, err
 	}
-	defer file.Close()
+	return parseInterval(string(content))
+}
 
-	var hours int
-	_, err = fmt.Fscan(file, &hours)
+// Funzione per interpretare un intervallo: una durata ("90m", "1h30m") oppure, come nei file
+// delle versioni precedenti, un numero intero di ore
+func parseInterval(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if hours, err := strconv.Atoi(value); err == nil {
+		return time.Duration(hours) * time.Hour, nil
+	}
+	interval, err := time.ParseDuration(value)
 	if err != nil {
-		return 0, err
+		return 0, fmt.Errorf("invalid interval %q: use a duration like 90m or a number of hours", value)
 	}
-	return time.Duration(hours) * time.Hour, nil
+	return interval, nil
 }
 
 // Funzione per scrivere l'intervallo nel file
 func writeCheckInterval(interval time.Duration) error {
-	file, err := os.Create(intervalFile)
-	if err != nil {
+	if err := backupConfig(); err != nil {
 		return err
 	}
-	defer file.Close()
 
-	_, err = fmt.Fprintf(file, "%d", int(interval.Hours()))
-	return err
+	// La durata viene salvata per intero ("1h30m0s"), senza troncare alle ore
+	return os.WriteFile(intervalFile, []byte(interval.String()), 0644)
 }
 
-func getStoreIDsByCity(cityName string, endpoint_url string) {
+// Funzione per stampare gli store di una città. Ritorna un errore se l'elenco degli store
+// non può essere scaricato, anche dopo i tentativi ripetuti.
+func getStoreIDsByCity(cityName string, endpoint_url string) error {
 	var storesFound bool
 
-	// Scarichiamo i dati degli store usando la funzione esistente
-	downloadStoreData(endpoint_url)
+	// Scarichiamo i dati degli store, ripetendo la richiesta in caso di errori temporanei
+	if err := loadStoreData(endpoint_url); err != nil {
+		return err
+	}
 
 	// Verifica se ci sono negozi disponibili nella risposta
 	if len(storeResponse.Locations) == 0 {
 		fmt.Println("Nessun negozio trovato nella risposta.")
-		return
+		return nil
 	}
 
 	// Convertiamo l'input dell'utente in lowercase per un confronto case-insensitive
 	lowerCityName := strings.ToLower(cityName)
 
 	// Iteriamo su tutti gli store disponibili
+	var matches []Location
 	for _, store := range storeResponse.Locations {
 		// Confrontiamo i nomi delle città convertendoli in lowercase
 		if strings.ToLower(store.City) == lowerCityName {
-			// Stampa sia lo StoreID che l'indirizzo (Address1)
-			color.Cyan("Store ID: %s, Address: %s\n", store.ID, store.Address1)
+			matches = append(matches, store)
 			storesFound = true
 		}
 	}
 
+	// Nelle grandi città mostriamo solo gli store più vicini, con la possibilità di vederli tutti
+	sortStoresByDistance(matches)
+	limit := cityLookupLimit()
+	shown := matches
+	if limit > 0 && len(matches) > limit {
+		shown = matches[:limit]
+	}
+	for _, store := range shown {
+		// Stampa sia lo StoreID che l'indirizzo (Address1)
+		color.Cyan("%sStore ID: %s, Address: %s%s\n", favoriteMark(store), store.ID, store.Address1, distanceTag(storeDistance(store)))
+	}
+	if hidden := len(matches) - len(shown); hidden > 0 {
+		fmt.Printf("...and %d more. Show all? (y/n)\n", hidden)
+		if strings.EqualFold(readLine(), "y") {
+			for _, store := range matches[len(shown):] {
+				color.Cyan("%sStore ID: %s, Address: %s%s\n", favoriteMark(store), store.ID, store.Address1, distanceTag(storeDistance(store)))
+			}
+		}
+	}
+
 	// Se non sono stati trovati store nella città indicata
 	if !storesFound {
 		color.Red("No stores found in the city: %s\n", cityName)
@@ -372,74 +1079,256 @@ func getStoreIDsByCity(cityName string, endpoint_url string) {
 			fmt.Println("No similar cities found.")
 		}
 	}
+	return nil
+}
+
+// Città presente nella risposta con il numero di store
+type cityCount struct {
+	City   string
+	Stores int
+}
+
+// Funzione per elencare in ordine alfabetico le città presenti nella risposta, con il numero di store
+func listCities() []cityCount {
+	counts := make(map[string]int)
+	for _, store := range storeResponse.Locations {
+		city := strings.TrimSpace(store.City)
+		if city != "" {
+			counts[city]++
+		}
+	}
+
+	cities := make([]cityCount, 0, len(counts))
+	for city, stores := range counts {
+		cities = append(cities, cityCount{City: city, Stores: stores})
+	}
+	sort.Slice(cities, func(i, j int) bool {
+		return cities[i].City < cities[j].City
+	})
+	return cities
 }
 
 // Funzione per suggerire città simili in caso di mancata corrispondenza esatta
 func suggestSimilarCities(inputCity string) []string {
-	var suggestions []string
+	const maxSuggestions = 3
 
 	// Convertiamo l'input in lowercase per confronto case-insensitive
-	lowerInputCity := strings.ToLower(inputCity)
-
-	// Mappa per tenere traccia delle distanze delle città trovate
-	cityDistances := make(map[string]int)
+	lowerInputCity := []rune(strings.ToLower(inputCity))
 
-	// Iteriamo su tutti gli store disponibili per calcolare le distanze
+	// Ogni città viene considerata una sola volta, anche se ha molti store
+	type candidate struct {
+		City       string
+		Lower      []rune
+		LowerBound int
+	}
+	seen := make(map[string]bool)
+	var candidates []candidate
 	for _, store := range storeResponse.Locations {
-		// Convertiamo il nome della città in lowercase per il confronto
-		lowerCityName := strings.ToLower(store.City)
-
-		// Calcoliamo la distanza di Levenshtein tra l'input e il nome della città
-		distance := levenshtein.DistanceForStrings([]rune(lowerInputCity), []rune(lowerCityName), levenshtein.DefaultOptions)
+		if seen[store.City] {
+			continue
+		}
+		seen[store.City] = true
 
-		// Salviamo la distanza nella mappa
-		cityDistances[store.City] = distance
+		lower := []rune(strings.ToLower(store.City))
+		// La differenza di lunghezza è un limite inferiore della distanza di Levenshtein
+		bound := len(lower) - len(lowerInputCity)
+		if bound < 0 {
+			bound = -bound
+		}
+		candidates = append(candidates, candidate{City: store.City, Lower: lower, LowerBound: bound})
 	}
 
-	// Troviamo le 2-3 città con la distanza più bassa
-	closestCities := findTopMatches(cityDistances, 3)
+	// Le città con lunghezza più simile vengono valutate per prime, così il limite si stringe subito
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].LowerBound < candidates[j].LowerBound
+	})
+
+	// Migliori risultati finora, ordinati come in findTopMatches (distanza, poi nome)
+	cityDistances := make(map[string]int)
+	var worst int
+	for _, c := range candidates {
+		// Nessuna città rimanente può entrare tra i migliori: a parità di distanza
+		// serve comunque il calcolo completo per l'ordine alfabetico
+		if len(cityDistances) >= maxSuggestions && c.LowerBound > worst {
+			break
+		}
+
+		distance := levenshtein.DistanceForStrings(lowerInputCity, c.Lower, levenshtein.DefaultOptions)
+		cityDistances[c.City] = distance
 
-	// Aggiungiamo le città trovate ai suggerimenti
-	suggestions = append(suggestions, closestCities...)
+		if len(cityDistances) > maxSuggestions {
+			top := findTopMatches(cityDistances, maxSuggestions)
+			kept := make(map[string]int, maxSuggestions)
+			for _, city := range top {
+				kept[city] = cityDistances[city]
+			}
+			cityDistances = kept
+		}
+		worst = 0
+		for _, d := range cityDistances {
+			if d > worst {
+				worst = d
+			}
+		}
+	}
 
-	// Ritorniamo la lista delle città suggerite
-	return suggestions
+	// Troviamo le 2-3 città con la distanza più bassa
+	return findTopMatches(cityDistances, maxSuggestions)
 }
 
-// Funzione di supporto per trovare le città con la distanza più bassa
-func findTopMatches(cityDistances map[string]int, maxMatches int) []string {
-	type cityDistance struct {
-		City     string
+// Funzione di supporto per trovare le chiavi (città, store ID...) con la distanza più bassa
+func findTopMatches(distances map[string]int, maxMatches int) []string {
+	type keyDistance struct {
+		Key      string
 		Distance int
 	}
 
 	// Convertiamo la mappa in un array di struct per ordinare le distanze
-	var sortedCities []cityDistance
-	for city, distance := range cityDistances {
-		sortedCities = append(sortedCities, cityDistance{City: city, Distance: distance})
+	var sortedKeys []keyDistance
+	for key, distance := range distances {
+		sortedKeys = append(sortedKeys, keyDistance{Key: key, Distance: distance})
 	}
 
-	// Ordiniamo l'array per distanza crescente
-	sort.Slice(sortedCities, func(i, j int) bool {
-		return sortedCities[i].Distance < sortedCities[j].Distance
+	// Ordiniamo l'array per distanza crescente, a parità di distanza in ordine alfabetico
+	sort.Slice(sortedKeys, func(i, j int) bool {
+		if sortedKeys[i].Distance != sortedKeys[j].Distance {
+			return sortedKeys[i].Distance < sortedKeys[j].Distance
+		}
+		return sortedKeys[i].Key < sortedKeys[j].Key
 	})
 
 	// Prendiamo i primi `maxMatches` risultati
 	var topMatches []string
-	for i := 0; i < maxMatches && i < len(sortedCities); i++ {
-		topMatches = append(topMatches, sortedCities[i].City)
+	for i := 0; i < maxMatches && i < len(sortedKeys); i++ {
+		topMatches = append(topMatches, sortedKeys[i].Key)
 	}
 
 	return topMatches
 }
 
+// Funzione per cercare gli store per nome o indirizzo con confronto fuzzy (Levenshtein).
+// Restituisce gli store ordinati dal più simile al meno simile.
+func searchStoresByName(query string) []Location {
+	lowerQuery := strings.ToLower(strings.TrimSpace(query))
+	if lowerQuery == "" {
+		return nil
+	}
+
+	storesByID := make(map[string]Location)
+	storeDistances := make(map[string]int)
+
+	for _, store := range storeResponse.Locations {
+		best := -1
+		for _, field := range []string{store.Name, store.Address1} {
+			lowerField := strings.ToLower(field)
+			if lowerField == "" {
+				continue
+			}
+
+			// Una corrispondenza parziale (es. nome del centro commerciale) vale come match esatto
+			distance := 0
+			if !strings.Contains(lowerField, lowerQuery) {
+				distance = levenshtein.DistanceForStrings([]rune(lowerQuery), []rune(lowerField), levenshtein.DefaultOptions)
+			}
+			if best < 0 || distance < best {
+				best = distance
+			}
+		}
+		if best < 0 {
+			continue
+		}
+
+		storesByID[store.ID] = store
+		storeDistances[store.ID] = best
+	}
+
+	var matches []Location
+	for _, id := range findTopMatches(storeDistances, 5) {
+		matches = append(matches, storesByID[id])
+	}
+	return matches
+}
+
+// Funzione per leggere una riga intera da stdin (anche con spazi), ignorando le righe vuote
+// lasciate da una precedente fmt.Scan. Legge un byte alla volta per non sottrarre input a fmt.Scan.
+func readLine() string {
+	line, _ := readLineErr()
+	return line
+}
+
+// Come readLine, ma restituisce l'errore di lettura (io.EOF con lo standard input chiuso)
+// quando non è rimasta nessuna riga da leggere
+func readLineErr() (string, error) {
+	var line []byte
+	buf := make([]byte, 1)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if n == 0 || err != nil {
+			if trimmed := strings.TrimSpace(string(line)); trimmed != "" {
+				return trimmed, nil
+			}
+			if err == nil {
+				err = io.EOF
+			}
+			return "", err
+		}
+		if buf[0] == '\n' {
+			if trimmed := strings.TrimSpace(string(line)); trimmed != "" {
+				return trimmed, nil
+			}
+			line = line[:0]
+			continue
+		}
+		line = append(line, buf[0])
+	}
+}
+
+// Come readLine, ma una riga vuota (solo Invio) viene restituita come stringa vuota, così come
+// lo standard input chiuso: da usare per le domande in cui l'input vuoto ha un significato
+// ("leave empty to keep it")
+func readOptionalLine() string {
+	var line []byte
+	buf := make([]byte, 1)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if n == 0 || err != nil || buf[0] == '\n' {
+			return strings.TrimSpace(string(line))
+		}
+		line = append(line, buf[0])
+	}
+}
+
+// Funzione per ottenere l'url dell'endpoint in base al paese (FR come default), con l'area
+// di ricerca configurata
+func endpointForCountry(country string) string {
+	return applyStoreServices(applySearchArea(baseEndpointForCountry(country), searchAreaFor(country)), config.StoreServices)
+}
+
+// Url predefinito dell'endpoint di un paese, senza personalizzazioni
+func baseEndpointForCountry(country string) string {
+	switch country {
+	case "IT":
+		return endpoint_url_it
+	case "DE":
+		return endpoint_url_de
+	default:
+		return endpoint_url_fr
+	}
+}
+
 // Funzione per salvare la selezione del paese su un file
 func writeCountrySelection(country string) error {
+	if err := backupConfig(); err != nil {
+		return err
+	}
 	return os.WriteFile("country_selection.txt", []byte(country), 0644)
 }
 
 // Funzione per leggere la selezione del paese dal file
 func readCountrySelection() (string, error) {
+	if country := strings.TrimSpace(os.Getenv(envCountry)); country != "" {
+		return strings.ToUpper(country), nil
+	}
 	content, err := os.ReadFile("country_selection.txt")
 	if err != nil {
 		return "", err
@@ -450,12 +1339,25 @@ func readCountrySelection() (string, error) {
 func getWebHookUrl() string {
 	fmt.Println("Please enter your Discord webhook URL:")
 	var webhookURL string
-	fmt.Scan(&webhookURL)
+	for {
+		// Con lo standard input chiuso (EOF) non arriverà mai un url valido: il webhook
+		// salvato resta invariato
+		if _, err := fmt.Scan(&webhookURL); err != nil {
+			color.Red("Webhook URL not changed: %v\n", err)
+			return ""
+		}
+		webhookURL = strings.TrimSpace(webhookURL)
+		err := validateWebhookURL(webhookURL)
+		if err == nil {
+			break
+		}
+		color.Red("%v. Please enter a valid URL:\n", err)
+	}
 
 	// Salva l'URL nel file
 	err := writeWebhookURL(webhookURL)
 	if err != nil {
-		log.Fatalf("Error saving webhook URL: %v", err)
+		persistFailed("Error saving webhook URL: %v", err)
 	}
 
 	color.Green("Webhook URL saved successfully!")
@@ -464,18 +1366,153 @@ func getWebHookUrl() string {
 
 // Funzione per scrivere l'URL del webhook nel file
 func writeWebhookURL(url string) error {
+	// Con il portachiavi abilitato il file viene usato solo se il portachiavi non è disponibile
+	if writeSecret(secretWebhookURL, url) {
+		return nil
+	}
+	if err := backupConfig(); err != nil {
+		return err
+	}
 	return os.WriteFile("webhook_url.txt", []byte(url), 0644)
 }
 
 func readWebhookURL() (string, error) {
+	if value := strings.TrimSpace(os.Getenv(envWebhook)); value != "" {
+		return value, nil
+	}
+	if value, ok := readSecret(secretWebhookURL); ok {
+		return strings.TrimSpace(value), nil
+	}
 	content, err := os.ReadFile("webhook_url.txt")
 	if err != nil {
 		return "", err
 	}
-	return string(content), nil
+	// Un a capo finale o spazi nel file renderebbero non valide le richieste POST
+	return strings.TrimSpace(string(content)), nil
+}
+
+// Funzione per verificare che l'URL del webhook sia plausibile (http/https con un host)
+func validateWebhookURL(webhookURL string) error {
+	if webhookURL == "" {
+		return errors.New("webhook URL is empty")
+	}
+	u, err := url.Parse(webhookURL)
+	if err != nil {
+		return fmt.Errorf("invalid webhook URL: %v", err)
+	}
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return fmt.Errorf("invalid webhook URL %q: scheme must be http or https", webhookURL)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("invalid webhook URL %q: missing host", webhookURL)
+	}
+	return nil
 }
 
 func main() {
+	flag.Parse()
+
+	if *logFormatFlag != "text" && *logFormatFlag != "json" {
+		fatalConfig("Invalid -log-format %q: use text or json", *logFormatFlag)
+	}
+	minLevel, err := parseLogLevel(*logLevelFlag)
+	if err != nil {
+		fatalConfig("Invalid -log-level: %v", err)
+	}
+	logger = newLogger(stderrOutput, *logFormatFlag, minLevel)
+	outboundLimiter = newInflightLimiter(*maxInflightFlag)
+	enableReadOnlyIfNeeded()
+
+	cmd, cmdFlags, err := parseCommand(flag.Args())
+	if err != nil {
+		if err != flag.ErrHelp {
+			fmt.Fprintf(os.Stderr, "%v\n\n", err)
+			printUsage()
+		}
+		os.Exit(exitConfigError)
+	}
+	if cmd != nil {
+		switch cmd.Name {
+		case "run":
+			*snipeFlag = true
+		case "check":
+			*onceFlag = true
+		case "config":
+			if action := cmdFlags.Arg(0); action == "" || action == "validate" {
+				*validateConfigFlag = true
+			}
+		}
+	}
+
+	if *onceFlag {
+		*snipeFlag = true
+		*countFlag = 1
+	}
+
+	if *ackFlag != "" {
+		// Il formato dei file di stato dipende dalla configurazione
+		if cfg, err := loadConfig(); err == nil {
+			config = cfg
+		}
+		storeID, productID, _ := strings.Cut(*ackFlag, ":")
+		count, err := acknowledgeRestock(storeID, productID)
+		if err != nil {
+			fatalError("Errore nel riconoscimento della notifica: %v", err)
+		}
+		fmt.Printf("Acknowledged %d in-stock entries for %s.\n", count, *ackFlag)
+		return
+	}
+
+	if *validateConfigFlag {
+		if !printConfigReport(validateConfiguration()) {
+			os.Exit(exitConfigError)
+		}
+		return
+	}
+
+	config, err = loadConfig()
+	if err != nil {
+		fatalConfig("Errore nella lettura della configurazione: %v", err)
+	}
+	messageTemplate = loadMessageTemplate()
+	restoreLastAvailability()
+	loadNotifierHealth()
+
+	if *probeFlag != "" {
+		runProbe(*probeFlag)
+	}
+
+	if *resetStatsFlag {
+		if err := resetStats(); err != nil {
+			fatalError("Errore nell'azzeramento delle statistiche: %v", err)
+		}
+		fmt.Println("Statistics reset.")
+		return
+	}
+
+	if *recordPinsFlag {
+		pins, err := recordCertPins()
+		if err != nil {
+			fatalError("Errore nella registrazione dei pin dei certificati: %v", err)
+		}
+		for host, pin := range pins {
+			fmt.Printf("%s: %s\n", host, pin)
+		}
+		return
+	}
+
+	if *simulateRestockFlag != "" {
+		storeID, productID, _ := strings.Cut(*simulateRestockFlag, ":")
+		if err := simulateRestock(storeID, productID, *persistFlag); err != nil {
+			fatalError("Errore nella simulazione del restock: %v", err)
+		}
+		return
+	}
+
+	// Sottocomandi non interattivi (list, add-store, city, ...); menu, run e check proseguono
+	if cmd != nil && cmd.Run != nil {
+		os.Exit(cmd.Run(cmdFlags))
+	}
 
 	// Ciclo continuo fino a quando l'utente non sceglie di avviare il programma (opzione 4)
 	for {
@@ -484,45 +1521,30 @@ func main() {
 		country, err := readCountrySelection()
 		if err != nil {
 			fmt.Println("Please select your country (IT, DE, FR):")
-			var selectedCountry string
-			for {
-				fmt.Scan(&selectedCountry)
-				selectedCountry = strings.ToUpper(selectedCountry)
-				if selectedCountry == "IT" || selectedCountry == "DE" || selectedCountry == "FR" {
-					err := writeCountrySelection(selectedCountry)
-					if err != nil {
-						log.Fatalf("Errore nella scrittura della selezione del paese: %v", err)
-					}
-					country = selectedCountry
-					break
-				} else {
-					fmt.Println("Invalid selection. Please select either IT, DE, or FR.")
-				}
+			selectedCountry := promptCountry()
+			err := writeCountrySelection(selectedCountry)
+			if err != nil {
+				persistFailed("Errore nella scrittura della selezione del paese: %v", err)
 			}
+			country = selectedCountry
 		} else {
 			fmt.Printf("Country selected: %s\n", country)
 		}
 
 		// Si definisce l'url corretto in base alla scelta
-		var choosen_region_url string
-		if country == "IT" {
-			choosen_region_url = endpoint_url_it
-		} else if country == "DE" {
-			choosen_region_url = endpoint_url_de
-		} else {
-			choosen_region_url = endpoint_url_fr
-		}
+		choosen_region_url := endpointForCountry(country)
 
 
 		hookurl, error := readWebhookURL()
 		var hook_status bool
 		if error != nil {
-			color.Red("Error reading webhook url, please check the file.")
-		}
-		if len(hookurl) > 1 {
-			hook_status = true
+			if !os.IsNotExist(error) {
+				color.Red("Error reading webhook url, please check the file.")
+			}
+		} else if err := validateWebhookURL(hookurl); err != nil {
+			color.Red("%v, please check webhook_url.txt.\n", err)
 		} else {
-			hook_status = false
+			hook_status = true
 		}
 
 
@@ -538,13 +1560,13 @@ func main() {
 		var storeIDs []string
 		storeIDs, err = readStoreIDs()
 		if err != nil {
-			log.Fatalf("Errore nella lettura degli ID dei negozi: %v", err)
+			fatalConfig("Errore nella lettura degli ID dei negozi: %v", err)
 		}
 
 		// Lettura del tempo di intervallo
 		checkInterval, err := readCheckInterval()
 		if err != nil {
-			log.Fatal("Errore nella lettura dell'intervallo di controllo: %v", err)
+			fatalConfig("Errore nella lettura dell'intervallo di controllo: %v", err)
 		}
 
 		// Stampa gli store ID attuali e il tempo di intervallo
@@ -558,29 +1580,52 @@ func main() {
 		fmt.Println("+-+-+-+-+-+-+-+-+-+-+-+")
 		fmt.Println()
 
-		// Menu di selezione
-		fmt.Println("Please enter an option: ")
-		fmt.Println("1) Add StoreID")
-		fmt.Println("2) Set Interval for Availability Checks ")
-		fmt.Println("3) City StoreIDs Lookup")
-		fmt.Println("4) Start Sniper")
-
-		fmt.Println()
-		fmt.Print("5) Change Country - ")
-		fmt.Print("Country Selected: ")
-		color.Green("%s", country)
-		fmt.Print("")
-		fmt.Print("6) Add WebHook Url - ")
-		if !(hook_status) {
-			color.Red("Not Added yet ❌")
+		var user_input int
+		if *snipeFlag {
+			// Avvio diretto dello sniper senza passare dal menu (-snipe, -once)
+			user_input = 4
 		} else {
-			color.Green("Added Already ✅")
-		}
-		fmt.Println("------------------------")
-		fmt.Println()
+			// Menu di selezione
+			fmt.Println("Please enter an option: ")
+			fmt.Println("1) Add StoreID")
+			fmt.Println("2) Set Interval for Availability Checks ")
+			fmt.Println("3) City StoreIDs Lookup")
+			fmt.Println("4) Start Sniper")
 
-		var user_input int
-		fmt.Scan(&user_input)
+			fmt.Println()
+			fmt.Print("5) Change Country - ")
+			fmt.Print("Country Selected: ")
+			color.Green("%s", country)
+			fmt.Print("")
+			fmt.Print("6) Add WebHook Url - ")
+			if !(hook_status) {
+				color.Red("Not Added yet ❌")
+			} else {
+				color.Green("Added Already ✅")
+			}
+			fmt.Println("7) Search Store by Name/Address")
+			fmt.Println("8) Import StoreIDs (CSV/JSON)")
+			fmt.Println("9) Export StoreIDs (CSV/JSON)")
+			fmt.Println("10) Restore Configuration Backup")
+			fmt.Println("11) List Cities with Stores")
+			fmt.Println("12) Validate Monitored StoreIDs")
+			fmt.Println("13) Acknowledge a Restock")
+			fmt.Println("14) Compare Stores for a Product")
+			fmt.Println("15) Move Secrets to the OS Keyring")
+			fmt.Println("16) Store Details and Product Availability")
+			fmt.Println("17) View Statistics")
+			fmt.Println("18) Validate Configuration")
+			fmt.Println("19) Export Shareable Configuration")
+			fmt.Println("20) Import Shared Configuration")
+			fmt.Println("21) Notification Channel Health")
+			fmt.Println("22) Edit Settings")
+			fmt.Println("23) Custom Search Area (coordinates and radius)")
+			fmt.Println("24) Store Services Filter")
+			fmt.Println("------------------------")
+			fmt.Println()
+
+			fmt.Scan(&user_input)
+		}
 
 		switch user_input {
 		case 1:
@@ -596,7 +1641,7 @@ func main() {
 
 					err := writeStoreID(newID)
 					if err != nil {
-						log.Fatalf("Errore nella scrittura dell'ID del negozio: %v", err)
+						persistFailed("Errore nella scrittura dell'ID del negozio: %v", err)
 					}
 					fmt.Println("StoreID added successfully!")
 
@@ -609,14 +1654,16 @@ func main() {
 
 		case 2:
 			// Impostazione dell'intervallo di controllo
-			fmt.Println("Set the check interval (in hours):")
-			var hours int
-			fmt.Scan(&hours)
-			checkInterval = time.Duration(hours) * time.Hour
+			interval := promptInterval(checkInterval)
+			if interval == checkInterval {
+				fmt.Printf("Check interval unchanged (%v).\n", checkInterval)
+				break
+			}
+			checkInterval = interval
 			if err := writeCheckInterval(checkInterval); err != nil {
-				log.Fatalf("Errore nella scrittura dell'intervallo di controllo: %v", err)
+				persistFailed("Errore nella scrittura dell'intervallo di controllo: %v", err)
 			}
-			fmt.Printf("Check interval set to %d hours.\n", hours)
+			fmt.Printf("Check interval set to %v.\n", checkInterval)
 
 		case 3:
 			// Ricerca degli Store ID per città
@@ -628,32 +1675,32 @@ func main() {
 			upper := strings.ToUpper(cityName)
 
 			color.Magenta("Stores found for %s: ", cityName)
-			getStoreIDsByCity(upper, choosen_region_url)
+			if err := getStoreIDsByCity(upper, choosen_region_url); err != nil {
+				printLookupError(err)
+			}
 
 			fmt.Println()
 
 		case 4:
-			if len(storeIDs) == 0 {
-				log.Fatal("Error: Store ID List is empty")
+			if len(storeIDs) == 0 && len(config.MonitorCities) == 0 {
+				fatalConfig("Error: Store ID List is empty")
 			} else {
 				fmt.Println()
 				fmt.Println("Starting sniper...")
 				fmt.Println()
-				for {
-					checkProductAvailability(storeIDs, choosen_region_url, hookurl)
-					//Timestamp
-					timestamp := time.Now().Format("2006-01-02 15:04:05")
-					fmt.Printf("Checked at: %s\n", timestamp)
-					fmt.Println()
-
-					// Inizializza il timer per l'output
-					for remaining := checkInterval; remaining > 0; remaining -= time.Second {
-						fmt.Printf("\r"+redColor+"Leave this Terminal Page open, next check will be in %v seconds"+resetColor, int(remaining.Seconds()))
-						time.Sleep(time.Second)
-					}
-					fmt.Println()
-
+				settings := runtimeSettings{
+					StoreIDs: storeIDs,
+					Interval: checkInterval,
+					Webhook:  hookurl,
+					Country:  country,
+					Config:   config,
+				}
+				code := runSniper(settings, *countFlag)
+				// Numero di cicli richiesto con -count/-once completato
+				if *snipeFlag {
+					os.Exit(code)
 				}
+				return
 			}
 
 		case 5:
@@ -665,11 +1712,10 @@ func main() {
 				fmt.Println("Please enter the new region (e.g., IT, FR, DE):")
 				fmt.Println()
 
-				var newRegion string
-				fmt.Scan(&newRegion)
+				newRegion := promptCountry()
 				// Scrive la nuova regione nel file
 				if err := writeCountrySelection(newRegion); err != nil {
-					log.Fatalf("Errore nella scrittura della selezione della regione: %v", err)
+					persistFailed("Errore nella scrittura della selezione della regione: %v", err)
 				}
 
 				color.Green("Region changed to %s.\n", newRegion)
@@ -679,6 +1725,255 @@ func main() {
 		case 6:
 			getWebHookUrl()
 
+		case 7:
+			// Ricerca fuzzy degli store per nome o indirizzo
+			fmt.Println("Please write the store name or address:  (Example: Via Torino/Les Halles)")
+			query := readLine()
+
+			if !loadStoreDataForMenu(choosen_region_url) {
+				break
+			}
+			matches := searchStoresByName(query)
+			if len(matches) == 0 {
+				color.Red("No stores found for: %s\n", query)
+			} else {
+				color.Magenta("Best matches for %s: ", query)
+				for _, store := range matches {
+					color.Cyan("Store ID: %s, Name: %s, Address: %s, City: %s%s\n", store.ID, store.Name, store.Address1, store.City, distanceTag(storeDistance(store)))
+				}
+			}
+			fmt.Println()
+
+		case 8:
+			fmt.Println("Enter the path of the file to import (.csv with columns id,label or .json):")
+			path := readLine()
+
+			added, skipped, err := importStoreIDs(path)
+			if err != nil {
+				color.Red("Import failed: %v\n", err)
+			} else {
+				color.Green("Import completed: %d added, %d skipped (duplicates or invalid).\n", added, skipped)
+			}
+
+		case 9:
+			fmt.Println("Enter the path of the export file (.csv or .json):")
+			path := readLine()
+
+			count, err := exportStoreIDs(path)
+			if err != nil {
+				color.Red("Export failed: %v\n", err)
+			} else {
+				color.Green("Exported %d StoreIDs to %s\n", count, path)
+			}
+
+		case 10:
+			backups, err := listBackups()
+			if err != nil {
+				color.Red("Error reading backups: %v\n", err)
+				break
+			}
+			if len(backups) == 0 {
+				fmt.Println("No backups available yet.")
+				break
+			}
+
+			fmt.Println("Available backups (oldest first):")
+			for i, name := range backups {
+				fmt.Printf("%d) %s\n", i+1, name)
+			}
+			fmt.Println("Enter the number of the backup to restore (0 to cancel):")
+			var choice int
+			fmt.Scan(&choice)
+			if choice < 1 || choice > len(backups) {
+				fmt.Println("Restore cancelled.")
+				break
+			}
+
+			if err := restoreBackup(backups[choice-1]); err != nil {
+				color.Red("Restore failed: %v\n", err)
+			} else {
+				color.Green("Configuration restored from %s. Restart the sniper to apply all settings.\n", backups[choice-1])
+			}
+
+		case 11:
+			// Elenco delle città, raggruppate per iniziale
+			if !loadStoreDataForMenu(choosen_region_url) {
+				break
+			}
+			cities := listCities()
+			if len(cities) == 0 {
+				fmt.Println("Nessun negozio trovato nella risposta.")
+				break
+			}
+
+			color.Magenta("%d cities found:", len(cities))
+			var initial rune
+			for _, city := range cities {
+				if first := []rune(city.City)[0]; first != initial {
+					initial = first
+					fmt.Println()
+					color.Cyan("%c", initial)
+				}
+				fmt.Printf("  %s (%d)\n", city.City, city.Stores)
+			}
+			fmt.Println()
+
+		case 12:
+			if len(storeIDs) == 0 {
+				fmt.Println("The Store ID List is empty.")
+				break
+			}
+
+			// Gli ID dei paesi in "countries" vanno verificati con l'elenco del loro paese
+			lists, err := monitoredStoreLists(country, choosen_region_url)
+			if err != nil {
+				printLookupError(err)
+				break
+			}
+
+			var validIDs []string
+			for _, id := range storeIDs {
+				status, storeCountry, store := validateStoreID(id, country, lists)
+				switch status {
+				case storeIDValid:
+					if storeCountry != country {
+						color.Green("✅ %s (%s): %s, %s\n", id, storeCountry, store.Name, store.Address1)
+					} else {
+						color.Green("✅ %s: %s, %s\n", id, store.Name, store.Address1)
+					}
+					validIDs = append(validIDs, id)
+				case storeIDOtherCountry:
+					color.Yellow("🌍 %s: belongs to %s, which is not monitored (add it to \"countries\")\n", id, storeCountry)
+				default:
+					color.Red("❌ %s: unknown store\n", id)
+				}
+			}
+
+			invalid := len(storeIDs) - len(validIDs)
+			if invalid == 0 {
+				color.Green("All %d StoreIDs are valid.\n", len(storeIDs))
+				break
+			}
+
+			fmt.Printf("%d invalid StoreIDs found. Do you want to remove them? Please enter y or n\n", invalid)
+			var choice string
+			fmt.Scan(&choice)
+			if choice == "y" {
+				if err := writeStoreIDs(validIDs); err != nil {
+					persistFailed("Errore nella scrittura degli ID dei negozi: %v", err)
+				}
+				color.Green("Removed %d StoreIDs.\n", invalid)
+			}
+
+		case 13:
+			state, err := loadNotifyState()
+			if err != nil {
+				color.Red("Error reading notification state: %v\n", err)
+				break
+			}
+			entries := availableEntries(state)
+			if len(entries) == 0 {
+				fmt.Println("No products are currently in stock.")
+				break
+			}
+
+			for i, entry := range entries {
+				status := "alerting"
+				if entry.Acknowledged {
+					status = "acknowledged"
+				}
+				fmt.Printf("%d) Store %s, Product %s (%s) - %s\n", i+1, entry.StoreID, entry.ProductID, entry.Country, status)
+			}
+			fmt.Println("Enter the number to acknowledge (0 to cancel):")
+			var choice int
+			fmt.Scan(&choice)
+			if choice < 1 || choice > len(entries) {
+				break
+			}
+
+			entry := entries[choice-1]
+			if _, err := acknowledgeRestock(entry.StoreID, entry.ProductID); err != nil {
+				color.Red("Error saving acknowledgement: %v\n", err)
+			} else {
+				color.Green("Alerts for store %s paused until the product goes out of stock.\n", entry.StoreID)
+			}
+
+		case 14:
+			if len(storeIDs) == 0 {
+				fmt.Println("The Store ID List is empty.")
+				break
+			}
+			fmt.Printf("Please enter the product ID (leave empty for %s):\n", defaultProductID)
+			pid := readOptionalLine()
+			if pid == "" {
+				pid = defaultProductID
+			}
+			if err := compareStores(storeIDs, choosen_region_url, pid); err != nil {
+				color.Red("Error checking availability: %v\n", err)
+			}
+
+		case 15:
+			migrated, err := migrateSecretsToKeyring()
+			if err != nil {
+				color.Red("Error moving secrets to the keyring: %v\n", err)
+			}
+			if len(migrated) == 0 && err == nil {
+				fmt.Println("No plaintext secrets found.")
+			} else if len(migrated) > 0 {
+				color.Green("Moved to the keyring: %s\n", strings.Join(migrated, ", "))
+			}
+
+		case 16:
+			fmt.Println("Please enter the Store ID:")
+			storeID := readLine()
+			if err := showStoreDetails(storeID, choosen_region_url); err != nil {
+				color.Red("Error: %v\n", err)
+			}
+
+		case 17:
+			if err := printStats(); err != nil {
+				color.Red("Error reading statistics: %v\n", err)
+			}
+
+		case 18:
+			printConfigReport(validateConfiguration())
+
+		case 19:
+			fmt.Println("Enter the path of the file to write (e.g. preset.json):")
+			path := readLine()
+
+			stripped, err := exportSharedConfig(path)
+			if err != nil {
+				color.Red("Export failed: %v\n", err)
+				break
+			}
+			color.Green("Configuration exported to %s\n", path)
+			if len(stripped) > 0 {
+				fmt.Printf("Secrets replaced with %s: %s\n", secretPlaceholder, strings.Join(stripped, ", "))
+			}
+
+		case 20:
+			fmt.Println("Enter the path of the shared configuration file:")
+			path := readLine()
+
+			if err := importSharedConfig(path); err != nil {
+				color.Red("Import failed: %v\n", err)
+			} else {
+				color.Green("Configuration imported. The previous files were saved in backups/.\n")
+			}
+
+		case 21:
+			showNotifierHealth()
+
+		case 22:
+			editSettings()
+
+		case 23:
+			editSearchArea()
+
+		case 24:
+			editStoreServices()
+
 		default:
 			fmt.Println("Invalid option. Please check your input and try again.")
 		}
@@ -0,0 +1,469 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/texttheater/golang-levenshtein/levenshtein"
+)
+
+func TestSplitDiscordMessage(t *testing.T) {
+	line := strings.Repeat("a", 99) + "\n" // 100 caratteri per riga
+	tests := []struct {
+		name    string
+		message string
+		chunks  int
+	}{
+		{name: "exactly the limit", message: strings.Repeat("x", discordMessageLimit), chunks: 1},
+		{name: "oversized payload", message: strings.Repeat(line, 45), chunks: 3},
+		{name: "single line over the limit", message: strings.Repeat("x", 2*discordMessageLimit+500), chunks: 3},
+		{name: "multibyte runes", message: strings.Repeat("é🛍️", 1500), chunks: 3},
+		{name: "blank lines at the cut", message: "a\n" + strings.Repeat("\n", 2500) + "b", chunks: 2},
+		{name: "only blank lines before the cut", message: strings.Repeat("\n", 2500) + "b", chunks: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks := splitDiscordMessage(tt.message, discordMessageLimit)
+			if len(chunks) != tt.chunks {
+				t.Errorf("got %d chunks, want %d", len(chunks), tt.chunks)
+			}
+			for i, chunk := range chunks {
+				if n := utf8.RuneCountInString(chunk); n > discordMessageLimit {
+					t.Errorf("chunk %d has %d characters, over the limit", i, n)
+				}
+				if strings.TrimSpace(chunk) == "" {
+					t.Errorf("chunk %d is empty", i)
+				}
+				if !utf8.ValidString(chunk) {
+					t.Errorf("chunk %d splits a multibyte rune", i)
+				}
+			}
+			// Si perdono al massimo gli a capo sui punti di taglio
+			strip := func(s string) string { return strings.ReplaceAll(s, "\n", "") }
+			if got := strip(strings.Join(chunks, "")); got != strip(tt.message) {
+				t.Errorf("joined chunks differ from the message")
+			}
+		})
+	}
+}
+
+// Funzione per leggere una risposta dello store locator da testdata/
+func loadResponseFixture(t *testing.T, name string) StoreResponse {
+	t.Helper()
+	content, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var response StoreResponse
+	if err := json.Unmarshal(content, &response); err != nil {
+		t.Fatalf("decoding %s: %v", name, err)
+	}
+	return response
+}
+
+func TestDedupeLocations(t *testing.T) {
+	response := loadResponseFixture(t, "duplicate_stores.json")
+
+	locations, collapsed := dedupeLocations(response.Locations)
+	if collapsed != 2 {
+		t.Errorf("collapsed = %d, want 2", collapsed)
+	}
+	if len(locations) != 2 {
+		t.Fatalf("got %d locations, want 2", len(locations))
+	}
+	seen := make(map[string]bool)
+	for _, location := range locations {
+		if seen[location.ID] {
+			t.Errorf("store %s appears more than once", location.ID)
+		}
+		seen[location.ID] = true
+	}
+
+	// I flag delle voci duplicate vengono uniti nella prima
+	milano := locations[0]
+	if milano.ID != "1234" {
+		t.Fatalf("first location = %s, want 1234 (order of first appearance)", milano.ID)
+	}
+	if !milano.ProductAvailability || !milano.EnableClickCollect || !milano.EnableDeliveryToStore {
+		t.Errorf("merged flags = availability %v, click & collect %v, delivery %v, want all true",
+			milano.ProductAvailability, milano.EnableClickCollect, milano.EnableDeliveryToStore)
+	}
+	if len(milano.StoreServices) != 2 {
+		t.Errorf("merged services = %v, want click-collect and delivery-to-store once each", milano.StoreServices)
+	}
+}
+
+// Risposta di prova con molti store per città, come quelle dei raggi di ricerca ampi
+func benchmarkCityResponse() []Location {
+	cities := []string{"Milano", "Roma", "Torino", "Napoli", "Bologna", "Firenze", "Genova", "Venezia",
+		"Verona", "Padova", "Bari", "Catania", "Palermo", "Messina", "Reggio Calabria", "Cagliari",
+		"Trieste", "Brescia", "Bergamo", "Monza", "Parma", "Modena", "Rimini", "Pescara", "Salerno"}
+	var locations []Location
+	for i := 0; i < 600; i++ {
+		city := cities[i%len(cities)]
+		if i%4 == 0 {
+			// Varianti di maiuscole e quartieri, come nelle risposte reali
+			city = strings.ToUpper(city)
+		}
+		locations = append(locations, Location{ID: strconv.Itoa(1000 + i), City: city})
+	}
+	return locations
+}
+
+// Versione di riferimento senza deduplicazione né prefiltro, per confrontare risultati e tempi
+func naiveCitySuggestions(inputCity string) []string {
+	lowerInputCity := []rune(strings.ToLower(inputCity))
+	cityDistances := make(map[string]int)
+	for _, store := range storeResponse.Locations {
+		cityDistances[store.City] = levenshtein.DistanceForStrings(lowerInputCity, []rune(strings.ToLower(store.City)), levenshtein.DefaultOptions)
+	}
+	return findTopMatches(cityDistances, 3)
+}
+
+func TestSuggestSimilarCitiesMatchesNaive(t *testing.T) {
+	previous := storeResponse
+	t.Cleanup(func() { storeResponse = previous })
+	storeResponse.Locations = benchmarkCityResponse()
+
+	for _, input := range []string{"Milno", "rome", "Reggio", "Bolonia", "x", "Palermo Centro"} {
+		got, want := suggestSimilarCities(input), naiveCitySuggestions(input)
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("suggestSimilarCities(%q) = %v, want %v", input, got, want)
+		}
+	}
+}
+
+func BenchmarkCitySuggestions(b *testing.B) {
+	previous := storeResponse
+	b.Cleanup(func() { storeResponse = previous })
+	storeResponse.Locations = benchmarkCityResponse()
+
+	b.Run("deduplicated", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			suggestSimilarCities("Milno")
+		}
+	})
+	b.Run("per store", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			naiveCitySuggestions("Milno")
+		}
+	})
+}
+
+func TestNormalizeServices(t *testing.T) {
+	services := []StoreService{
+		{ID: "delivery-to-store", Name: "Delivery to store"},
+		{ID: "click-collect", Name: "Click & Collect"},
+		{ID: "beauty-service", Name: "Beauty Service"},
+		{ID: "click-collect", Name: "Click & Collect (duplicate)"},
+		{ID: "delivery-to-store", Name: "Delivery to store"},
+	}
+
+	got := normalizeServices(services)
+	want := []StoreService{
+		{ID: "beauty-service", Name: "Beauty Service"},
+		{ID: "click-collect", Name: "Click & Collect"},
+		{ID: "delivery-to-store", Name: "Delivery to store"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("normalizeServices() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("service %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+	if names := serviceNames(services); names != "Beauty Service, Click & Collect, Delivery to store" {
+		t.Errorf("serviceNames() = %q", names)
+	}
+	// L'elenco originale non viene modificato
+	if services[0].ID != "delivery-to-store" || len(services) != 5 {
+		t.Errorf("input changed: %v", services)
+	}
+}
+
+func TestFlexFloat64Fields(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		want    [3]FlexFloat64 // latitude, longitude, distance
+		wantErr bool
+	}{
+		{name: "numbers", json: `{"latitude": 45.4642, "longitude": 9.19, "distance": 1.25}`, want: [3]FlexFloat64{45.4642, 9.19, 1.25}},
+		{name: "strings", json: `{"latitude": "45.4642", "longitude": "9.19", "distance": "1.25"}`, want: [3]FlexFloat64{45.4642, 9.19, 1.25}},
+		{name: "decimal comma", json: `{"latitude": "45,4642", "longitude": " 9,19 ", "distance": "1,25"}`, want: [3]FlexFloat64{45.4642, 9.19, 1.25}},
+		{name: "empty strings", json: `{"latitude": "", "longitude": "", "distance": ""}`, want: [3]FlexFloat64{0, 0, 0}},
+		{name: "null", json: `{"latitude": null, "longitude": null, "distance": null}`, want: [3]FlexFloat64{0, 0, 0}},
+		{name: "mixed", json: `{"latitude": 48.8716, "longitude": "2.3035", "distance": null}`, want: [3]FlexFloat64{48.8716, 2.3035, 0}},
+		{name: "not a number", json: `{"latitude": "north", "longitude": 9.19, "distance": 1}`, wantErr: true},
+		{name: "wrong type", json: `{"latitude": true, "longitude": 9.19, "distance": 1}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var location Location
+			err := json.Unmarshal([]byte(tt.json), &location)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("decoded %s without error", tt.json)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decoding %s: %v", tt.json, err)
+			}
+			got := [3]FlexFloat64{location.Latitude, location.Longitude, location.Distance}
+			if got != tt.want {
+				t.Errorf("latitude, longitude, distance = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindAvailabilityFlag(t *testing.T) {
+	tests := []struct {
+		name   string
+		json   string
+		want   bool
+		wantOK bool
+	}{
+		{name: "top level", json: `{"inStock": true}`, want: true, wantOK: true},
+		{name: "key priority", json: `{"inStock": true, "available": false}`, want: false, wantOK: true},
+		{name: "shallowest wins", json: `{"a": {"b": {"available": true}}, "c": {"inStock": false}}`, want: false, wantOK: true},
+		{name: "sibling order", json: `{"z": {"available": true}, "a": {"available": false}, "m": {"inStock": true}}`, want: false, wantOK: true},
+		{name: "inside array", json: `{"items": [{"name": "x"}, {"in_stock": true}]}`, want: true, wantOK: true},
+		{name: "missing", json: `{"product": {"available": "yes"}}`, wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var value interface{}
+			if err := json.Unmarshal([]byte(tt.json), &value); err != nil {
+				t.Fatal(err)
+			}
+			// Ripetiamo la ricerca per far emergere un eventuale ordine casuale delle mappe
+			for i := 0; i < 50; i++ {
+				got, ok := findAvailabilityFlag(value)
+				if ok != tt.wantOK || got != tt.want {
+					t.Fatalf("findAvailabilityFlag(%s) = %v, %v, want %v, %v", tt.json, got, ok, tt.want, tt.wantOK)
+				}
+			}
+		})
+	}
+}
+
+func TestResponseCache(t *testing.T) {
+	endpoint := "https://www.sephora.it/on/demandware.store/Stores-FindStores?pid=P1"
+	if key := responseCacheKey(cacheBustedURL(endpoint)); key != endpoint {
+		t.Errorf("responseCacheKey(cacheBustedURL(url)) = %q, want %q", key, endpoint)
+	}
+	if key := responseCacheKey(cacheBustedURL("https://www.sephora.it/stores")); key != "https://www.sephora.it/stores" {
+		t.Errorf("responseCacheKey without query = %q", key)
+	}
+
+	// La cache non supera il limite e scarta la voce usata meno di recente
+	for i := 0; i < maxCachedResponses+10; i++ {
+		storeCachedResponse(endpoint+"&n="+strconv.Itoa(i), "etag", StoreResponse{})
+		if i == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		cachedResponse(endpoint + "&n=0")
+	}
+	if n := len(responseCache.entries); n != maxCachedResponses {
+		t.Errorf("cache holds %d entries, want %d", n, maxCachedResponses)
+	}
+	if _, ok := cachedResponse(endpoint + "&n=0"); !ok {
+		t.Error("most recently used entry was evicted")
+	}
+	if _, ok := cachedResponse(endpoint + "&n=1"); ok {
+		t.Error("least recently used entry was kept")
+	}
+}
+
+func TestQuietDigestDedupe(t *testing.T) {
+	const webhook = "https://discord.com/api/webhooks/1/test"
+	defer delete(quietDigest, webhook)
+	target := notifyTarget{URL: webhook, Channel: "discord"}
+
+	restock := logFields{"store": "IT123", "product": "P1"}
+	queueQuietDigest(target, "P1 in stock at IT123 (cycle 1)", restock)
+	queueQuietDigest(target, "P1 in stock at IT123 (cycle 2)", restock)
+	queueQuietDigest(target, "P1 delivery available", logFields{"store": "IT123", "product": "P1", "fulfillment": "delivery"})
+	queueQuietDigest(target, "P2 in stock at IT123", logFields{"store": "IT123", "product": "P2"})
+	queueQuietDigest(target, "New store opened", logFields{"country": "IT"})
+	queueQuietDigest(target, "New store opened", logFields{"country": "IT"})
+
+	var got []string
+	for _, entry := range quietDigest[webhook].Entries {
+		got = append(got, entry.Message)
+	}
+	want := []string{"P1 in stock at IT123 (cycle 2)", "P1 delivery available", "P2 in stock at IT123", "New store opened", "New store opened"}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("digest = %q, want %q", got, want)
+	}
+}
+
+func TestDecodeStreamingBodyError(t *testing.T) {
+	chdirTemp(t)
+
+	tests := []struct {
+		name          string
+		body          string
+		wantTruncated bool
+	}{
+		{name: "truncated", body: `{"success": true, "locations": [{"ID": "IT1", "name": "Milano`, wantTruncated: true},
+		{name: "schema", body: `{"success": true, "locations": {"ID": "IT1"}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{
+				StatusCode:    http.StatusOK,
+				Header:        http.Header{"Content-Type": []string{"application/json"}},
+				Body:          io.NopCloser(strings.NewReader(tt.body)),
+				ContentLength: -1,
+			}
+			_, err := decodeStreamingBody(resp, "https://www.sephora.it/stores?pid=P1", nil)
+			var decodeErr *decodeError
+			if !errors.As(err, &decodeErr) {
+				t.Fatalf("error = %v, want a *decodeError", err)
+			}
+			if decodeErr.Truncated != tt.wantTruncated {
+				t.Errorf("Truncated = %v, want %v (%v)", decodeErr.Truncated, tt.wantTruncated, err)
+			}
+			if decodeErr.Snippet != tt.body || decodeErr.Length != len(tt.body) {
+				t.Errorf("snippet %q (%d bytes), want the whole body", decodeErr.Snippet, decodeErr.Length)
+			}
+			if saved, _ := os.ReadFile(decodeErr.BodyFile); string(saved) != tt.body {
+				t.Errorf("saved body = %q", saved)
+			}
+		})
+	}
+}
+
+func TestValidateSetting(t *testing.T) {
+	chdirTemp(t)
+
+	patch := func(cfg Config, key string) []byte {
+		content, err := patchConfigSetting(cfg, key)
+		if err != nil {
+			t.Fatalf("patch %s: %v", key, err)
+		}
+		return content
+	}
+
+	valid := defaultConfig()
+	valid.MinQuantity = 2
+	updated, err := validateSetting(patch(valid, "min_quantity"))
+	if err != nil {
+		t.Fatalf("valid change rejected: %v", err)
+	}
+	if updated.MinQuantity != 2 || updated.AvailabilityMode != defaultAvailabilityMode {
+		t.Errorf("updated config = %+v", updated)
+	}
+
+	nested := defaultConfig()
+	nested.Ntfy.Topic = "https://"
+	if _, err := validateSetting(patch(nested, "ntfy.topic")); err == nil {
+		t.Error("invalid ntfy.topic accepted")
+	}
+
+	crossField := defaultConfig()
+	crossField.AvailabilitySource = sourcePDP
+	if _, err := validateSetting(patch(crossField, "availability_source")); err == nil {
+		t.Error("availability_source pdp without pdp_stock_endpoint accepted")
+	}
+}
+
+func TestPatchJSONKey(t *testing.T) {
+	original := "{\n    \"min_quantity\": 1,\n    \"custom\": [1,2],\n    \"ntfy\": {\n        \"server\": \"https://ntfy.sh\"\n    }\n}\n"
+	tests := []struct {
+		name    string
+		content string
+		key     string
+		value   string
+		want    string
+	}{
+		{"existing key", original, "min_quantity", "3",
+			"{\n    \"min_quantity\": 3,\n    \"custom\": [1,2],\n    \"ntfy\": {\n        \"server\": \"https://ntfy.sh\"\n    }\n}\n"},
+		{"new nested key", original, "ntfy.topic", `"alerts"`,
+			"{\n    \"min_quantity\": 1,\n    \"custom\": [1,2],\n    \"ntfy\": {\n        \"server\": \"https://ntfy.sh\",\n        \"topic\": \"alerts\"\n    }\n}\n"},
+		{"new key", original, "language", `"it"`,
+			"{\n    \"min_quantity\": 1,\n    \"custom\": [1,2],\n    \"ntfy\": {\n        \"server\": \"https://ntfy.sh\"\n    },\n    \"language\": \"it\"\n}\n"},
+		{"missing file", "", "ntfy.topic", `"alerts"`, "{\n  \"ntfy\": {\"topic\":\"alerts\"}\n}\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := patchJSONKey([]byte(tt.content), tt.key, json.RawMessage(tt.value))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseProductVariants(t *testing.T) {
+	tests := []struct {
+		name string
+		page string
+		want []ProductVariant
+	}{
+		{
+			name: "json-ld hasVariant array",
+			page: `<script type="application/ld+json">{"@type": "ProductGroup", "hasVariant": [
+				{"sku": "P1-01", "name": "01 Ivory"}, {"sku": "P1-02", "name": "02 Beige &amp; Rose"}]}</script>`,
+			want: []ProductVariant{{PID: "P1-01", Name: "01 Ivory"}, {PID: "P1-02", Name: "02 Beige & Rose"}},
+		},
+		{
+			name: "json-ld single offer",
+			page: `<script type="application/ld+json">{"@type": "Product", "offers": {"sku": "P2-50", "name": "50 ml"}}</script>`,
+			want: []ProductVariant{{PID: "P2-50", Name: "50 ml"}},
+		},
+		{
+			name: "json-ld single variant in graph",
+			page: `<script type='application/ld+json'>{"@graph": [{"@type": "WebPage"}, {"hasVariant": {"sku": "P3-S", "name": "Small"}}]}</script>`,
+			want: []ProductVariant{{PID: "P3-S", Name: "Small"}},
+		},
+		{
+			name: "html fallback",
+			page: `<ul><li data-pid="P4-A" data-attr-value="Rouge">a</li><li class="x" data-pid="P4-B" title="Noir">b</li>
+				<li data-pid="P4-C">no name</li><li data-pid="P4-A" title="Duplicate">c</li></ul>`,
+			want: []ProductVariant{{PID: "P4-A", Name: "Rouge"}, {PID: "P4-B", Name: "Noir"}},
+		},
+		{
+			name: "invalid json-ld falls back to html",
+			page: `<script type="application/ld+json">{"hasVariant": [</script><div data-pid="P5-1" aria-label="Mini"></div>`,
+			want: []ProductVariant{{PID: "P5-1", Name: "Mini"}},
+		},
+		{
+			name: "nothing found",
+			page: `<html><body>No variants</body></html>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseProductVariants([]byte(tt.page))
+			if len(got) != len(tt.want) {
+				t.Fatalf("parseProductVariants() = %v, want %v", got, tt.want)
+			}
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Errorf("variant %d = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Valore che sostituisce i segreti nelle configurazioni condivise
+const secretPlaceholder = "<secret>"
+
+// Configurazione condivisibile: store, prodotti, intervallo e impostazioni, senza segreti
+type sharedConfig struct {
+	Country       string            `json:"country,omitempty"`
+	StoreIDs      []string          `json:"store_ids"`
+	StoreLabels   map[string]string `json:"store_labels,omitempty"`
+	CheckInterval Duration          `json:"check_interval"`
+	WebhookURL    string            `json:"webhook_url,omitempty"`
+	Config        Config            `json:"config"`
+}
+
+// Segreto della configurazione: descrizione per il prompt e puntatore al valore
+type sharedSecret struct {
+	Name  string
+	Value *string
+}
+
+// Funzione per elencare i segreti di una configurazione condivisa. I webhook delle rotte,
+// gli header delle richieste e le password dei proxy sono gestiti a parte.
+func (s *sharedConfig) secrets() []sharedSecret {
+	return []sharedSecret{
+		{"Discord webhook URL", &s.WebhookURL},
+		{"Discord bot token", &s.Config.DiscordBotToken},
+		{"control API token", &s.Config.APIToken},
+		{"ntfy token", &s.Config.Ntfy.Token},
+		// Chi conosce il topic può leggere gli avvisi
+		{"ntfy topic", &s.Config.Ntfy.Topic},
+	}
+}
+
+// Mappa di segreti (webhook per rotta, header delle richieste): ogni valore viene sostituito
+// dal segnaposto e richiesto singolarmente all'importazione
+type sharedSecretMap struct {
+	// Descrizione per il prompt, con %s al posto della chiave
+	Prompt string
+	// Descrizione nell'elenco dei segreti rimossi, con %d al posto del numero di valori
+	Summary string
+	Values  map[string]string
+}
+
+func (s *sharedConfig) secretMaps() []sharedSecretMap {
+	return []sharedSecretMap{
+		{"the webhook URL for %s (leave empty to remove the route)", "%d routed webhook URLs", s.Config.WebhookRoutes.Countries},
+		{"the webhook URL for %s (leave empty to remove the route)", "%d routed webhook URLs", s.Config.WebhookRoutes.Stores},
+		// Gli header possono contenere cookie e credenziali
+		{"the value of the store locator header %s (leave empty to remove it)", "%d store locator headers", s.Config.LocatorRequest.Headers},
+		{"the value of the product page header %s (leave empty to remove it)", "%d product page headers", s.Config.PDPStockRequest.Headers},
+	}
+}
+
+// Funzione per esportare la configurazione in un file condivisibile, sostituendo i segreti
+// con un segnaposto. Ritorna i segreti rimossi.
+func exportSharedConfig(path string) ([]string, error) {
+	shared := sharedConfig{Config: config}
+
+	if country, err := readCountrySelection(); err == nil {
+		shared.Country = strings.TrimSpace(country)
+	}
+	ids, err := readStoreIDs()
+	if err != nil {
+		return nil, err
+	}
+	shared.StoreIDs = ids
+	if shared.StoreLabels, err = readStoreLabels(); err != nil {
+		return nil, err
+	}
+	interval, err := readCheckInterval()
+	if err != nil {
+		return nil, err
+	}
+	shared.CheckInterval = Duration{interval}
+	if webhookURL, err := readWebhookURL(); err == nil {
+		shared.WebhookURL = webhookURL
+	}
+
+	// Le mappe vengono copiate, così la configurazione in uso non viene modificata
+	shared.Config.WebhookRoutes = WebhookRoutes{
+		Countries: stripRoutes(config.WebhookRoutes.Countries),
+		Stores:    stripRoutes(config.WebhookRoutes.Stores),
+	}
+	shared.Config.LocatorRequest.Headers = stripRoutes(config.LocatorRequest.Headers)
+	shared.Config.PDPStockRequest.Headers = stripRoutes(config.PDPStockRequest.Headers)
+	shared.Config.Proxies = append([]string(nil), config.Proxies...)
+	var stripped []string
+	for _, secret := range shared.secrets() {
+		if *secret.Value != "" {
+			*secret.Value = secretPlaceholder
+			stripped = append(stripped, secret.Name)
+		}
+	}
+	counts := make(map[string]int)
+	var summaries []string
+	for _, secrets := range shared.secretMaps() {
+		if len(secrets.Values) > 0 && counts[secrets.Summary] == 0 {
+			summaries = append(summaries, secrets.Summary)
+		}
+		counts[secrets.Summary] += len(secrets.Values)
+	}
+	for _, summary := range summaries {
+		stripped = append(stripped, fmt.Sprintf(summary, counts[summary]))
+	}
+	if proxies := stripProxyPasswords(shared.Config.Proxies); proxies > 0 {
+		stripped = append(stripped, fmt.Sprintf("%d proxy passwords", proxies))
+	}
+	// La posizione di casa non viene condivisa né richiesta: va impostata di nuovo
+	if shared.Config.HomeLocation != nil {
+		shared.Config.HomeLocation = nil
+		stripped = append(stripped, "home location (removed)")
+	}
+
+	content, err := json.MarshalIndent(shared, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return stripped, os.WriteFile(path, append(content, '\n'), 0644)
+}
+
+// Funzione per sostituire con il segnaposto la password dei proxy che ne hanno una; il resto
+// dell'url resta leggibile. Ritorna il numero di password rimosse.
+func stripProxyPasswords(proxies []string) int {
+	stripped := 0
+	for i, proxy := range proxies {
+		u, err := url.Parse(proxy)
+		if err != nil || u.User == nil {
+			continue
+		}
+		if _, hasPassword := u.User.Password(); !hasPassword {
+			continue
+		}
+		u.User = url.UserPassword(u.User.Username(), secretPlaceholder)
+		proxies[i] = u.String()
+		stripped++
+	}
+	return stripped
+}
+
+func stripRoutes(routes map[string]string) map[string]string {
+	if routes == nil {
+		return nil
+	}
+	stripped := make(map[string]string, len(routes))
+	for key := range routes {
+		stripped[key] = secretPlaceholder
+	}
+	return stripped
+}
+
+// Funzione per importare una configurazione condivisa, chiedendo all'utente i segreti rimossi.
+// La configurazione attuale viene salvata nei backup prima di essere sostituita.
+func importSharedConfig(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	shared := sharedConfig{Config: defaultConfig()}
+	if err := json.Unmarshal(content, &shared); err != nil {
+		return fmt.Errorf("invalid shared configuration: %v", err)
+	}
+
+	// Segreti: un valore vuoto lascia l'impostazione disattivata
+	for _, secret := range shared.secrets() {
+		if *secret.Value != secretPlaceholder {
+			continue
+		}
+		fmt.Printf("Enter the %s (leave empty to skip):\n", secret.Name)
+		*secret.Value = readOptionalLine()
+	}
+	for _, secrets := range shared.secretMaps() {
+		for key, value := range secrets.Values {
+			if value != secretPlaceholder {
+				continue
+			}
+			fmt.Printf("Enter "+secrets.Prompt+":\n", key)
+			if value = readOptionalLine(); value == "" {
+				delete(secrets.Values, key)
+			} else {
+				secrets.Values[key] = value
+			}
+		}
+	}
+	var proxies []string
+	for _, proxy := range shared.Config.Proxies {
+		u, err := url.Parse(proxy)
+		if err == nil && u.User != nil {
+			if password, _ := u.User.Password(); password == secretPlaceholder {
+				u.User = url.User(u.User.Username())
+				fmt.Printf("Enter the password of proxy %s (leave empty to remove the proxy):\n", u.Redacted())
+				password := readOptionalLine()
+				if password == "" {
+					continue
+				}
+				u.User = url.UserPassword(u.User.Username(), password)
+				proxy = u.String()
+			}
+		}
+		proxies = append(proxies, proxy)
+	}
+	shared.Config.Proxies = proxies
+	if shared.WebhookURL != "" {
+		if err := validateWebhookURL(shared.WebhookURL); err != nil {
+			return err
+		}
+	}
+
+	// Tutto viene validato prima di scrivere qualsiasi file, così un file condiviso non valido
+	// non lascia una configurazione a metà che impedisce l'avvio successivo
+	if shared.Country != "" {
+		code, _ := resolveCountry(shared.Country)
+		if code == "" {
+			return fmt.Errorf("invalid shared configuration: %w", checkCountryCode(shared.Country))
+		}
+		shared.Country = code
+	}
+	for _, id := range shared.StoreIDs {
+		if !validStoreID(id) {
+			return fmt.Errorf("invalid shared configuration: invalid store ID %q", id)
+		}
+	}
+	candidate, err := json.MarshalIndent(shared.Config, "", "  ")
+	if err != nil {
+		return err
+	}
+	updated, err := parseConfig(candidate)
+	if err != nil {
+		return fmt.Errorf("invalid shared configuration: %w", err)
+	}
+
+	if shared.Country != "" {
+		if err := writeCountrySelection(shared.Country); err != nil {
+			return err
+		}
+	}
+	if err := writeStoreIDs(shared.StoreIDs); err != nil {
+		return err
+	}
+	if len(shared.StoreLabels) > 0 {
+		if err := writeStoreLabels(shared.StoreLabels); err != nil {
+			return err
+		}
+	}
+	if shared.CheckInterval.Duration > 0 {
+		if err := writeCheckInterval(shared.CheckInterval.Duration); err != nil {
+			return err
+		}
+	}
+	if shared.WebhookURL != "" {
+		if err := writeWebhookURL(shared.WebhookURL); err != nil {
+			return err
+		}
+	}
+	if err := saveConfig(shared.Config); err != nil {
+		return err
+	}
+
+	// La nuova configurazione, già validata, viene applicata subito
+	config = updated
+	return nil
+}
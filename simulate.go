@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Funzione per iniettare un finto risultato disponibile nella catena completa delle notifiche
+// (stato, template, instradamento, fascia silenziosa, invio), come durante un vero restock.
+// Senza persist lo stato delle notifiche resta invariato.
+func simulateRestock(storeID string, productID string, persist bool) error {
+	storeID = strings.TrimSpace(storeID)
+	if !validStoreID(storeID) {
+		return fmt.Errorf("invalid store ID %q", storeID)
+	}
+	if productID == "" {
+		productID = defaultProductID
+	}
+
+	country, err := readCountrySelection()
+	if err != nil {
+		return fmt.Errorf("country: %v", err)
+	}
+	webhookurl, err := readWebhookURL()
+	if err != nil {
+		return fmt.Errorf("webhook URL: %v", err)
+	}
+
+	store := lookupStore(country, Location{ID: storeID, Name: "Simulated Store", Address1: "Simulated Address", CountryCode: country})
+	result := restockResult(store, productID, country)
+	result.Simulated = true
+
+	previous := notifyStateReadOnly
+	notifyStateReadOnly = previous || !persist
+	defer func() { notifyStateReadOnly = previous }()
+
+	logger.Info("Simulating restock", result.fields().with("persist", persist))
+	reportCheckResults([]CheckResult{result}, webhookurl)
+	return nil
+}
+
+// Funzione per cercare uno store nei dati reali, così il template viene provato con valori veri.
+// Se lo store locator non risponde o lo store non esiste viene usato placeholder.
+func lookupStore(country string, placeholder Location) Location {
+	response, err := fetchStoreResponse(endpointForCountry(country))
+	if err != nil {
+		logger.Warn("Store list unavailable, using placeholder store data", logFields{"error": err})
+		return placeholder
+	}
+	for _, location := range response.Locations {
+		if location.ID == placeholder.ID {
+			return location
+		}
+	}
+	return placeholder
+}
+
+// Funzione per costruire il risultato di un restock appena rilevato in uno store
+func restockResult(store Location, productID string, country string) CheckResult {
+	store.ProductAvailability = true
+	return CheckResult{
+		StoreID:   store.ID,
+		ProductID: productID,
+		Country:   country,
+		Name:      store.Name,
+		Address:   store.Address1,
+		City:      store.City,
+		Available: true,
+		Services:  normalizeServices(store.StoreServices),
+		CheckedAt: time.Now(),
+		Restocked: true,
+		Distance:  storeDistance(store),
+		Store:     store,
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Formati dei file di stato (notify_state, stats, notifier_health): JSON leggibile (default)
+// oppure gob, più compatto e veloce da leggere e scrivere con molte voci
+const (
+	stateFormatJSON = "json"
+	stateFormatGob  = "gob"
+)
+
+func validateStateFormat(format string) error {
+	switch format {
+	case "", stateFormatJSON, stateFormatGob:
+		return nil
+	}
+	return fmt.Errorf("invalid state_format %q: use %q or %q", format, stateFormatJSON, stateFormatGob)
+}
+
+// Percorso del file di stato nel formato indicato: i nomi dei file sono quelli JSON storici
+// e la versione gob cambia solo l'estensione
+func stateFilePath(jsonPath string, format string) string {
+	if format == stateFormatGob {
+		return strings.TrimSuffix(jsonPath, filepath.Ext(jsonPath)) + ".gob"
+	}
+	return jsonPath
+}
+
+func currentStateFormat() string {
+	if config.StateFormat == stateFormatGob {
+		return stateFormatGob
+	}
+	return stateFormatJSON
+}
+
+func otherStateFormat(format string) string {
+	if format == stateFormatGob {
+		return stateFormatJSON
+	}
+	return stateFormatGob
+}
+
+func encodeState(v interface{}, format string) ([]byte, error) {
+	if format == stateFormatGob {
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	content, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(content, '\n'), nil
+}
+
+func decodeState(content []byte, v interface{}, format string) error {
+	if format == stateFormatGob {
+		return gob.NewDecoder(bytes.NewReader(content)).Decode(v)
+	}
+	return json.Unmarshal(content, v)
+}
+
+// Funzione per leggere un file di stato nel formato configurato. Se esiste solo il file
+// nell'altro formato (es. il JSON prima di passare a gob) viene letto e convertito al primo uso;
+// il file originale resta come copia con estensione .bak. Ritorna false se non esiste nessun file.
+func readStateFile(jsonPath string, v interface{}) (bool, error) {
+	format := currentStateFormat()
+	path := stateFilePath(jsonPath, format)
+	content, err := os.ReadFile(path)
+	if err == nil {
+		if err := decodeState(content, v, format); err != nil {
+			return true, fmt.Errorf("invalid %s: %v", path, err)
+		}
+		return true, nil
+	}
+	if !os.IsNotExist(err) {
+		return false, err
+	}
+
+	oldFormat := otherStateFormat(format)
+	oldPath := stateFilePath(jsonPath, oldFormat)
+	content, err = os.ReadFile(oldPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	if err := decodeState(content, v, oldFormat); err != nil {
+		return true, fmt.Errorf("invalid %s: %v", oldPath, err)
+	}
+	if readOnlyMode || notifyStateReadOnly {
+		return true, nil
+	}
+	if err := writeStateFile(jsonPath, v); err != nil {
+		logger.Warn("Failed to migrate state file", logFields{"file": oldPath, "error": err})
+		return true, nil
+	}
+	logger.Info("State file migrated", logFields{"from": oldPath, "to": path})
+	return true, nil
+}
+
+// Funzione per mettere da parte un file di stato illeggibile rinominandolo in .bak, così il
+// salvataggio successivo non sovrascrive i dati ancora recuperabili. Ritorna il nuovo nome
+// (vuoto se il file non esiste).
+func backupBadStateFile(jsonPath string) (string, error) {
+	format := currentStateFormat()
+	for _, path := range []string{stateFilePath(jsonPath, format), stateFilePath(jsonPath, otherStateFormat(format))} {
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		return path + ".bak", os.Rename(path, path+".bak")
+	}
+	return "", nil
+}
+
+// Funzione per scrivere un file di stato nel formato configurato. Un file rimasto nell'altro
+// formato viene rinominato in .bak, così alla lettura successiva non viene preferito per errore.
+func writeStateFile(jsonPath string, v interface{}) error {
+	format := currentStateFormat()
+	content, err := encodeState(v, format)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(stateFilePath(jsonPath, format), content, 0644); err != nil {
+		return err
+	}
+	oldPath := stateFilePath(jsonPath, otherStateFormat(format))
+	if err := os.Rename(oldPath, oldPath+".bak"); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Funzione per eliminare un file di stato in entrambi i formati
+func removeStateFile(jsonPath string) error {
+	for _, format := range []string{stateFormatJSON, stateFormatGob} {
+		if err := os.Remove(stateFilePath(jsonPath, format)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Funzione per esportare in JSON i file di stato, qualunque sia il formato in uso, nella cartella
+// indicata; ritorna i file scritti
+func exportStateJSON(dir string) ([]string, error) {
+	state, err := loadNotifyState()
+	if err != nil {
+		return nil, err
+	}
+	saved, err := loadStats()
+	if err != nil {
+		return nil, err
+	}
+	loadNotifierHealth()
+	notifierHealthMu.Lock()
+	health := make(map[string]*channelHealth, len(notifierHealth))
+	for channel, h := range notifierHealth {
+		health[channel] = h
+	}
+	notifierHealthMu.Unlock()
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	exports := []struct {
+		file  string
+		value interface{}
+	}{
+		{notifyStateFile, state},
+		{statsFile, saved},
+		{notifierHealthFile, health},
+	}
+	var written []string
+	for _, export := range exports {
+		content, err := encodeState(export.value, stateFormatJSON)
+		if err != nil {
+			return written, err
+		}
+		path := filepath.Join(dir, export.file)
+		if err := os.WriteFile(path, content, 0644); err != nil {
+			return written, err
+		}
+		written = append(written, path)
+	}
+	return written, nil
+}
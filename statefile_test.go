@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+// Stato con n voci, come dopo mesi di monitoraggio di molti store e prodotti
+func largeNotifyState(n int) notifyState {
+	state := make(notifyState, n)
+	now := time.Date(2024, 5, 13, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < n; i++ {
+		entry := &notifyEntry{
+			StoreID:      fmt.Sprintf("%04d", i%500),
+			ProductID:    fmt.Sprintf("P%06d", i/500),
+			Country:      "IT",
+			Available:    i%3 == 0,
+			LastNotified: now.Add(-time.Duration(i) * time.Minute),
+		}
+		if entry.Available {
+			entry.AvailableSince = entry.LastNotified
+			entry.CooldownUntil = entry.LastNotified.Add(time.Hour)
+		}
+		state[notifyStateKey(entry.Country, entry.ProductID, entry.StoreID, "")] = entry
+	}
+	return state
+}
+
+func benchmarkStateFormat(b *testing.B, format string) {
+	chdirTemp(b)
+	previous := config
+	b.Cleanup(func() { config = previous })
+	config.StateFormat = format
+	state := largeNotifyState(10000)
+
+	b.Run("save", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if err := writeStateFile(notifyStateFile, state); err != nil {
+				b.Fatal(err)
+			}
+		}
+		if info, err := os.Stat(stateFilePath(notifyStateFile, format)); err == nil {
+			b.ReportMetric(float64(info.Size()), "file-bytes")
+		}
+	})
+	b.Run("load", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			loaded := make(notifyState)
+			if _, err := readStateFile(notifyStateFile, &loaded); err != nil {
+				b.Fatal(err)
+			}
+			if len(loaded) != len(state) {
+				b.Fatalf("loaded %d entries, want %d", len(loaded), len(state))
+			}
+		}
+	})
+}
+
+func BenchmarkStateJSON(b *testing.B) { benchmarkStateFormat(b, stateFormatJSON) }
+
+func BenchmarkStateGob(b *testing.B) { benchmarkStateFormat(b, stateFormatGob) }
+
+func TestMalformedStatsKeptAsBackup(t *testing.T) {
+	chdirTemp(t)
+	defer resetStats()
+
+	malformed := []byte(`{"since": "2026-01-01T00:00:00Z", "total": {"requests": 12`)
+	if err := os.WriteFile(statsFile, malformed, 0644); err != nil {
+		t.Fatal(err)
+	}
+	statsMu.Lock()
+	stats, statsLoaded = nil, false
+	statsMu.Unlock()
+
+	recordStatsNotification("IT")
+	saveStats()
+
+	if kept, err := os.ReadFile(statsFile + ".bak"); err != nil || string(kept) != string(malformed) {
+		t.Fatalf("backup = %q, %v; want the malformed file", kept, err)
+	}
+	saved, err := loadStats()
+	if err != nil {
+		t.Fatalf("new statistics file unreadable: %v", err)
+	}
+	if saved.Total.Notifications != 1 || saved.Countries["IT"].Notifications != 1 {
+		t.Errorf("saved statistics = %+v", saved.Total)
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// File con le statistiche cumulative, conservate tra un'esecuzione e l'altra
+const statsFile = "stats.json"
+
+// Contatori per un paese (o totali)
+type statsCounters struct {
+	Requests      int            `json:"requests"`
+	Successes     int            `json:"successes"`
+	Errors        map[string]int `json:"errors"`
+	Notifications int            `json:"notifications"`
+	Restocks      int            `json:"restocks"`
+	// Ritardo tra il controllo che rileva un restock e l'invio dell'avviso
+	LatencyCount   int   `json:"latency_count"`
+	LatencyTotalMs int64 `json:"latency_total_ms"`
+	LatencyMaxMs   int64 `json:"latency_max_ms"`
+}
+
+// Statistiche cumulative salvate in stats.json. A differenza di runMetrics, che riguarda una
+// sola esecuzione, sopravvivono ai riavvii e servono a valutare la configurazione nel tempo.
+type persistentStats struct {
+	Since     time.Time                 `json:"since"`
+	Total     statsCounters             `json:"total"`
+	Countries map[string]*statsCounters `json:"countries"`
+}
+
+var (
+	statsMu     sync.Mutex
+	stats       *persistentStats
+	statsLoaded bool
+	// true se il file illeggibile non è stato messo da parte: salvare lo sovrascriverebbe
+	statsReadOnly bool
+)
+
+// Funzione per leggere le statistiche dal file
+func loadStats() (*persistentStats, error) {
+	loaded := &persistentStats{Since: time.Now(), Countries: make(map[string]*statsCounters)}
+	found, err := readStateFile(statsFile, loaded)
+	if err != nil {
+		if !found {
+			return loaded, err
+		}
+		return &persistentStats{Since: time.Now(), Countries: make(map[string]*statsCounters)}, err
+	}
+	if loaded.Countries == nil {
+		loaded.Countries = make(map[string]*statsCounters)
+	}
+	return loaded, nil
+}
+
+// Funzione per aggiornare le statistiche (totali e del paese) sotto lock, leggendo il file al primo uso
+func updateStats(country string, update func(c *statsCounters)) {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+
+	if !statsLoaded {
+		var err error
+		if stats, err = loadStats(); err != nil {
+			fields := logFields{"error": err}
+			// Il file illeggibile viene conservato prima che i nuovi contatori lo sovrascrivano
+			if !readOnlyMode {
+				backup, renameErr := backupBadStateFile(statsFile)
+				if renameErr != nil {
+					logger.Warn("Failed to keep the unreadable statistics file, not saving statistics", fields.with("rename_error", renameErr))
+					statsReadOnly = true
+				} else if backup != "" {
+					fields = fields.with("backup", backup)
+				}
+			}
+			logger.Warn("Failed to read statistics, starting fresh", fields)
+		}
+		statsLoaded = true
+	}
+
+	update(&stats.Total)
+	if country != "" {
+		counters, ok := stats.Countries[country]
+		if !ok {
+			counters = &statsCounters{}
+			stats.Countries[country] = counters
+		}
+		update(counters)
+	}
+}
+
+// Funzione per registrare l'esito di una richiesta allo store locator
+func recordStatsRequest(country string, err error) {
+	updateStats(country, func(c *statsCounters) {
+		c.Requests++
+		if err == nil {
+			c.Successes++
+			return
+		}
+		if c.Errors == nil {
+			c.Errors = make(map[string]int)
+		}
+		c.Errors[errorKind(err)]++
+	})
+}
+
+func recordStatsNotification(country string) {
+	updateStats(country, func(c *statsCounters) { c.Notifications++ })
+}
+
+func recordStatsRestock(country string) {
+	updateStats(country, func(c *statsCounters) { c.Restocks++ })
+}
+
+func recordStatsLatency(country string, latency time.Duration) {
+	ms := latency.Milliseconds()
+	updateStats(country, func(c *statsCounters) {
+		c.LatencyCount++
+		c.LatencyTotalMs += ms
+		if ms > c.LatencyMaxMs {
+			c.LatencyMaxMs = ms
+		}
+	})
+}
+
+// Funzione per salvare le statistiche accumulate, chiamata ad ogni ciclo
+func saveStats() {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+	if stats == nil || readOnlyMode || statsReadOnly {
+		return
+	}
+
+	if err := writeStateFile(statsFile, stats); err != nil {
+		logger.Warn("Failed to save statistics", logFields{"error": err})
+	}
+}
+
+// Funzione per azzerare le statistiche (-reset-stats)
+func resetStats() error {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+	stats, statsLoaded, statsReadOnly = nil, false, false
+	return removeStateFile(statsFile)
+}
+
+// Funzione per stampare le statistiche cumulative, totali e per paese
+func printStats() error {
+	saved, err := loadStats()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("+-+-+-+-+ Statistics since %s +-+-+-+-+\n", formatTimestamp(saved.Since))
+	printStatsCounters("Total", saved.Total)
+
+	countries := make([]string, 0, len(saved.Countries))
+	for country := range saved.Countries {
+		countries = append(countries, country)
+	}
+	sort.Strings(countries)
+	for _, country := range countries {
+		printStatsCounters(country, *saved.Countries[country])
+	}
+	return nil
+}
+
+func printStatsCounters(label string, c statsCounters) {
+	successRate := 0.0
+	if c.Requests > 0 {
+		successRate = float64(c.Successes) * 100 / float64(c.Requests)
+	}
+	fmt.Printf("%s: %d requests, %d ok (%.1f%%), %d notifications, %d restocks\n",
+		label, c.Requests, c.Successes, successRate, c.Notifications, c.Restocks)
+	if c.LatencyCount > 0 {
+		average := time.Duration(c.LatencyTotalMs/int64(c.LatencyCount)) * time.Millisecond
+		maximum := time.Duration(c.LatencyMaxMs) * time.Millisecond
+		fmt.Printf("  %-19s avg %v, max %v (%d alerts)\n", "alert latency:", average, maximum, c.LatencyCount)
+	}
+
+	kinds := make([]string, 0, len(c.Errors))
+	for kind := range c.Errors {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		fmt.Printf("  %-19s %d\n", kind+":", c.Errors[kind])
+	}
+}
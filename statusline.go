@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// Funzione per verificare se un file è un terminale (senza dipendenze esterne)
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// Modalità compatta (-compact): una sola riga di stato aggiornata sul posto.
+// Disattivata automaticamente se l'output non è un terminale (pipe, file di log).
+func compactOutput() bool {
+	return *compactFlag && isTerminal(os.Stdout)
+}
+
+// Funzione per cancellare la riga di stato prima di stampare un dettaglio completo
+func clearStatusLine() {
+	if compactOutput() {
+		clearInPlace()
+	}
+}
+
+// Funzione per riscrivere la riga di stato con il riepilogo dell'ultimo ciclo
+func printStatusLine(cycle int, stores int, inStock int, schedules []*countrySchedule) {
+	warning := ""
+	if failing := len(failingChannels()); failing > 0 {
+		warning = fmt.Sprintf(" | ⚠️  %d notifier(s) failing", failing)
+	}
+	printInPlace("Cycle %d | %d stores | %d in stock | next check %s%s",
+		cycle, stores, inStock, nextDue(schedules).In(configuredLocation()).Format("15:04"), warning)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/fatih/color"
+)
+
+// Funzione per mostrare i dettagli di uno store e la disponibilità di tutti i prodotti
+// monitorati in quello store (una richiesta allo store locator per prodotto)
+func showStoreDetails(storeID string, endpoint_url string) error {
+	var watchlist productWatchList
+	products := watchlist.products()
+
+	var store *Location
+	availability := make(map[string]string, len(products))
+	for _, pid := range products {
+		results, err := checkProductAvailability([]string{storeID}, endpointForProduct(endpoint_url, pid))
+		if err != nil {
+			availability[pid] = "error: " + err.Error()
+			continue
+		}
+		if len(results) == 0 {
+			availability[pid] = "-"
+			continue
+		}
+		if store == nil {
+			store = &results[0].Store
+		}
+		if results[0].Available {
+			availability[pid] = "yes"
+		} else {
+			availability[pid] = "no"
+		}
+	}
+
+	if store == nil {
+		return fmt.Errorf("store %s not found", storeID)
+	}
+
+	color.Magenta("%s%s (%s)\n", favoriteMark(*store), store.Name, store.ID)
+	fmt.Printf("Address: %s, %s %s\n", store.Address1, store.Postal, store.City)
+	if store.Phone != "" {
+		fmt.Printf("Phone: %s\n", store.Phone)
+	}
+	fmt.Printf("Services: %s\n", fulfillmentSummary(*store))
+	if names := serviceNames(store.StoreServices); names != "" {
+		fmt.Printf("Store services: %s\n", names)
+	}
+	fmt.Printf("Hours: %s\n", storeHours(*store))
+	if note := exceptionalNote(*store); note != "" {
+		color.Yellow("%s\n", note)
+	}
+	if notice := attentionMessage(*store); notice != "" {
+		color.Yellow("Store notice: %s\n", notice)
+	}
+	for _, day := range store.Schedule {
+		fmt.Printf("  %s %s\n", day.Day, strings.TrimSpace(day.Time))
+	}
+	fmt.Println()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PRODUCT\tAVAILABLE")
+	for _, pid := range products {
+		fmt.Fprintf(w, "%s\t%s\n", pid, availability[pid])
+	}
+	return w.Flush()
+}
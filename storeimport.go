@@ -0,0 +1,214 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const storeLabelsFile = "store_labels.json"
+
+// Uno store in import/export: ID e un'etichetta libera (es. "Milano Duomo")
+type StoreEntry struct {
+	ID    string `json:"id"`
+	Label string `json:"label"`
+}
+
+var storeIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Funzione per validare il formato di uno Store ID
+func validStoreID(id string) bool {
+	return storeIDPattern.MatchString(id)
+}
+
+// Funzione per leggere le etichette degli store dal file
+func readStoreLabels() (map[string]string, error) {
+	labels := make(map[string]string)
+	content, err := os.ReadFile(storeLabelsFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return labels, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(content, &labels); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", storeLabelsFile, err)
+	}
+	return labels, nil
+}
+
+// Funzione per scrivere le etichette degli store nel file
+func writeStoreLabels(labels map[string]string) error {
+	if err := backupConfig(); err != nil {
+		return err
+	}
+
+	content, err := json.MarshalIndent(labels, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(storeLabelsFile, append(content, '\n'), 0644)
+}
+
+// Funzione per leggere una lista di store da un file CSV (colonne: id, label) o JSON
+func parseStoreEntries(path string) ([]StoreEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return parseStoreEntriesJSON(file)
+	}
+	return parseStoreEntriesCSV(file)
+}
+
+func parseStoreEntriesJSON(r io.Reader) ([]StoreEntry, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []StoreEntry
+	if err := json.Unmarshal(content, &entries); err == nil {
+		return entries, nil
+	}
+
+	// Accettiamo anche un semplice array di stringhe ["ITCODE1", "ITCODE2"]
+	var ids []string
+	if err := json.Unmarshal(content, &ids); err != nil {
+		return nil, fmt.Errorf("expected a JSON array of {\"id\",\"label\"} objects or strings: %v", err)
+	}
+	for _, id := range ids {
+		entries = append(entries, StoreEntry{ID: id})
+	}
+	return entries, nil
+}
+
+func parseStoreEntriesCSV(r io.Reader) ([]StoreEntry, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []StoreEntry
+	for i, record := range records {
+		if len(record) == 0 {
+			continue
+		}
+		// Intestazione opzionale
+		if i == 0 && strings.EqualFold(strings.TrimSpace(record[0]), "id") {
+			continue
+		}
+		entry := StoreEntry{ID: record[0]}
+		if len(record) > 1 {
+			entry.Label = record[1]
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// Funzione per importare una lista di store nel file store_ids, scartando duplicati e ID non validi.
+// Ritorna il numero di store aggiunti e saltati.
+func importStoreIDs(path string) (added int, skipped int, err error) {
+	entries, err := parseStoreEntries(path)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	existing, err := readStoreIDs()
+	if err != nil {
+		return 0, 0, err
+	}
+	known := make(map[string]bool, len(existing))
+	for _, id := range existing {
+		known[id] = true
+	}
+
+	labels, err := readStoreLabels()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	labelsChanged := false
+	for _, entry := range entries {
+		id := strings.TrimSpace(entry.ID)
+		label := strings.TrimSpace(entry.Label)
+
+		if !validStoreID(id) || known[id] {
+			skipped++
+			continue
+		}
+
+		if err := writeStoreID(id); err != nil {
+			return added, skipped, err
+		}
+		known[id] = true
+		added++
+
+		if label != "" {
+			labels[id] = label
+			labelsChanged = true
+		}
+	}
+
+	if labelsChanged {
+		if err := writeStoreLabels(labels); err != nil {
+			return added, skipped, err
+		}
+	}
+
+	return added, skipped, nil
+}
+
+// Funzione per esportare gli store monitorati in CSV o JSON (in base all'estensione del file)
+func exportStoreIDs(path string) (int, error) {
+	ids, err := readStoreIDs()
+	if err != nil {
+		return 0, err
+	}
+	labels, err := readStoreLabels()
+	if err != nil {
+		return 0, err
+	}
+
+	entries := make([]StoreEntry, 0, len(ids))
+	for _, id := range ids {
+		entries = append(entries, StoreEntry{ID: id, Label: labels[id]})
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		encoder := json.NewEncoder(file)
+		encoder.SetIndent("", "  ")
+		return len(entries), encoder.Encode(entries)
+	}
+
+	writer := csv.NewWriter(file)
+	if err := writer.Write([]string{"id", "label"}); err != nil {
+		return 0, err
+	}
+	for _, entry := range entries {
+		if err := writer.Write([]string{entry.ID, entry.Label}); err != nil {
+			return 0, err
+		}
+	}
+	writer.Flush()
+	return len(entries), writer.Error()
+}
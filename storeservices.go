@@ -0,0 +1,211 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/fatih/color"
+)
+
+// Servizi visti finora nelle risposte dello store locator, per segnalare gli ID di
+// store_services che nessuno store offre (errori di battitura, servizi di un altro paese)
+var (
+	seenServicesMu      sync.Mutex
+	seenServices        = make(map[string]bool)
+	unknownServiceWarns sync.Map
+)
+
+func validateStoreServices(ids []string) error {
+	for _, id := range ids {
+		if strings.TrimSpace(id) == "" {
+			return fmt.Errorf("invalid store_services: service IDs must not be empty")
+		}
+		if strings.Contains(id, ",") {
+			return fmt.Errorf("invalid store_services %q: list each service ID separately", id)
+		}
+	}
+	return nil
+}
+
+// Funzione per impostare il parametro storeservices dell'url con gli ID dei servizi richiesti,
+// così lo store locator restituisce solo gli store che li offrono
+func applyStoreServices(endpoint_url string, ids []string) string {
+	if len(ids) == 0 {
+		return endpoint_url
+	}
+	u, err := url.Parse(endpoint_url)
+	if err != nil {
+		return endpoint_url
+	}
+	query := u.Query()
+	query.Set("storeservices", strings.Join(ids, ","))
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// Funzione per controllare gli ID di store_services rispetto ai servizi presenti nelle risposte:
+// un ID mai visto viene segnalato una sola volta, perché il filtro potrebbe non avere effetto
+// o escludere tutti gli store
+func checkStoreServices(response StoreResponse, fields logFields) {
+	if len(config.StoreServices) == 0 {
+		return
+	}
+	// Nessuno store: probabilmente un ID sbagliato esclude tutto (segnalato una volta per paese)
+	if len(response.Locations) == 0 {
+		country, _ := fields["country"].(string)
+		if _, warned := unknownServiceWarns.LoadOrStore("#empty|"+country, true); !warned {
+			logger.Warn("No stores returned with the store_services filter, check the service IDs", fields.with("store_services", strings.Join(config.StoreServices, ",")))
+		}
+		return
+	}
+	seenServicesMu.Lock()
+	for _, store := range response.Locations {
+		for _, service := range store.StoreServices {
+			seenServices[service.ID] = true
+		}
+	}
+	var unknown []string
+	for _, id := range config.StoreServices {
+		if !seenServices[id] {
+			unknown = append(unknown, id)
+		}
+	}
+	seenServicesMu.Unlock()
+
+	for _, id := range unknown {
+		if _, warned := unknownServiceWarns.LoadOrStore(id, true); warned {
+			continue
+		}
+		logger.Warn("Configured store service not offered by any store in the response", fields.with("service", id))
+	}
+}
+
+// Servizio offerto dagli store della risposta, con il numero di store che lo offrono
+type serviceCount struct {
+	ID     string
+	Name   string
+	Stores int
+}
+
+// Funzione per elencare i servizi offerti dagli store, ordinati per ID
+func servicesInResponse(locations []Location) []serviceCount {
+	byID := make(map[string]*serviceCount)
+	for _, store := range locations {
+		for _, service := range store.StoreServices {
+			if service.ID == "" {
+				continue
+			}
+			entry, ok := byID[service.ID]
+			if !ok {
+				entry = &serviceCount{ID: service.ID, Name: service.Name}
+				byID[service.ID] = entry
+			}
+			entry.Stores++
+		}
+	}
+	services := make([]serviceCount, 0, len(byID))
+	for _, entry := range byID {
+		services = append(services, *entry)
+	}
+	sort.Slice(services, func(i, j int) bool { return services[i].ID < services[j].ID })
+	return services
+}
+
+// Funzione per scegliere dal menu i servizi richiesti agli store, tra quelli presenti
+// nella risposta dello store locator senza filtro sui servizi
+func editStoreServices() {
+	country, err := readCountrySelection()
+	if err != nil {
+		color.Red("Select a country first.\n")
+		return
+	}
+	country = strings.TrimSpace(country)
+
+	cfg, err := readConfigFile()
+	if err != nil {
+		color.Red("Cannot edit the store services: %v\n", err)
+		return
+	}
+	if !loadStoreDataForMenu(applySearchArea(baseEndpointForCountry(country), searchAreaFor(country))) {
+		return
+	}
+	services := servicesInResponse(storeResponse.Locations)
+	if len(services) == 0 {
+		color.Yellow("The store locator didn't report any service for these stores.\n")
+		return
+	}
+
+	selected := make(map[string]bool, len(cfg.StoreServices))
+	for _, id := range cfg.StoreServices {
+		selected[id] = true
+	}
+	fmt.Println("Services offered by the stores:")
+	for i, service := range services {
+		line := fmt.Sprintf("%d) %s - %s (%d stores)", i+1, service.ID, service.Name, service.Stores)
+		if selected[service.ID] {
+			color.Green("%s [selected]\n", line)
+		} else {
+			fmt.Println(line)
+		}
+	}
+	for _, id := range cfg.StoreServices {
+		if !hasServiceCount(services, id) {
+			color.Yellow("%s is selected but no store offers it.\n", id)
+		}
+	}
+
+	fmt.Println("Enter the numbers or IDs of the services to require, separated by commas")
+	fmt.Println("(leave empty to keep the current selection, \"-\" to clear it):")
+	value := readOptionalLine()
+	var ids []string
+	switch value {
+	case "":
+		return
+	case "-":
+	default:
+		for _, part := range strings.Split(value, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			if n, err := strconv.Atoi(part); err == nil {
+				if n < 1 || n > len(services) {
+					color.Red("%d is not in the list.\n", n)
+					return
+				}
+				part = services[n-1].ID
+			} else if !hasServiceCount(services, part) {
+				color.Red("No store offers the service %q.\n", part)
+				return
+			}
+			ids = append(ids, part)
+		}
+	}
+
+	cfg.StoreServices = ids
+	if err := saveConfig(cfg); err != nil {
+		color.Red("Not saved: %v\n", err)
+		return
+	}
+	if updated, err := loadConfig(); err == nil {
+		config = updated
+	}
+	if len(ids) == 0 {
+		color.Green("Store services filter cleared.\n")
+	} else {
+		color.Green("Only stores offering %s will be monitored.\n", strings.Join(ids, ", "))
+	}
+}
+
+func hasServiceCount(services []serviceCount, id string) bool {
+	for _, service := range services {
+		if service.ID == id {
+			return true
+		}
+	}
+	return false
+}
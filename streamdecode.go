@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Le risposte più piccole di questa soglia (con Content-Length noto) vengono lette per intero;
+// le altre vengono decodificate in streaming, uno store alla volta
+const streamingThreshold = largeResponseSize
+
+// Funzione per decidere se decodificare la risposta in streaming
+func useStreamingDecode(contentLength int64) bool {
+	return contentLength < 0 || contentLength > streamingThreshold
+}
+
+// Funzione per decodificare una StoreResponse leggendo "locations" un elemento alla volta.
+// Se keep non è nil vengono conservati solo gli store per cui restituisce true, così la memoria
+// usata dipende dagli store monitorati e non dalla dimensione della risposta.
+func decodeStoreResponseStream(r io.Reader, keep func(Location) bool) (StoreResponse, error) {
+	var response StoreResponse
+	dec := json.NewDecoder(r)
+
+	if err := expectDelim(dec, '{'); err != nil {
+		return response, err
+	}
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return response, err
+		}
+		key, _ := token.(string)
+
+		switch key {
+		case "locations":
+			if err := decodeLocations(dec, keep, &response.Locations); err != nil {
+				return response, fmt.Errorf("locations: %w", err)
+			}
+		case "success":
+			err = dec.Decode(&response.Success)
+		case "radius":
+			err = dec.Decode(&response.Radius)
+		case "favStoreId":
+			err = dec.Decode(&response.FavStoreId)
+		case "timestamp":
+			err = dec.Decode(&response.Timestamp)
+		case "isClickAndCollect":
+			err = dec.Decode(&response.IsClickAndCollect)
+		default:
+			var skip json.RawMessage
+			err = dec.Decode(&skip)
+		}
+		if err != nil {
+			return response, fmt.Errorf("%s: %w", key, err)
+		}
+	}
+	return response, expectDelim(dec, '}')
+}
+
+func decodeLocations(dec *json.Decoder, keep func(Location) bool, locations *[]Location) error {
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	// "locations": null
+	if token == nil {
+		return nil
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return fmt.Errorf("expected array, got %v", token)
+	}
+
+	for dec.More() {
+		var store Location
+		if err := dec.Decode(&store); err != nil {
+			return err
+		}
+		if keep == nil || keep(store) {
+			*locations = append(*locations, store)
+		}
+	}
+	return expectDelim(dec, ']')
+}
+
+func expectDelim(dec *json.Decoder, want json.Delim) error {
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != want {
+		return fmt.Errorf("expected %q, got %v", want, token)
+	}
+	return nil
+}
+
+// Byte iniziali di una risposta in streaming conservati per l'errore di decodifica
+const streamHeadSize = 64 * 1024
+
+// Writer che conserva solo i primi limit byte ricevuti
+type headBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (h *headBuffer) Write(p []byte) (int, error) {
+	if room := h.limit - h.Len(); room > 0 {
+		if len(p) > room {
+			h.Buffer.Write(p[:room])
+		} else {
+			h.Buffer.Write(p)
+		}
+	}
+	return len(p), nil
+}
+
+// Funzione per decodificare in streaming il corpo di una risposta dello store locator,
+// riconoscendo le pagine di challenge anti-bot dai primi byte. I primi byte letti vengono
+// conservati, così un errore di decodifica riporta l'inizio del corpo come newDecodeError.
+func decodeStreamingBody(resp *http.Response, endpoint_url string, keep func(Location) bool) (StoreResponse, error) {
+	fields := endpointFields(endpoint_url)
+	counter := newLimitedReader(resp.Body, fields)
+	captured := &headBuffer{limit: streamHeadSize}
+	reader := bufio.NewReader(io.TeeReader(counter, captured))
+
+	head, _ := reader.Peek(512)
+	if isChallengeResponse(resp.Header.Get("Content-Type"), head) {
+		body, _ := io.ReadAll(reader)
+		reportChallenge(body)
+		return StoreResponse{}, errAntiBotChallenge
+	}
+
+	parseStart := time.Now()
+	response, err := decodeStoreResponseStream(reader, keep)
+	if err != nil {
+		// Una risposta oltre il limite di dimensione non è un problema di formato
+		if errors.Is(err, errResponseTooLarge) {
+			return response, err
+		}
+		return response, newStreamDecodeError(resp, captured.Bytes(), counter.n, err)
+	}
+	logParseTime(int(counter.n), parseStart, fields.with("streaming", true))
+	return response, nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"text/template"
+	"time"
+)
+
+// File di default per il template delle notifiche
+const defaultMessageTemplateFile = "message_template.txt"
+
+// Template predefinito, equivalente al messaggio storico
+const defaultMessageTemplate = "**{{with .Emoji.title}}{{.}} {{end}}SEPHORA SNIPER{{with .Emoji.store}} {{.}}{{end}}** \n {{with .Emoji.cart}}{{.}} {{end}}The Product{{if .Variant}} **{{.Variant}}**{{end}} is available in the store **{{.StoreName}}**! \nStore Address: {{.Address1}}\n{{.Fulfillment}}\n{{if .Services}}Store services: {{.Services}}\n{{end}}Checked at: {{.CheckedAt}}{{if .ClosesAt}}\nOpen until: {{.ClosesAt}}{{end}}{{if .Confidence}}\nConfidence: {{.Confidence}}{{end}}{{if .MapsURL}}\nMap: {{.MapsURL}}{{end}}"
+
+// Dati disponibili nel template delle notifiche
+type MessageData struct {
+	StoreID     string
+	StoreName   string
+	Address1    string
+	City        string
+	Postal      string
+	Country     string
+	URL         string
+	ProductID   string
+	Variant     string
+	Fulfillment string
+	Services    string
+	MapsURL     string
+	CheckedAt   string
+	// Orario di chiusura dello store, se è aperto e gli orari sono noti
+	ClosesAt   string
+	Confidence string
+	// Emoji per nome, vuote con notification_style "plain"
+	Emoji map[string]string
+}
+
+var messageTemplate = template.Must(template.New("message").Parse(defaultMessageTemplate))
+
+// Funzione per costruire i dati del template a partire da un risultato
+func newMessageData(result CheckResult) MessageData {
+	data := MessageData{
+		StoreID:     result.StoreID,
+		StoreName:   result.Name,
+		Address1:    result.Address,
+		City:        result.City,
+		Postal:      result.Store.Postal,
+		Country:     result.Country,
+		URL:         result.Store.URL,
+		ProductID:   result.ProductID,
+		Variant:     result.Variant,
+		Fulfillment: fulfillmentSummary(result.Store),
+		Services:    serviceNames(result.Services),
+		CheckedAt:   formatTimestamp(result.CheckedAt),
+		ClosesAt:    closingTime(result.Store, result.CheckedAt),
+		Confidence:  result.Confidence,
+		Emoji:       emojiSet(),
+	}
+	if config.IncludeMapsLink {
+		data.MapsURL = mapsURL(result.Store)
+	}
+	return data
+}
+
+// Funzione per caricare e validare il template delle notifiche dal file configurato.
+// Se il file non esiste o il template non è valido si usa quello predefinito.
+func loadMessageTemplate() *template.Template {
+	fallback := template.Must(template.New("message").Parse(defaultMessageTemplate))
+
+	path := config.MessageTemplateFile
+	if path == "" {
+		path = defaultMessageTemplateFile
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Warn("Failed to read message template, using default", logFields{"path": path, "error": err})
+		}
+		return fallback
+	}
+
+	tmpl, err := template.New("message").Option("missingkey=error").Parse(string(content))
+	if err != nil {
+		logger.Warn("Invalid message template, using default", logFields{"path": path, "error": err})
+		return fallback
+	}
+
+	// Prova di esecuzione con dati di esempio, per scoprire subito campi inesistenti
+	sample := MessageData{StoreID: "ITSAMPLE", StoreName: "Sample Store", CheckedAt: formatTimestamp(time.Now()), Emoji: emojiSet()}
+	if err := tmpl.Execute(&bytes.Buffer{}, sample); err != nil {
+		logger.Warn("Message template failed to render, using default", logFields{"path": path, "error": err})
+		return fallback
+	}
+
+	logger.Info("Custom message template loaded", logFields{"path": path})
+	return tmpl
+}
+
+// Funzione per generare il testo della notifica per un risultato
+func renderMessage(result CheckResult) (string, error) {
+	var buf bytes.Buffer
+	if err := messageTemplate.Execute(&buf, newMessageData(result)); err != nil {
+		return "", fmt.Errorf("failed to render message template: %v", err)
+	}
+	return buf.String(), nil
+}
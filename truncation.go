@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+)
+
+// Nuove richieste dopo una risposta troncata, prima di considerarla un errore, e attesa
+// prima della prima di esse (raddoppiata ad ogni tentativo)
+const (
+	truncatedResponseRetries = 2
+	truncatedRetryDelay      = time.Second
+)
+
+// Funzione per riconoscere un corpo interrotto a metà (connessione chiusa prima della fine,
+// corpo più corto di Content-Length): a differenza di un JSON completo con uno schema diverso,
+// ripetere la richiesta può risolvere il problema
+func truncatedResponse(err error) bool {
+	if err == nil {
+		return false
+	}
+	var decodeErr *decodeError
+	if errors.As(err, &decodeErr) && decodeErr.Truncated {
+		return true
+	}
+	return errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// Funzione per capire se l'errore di json.Unmarshal indica un JSON finito prima del previsto
+func truncatedJSON(body []byte, err error) bool {
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return len(body) > 0 && syntaxErr.Offset >= int64(len(body))
+	}
+	return errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// Funzione per eseguire fetch ripetendola, fino a truncatedResponseRetries volte, quando la
+// risposta arriva troncata; gli altri errori (schema, HTTP, rete) vengono restituiti subito
+func refetchIfTruncated(fields logFields, fetch func() error) error {
+	delay := truncatedRetryDelay
+	for attempt := 1; ; attempt++ {
+		err := fetch()
+		if !truncatedResponse(err) {
+			return err
+		}
+		if attempt > truncatedResponseRetries || appCtx.Err() != nil {
+			logger.Error("Response still truncated, giving up", errorFields(fields, err).with("attempts", attempt))
+			return err
+		}
+		logger.Warn("Truncated response, fetching again", errorFields(fields, err).with("attempt", attempt, "delay", delay.String()))
+		select {
+		case <-appCtx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
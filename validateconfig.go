@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// Problema trovato nella configurazione, con il file e (se noto) la riga di riferimento
+type configProblem struct {
+	File    string
+	Line    int
+	Message string
+}
+
+func (p configProblem) String() string {
+	if p.Line > 0 {
+		return fmt.Sprintf("%s:%d: %s", p.File, p.Line, p.Message)
+	}
+	return fmt.Sprintf("%s: %s", p.File, p.Message)
+}
+
+// Funzione per trovare la riga (1-based) di un offset nel contenuto
+func lineAt(content []byte, offset int64) int {
+	if offset > int64(len(content)) {
+		offset = int64(len(content))
+	}
+	return bytes.Count(content[:offset], []byte("\n")) + 1
+}
+
+// Funzione per trovare la riga in cui compare una chiave JSON ("key"), 0 se non trovata
+func lineOfKey(content []byte, key string) int {
+	i := bytes.Index(content, []byte(`"`+key+`"`))
+	if i < 0 {
+		return 0
+	}
+	return lineAt(content, int64(i))
+}
+
+// Funzione per controllare tutti i file di configurazione e raccogliere ogni problema,
+// invece di fermarsi al primo come loadConfig
+func validateConfiguration() []configProblem {
+	var problems []configProblem
+	add := func(file string, line int, format string, args ...interface{}) {
+		problems = append(problems, configProblem{File: file, Line: line, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if content, err := os.ReadFile(configFile); err == nil {
+		problems = append(problems, validateConfigJSON(content)...)
+	} else if !os.IsNotExist(err) {
+		add(configFile, 0, "%v", err)
+	}
+
+	// File storici delle singole impostazioni
+	if ids, err := readStoreIDs(); err != nil {
+		add(storeIDFile, 0, "%v", err)
+	} else {
+		for i, id := range ids {
+			if !validStoreID(strings.TrimSpace(id)) {
+				add(storeIDFile, i+1, "invalid store ID %q", id)
+			}
+		}
+	}
+	if interval, err := readCheckInterval(); err != nil {
+		add(intervalFile, 1, "%v", err)
+	} else if interval < 0 {
+		add(intervalFile, 1, "interval must not be negative")
+	}
+	if country, err := readCountrySelection(); err == nil {
+		if code, _ := resolveCountry(country); code == "" {
+			add("country_selection.txt", 1, "invalid country %q: use one of %s", country, strings.Join(supportedCountries, ", "))
+		}
+	}
+	if webhookURL, err := readWebhookURL(); err == nil {
+		if err := validateWebhookURL(webhookURL); err != nil {
+			add("webhook_url.txt", 1, "%v", err)
+		}
+	}
+
+	return problems
+}
+
+// Funzione per controllare config.json: sintassi, chiavi sconosciute e valori di ogni campo
+func validateConfigJSON(content []byte) []configProblem {
+	var problems []configProblem
+	add := func(key string, format string, args ...interface{}) {
+		problems = append(problems, configProblem{File: configFile, Line: lineOfKey(content, key), Message: fmt.Sprintf(format, args...)})
+	}
+
+	cfg := defaultConfig()
+	dec := json.NewDecoder(bytes.NewReader(content))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
+		switch {
+		case errors.As(err, &syntaxErr):
+			return append(problems, configProblem{File: configFile, Line: lineAt(content, syntaxErr.Offset), Message: "syntax error: " + syntaxErr.Error()})
+		case errors.As(err, &typeErr):
+			return append(problems, configProblem{File: configFile, Line: lineAt(content, typeErr.Offset), Message: fmt.Sprintf("%s must be %s, not %s", typeErr.Field, typeErr.Type, typeErr.Value)})
+		case strings.HasPrefix(err.Error(), "json: unknown field "):
+			key := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+			add(key, "unknown setting %q (check the spelling)", key)
+			// Le chiavi sconosciute non impediscono di controllare il resto
+			cfg = defaultConfig()
+			if err := json.Unmarshal(content, &cfg); err != nil {
+				add(key, "%v", err)
+				return problems
+			}
+		default:
+			problems = append(problems, configProblem{File: configFile, Message: err.Error()})
+			return problems
+		}
+	}
+
+	if cfg.Timezone != "" {
+		if _, err := time.LoadLocation(cfg.Timezone); err != nil {
+			add("timezone", "invalid timezone %q", cfg.Timezone)
+		}
+	}
+	for _, country := range cfg.Countries {
+		if err := checkCountryCode(country); err != nil {
+			add("countries", "%v", err)
+		}
+	}
+	for country, interval := range cfg.CountryIntervals {
+		if err := checkCountryCode(country); err != nil {
+			add("country_intervals", "%v", err)
+		}
+		if interval.Duration <= 0 {
+			add("country_intervals", "interval for %s must be positive", country)
+		}
+	}
+	for _, pid := range cfg.Products {
+		if strings.TrimSpace(pid) == "" {
+			add("products", "empty product ID")
+		}
+	}
+	for _, category := range cfg.Categories {
+		if u, err := url.Parse(category); err != nil || u.Host == "" {
+			add("categories", "invalid category URL %q", category)
+		}
+	}
+	for _, city := range cfg.MonitorCities {
+		if strings.TrimSpace(city) == "" {
+			add("monitor_cities", "empty city name")
+		}
+	}
+	for _, id := range cfg.FavoriteStores {
+		if !validStoreID(strings.TrimSpace(id)) {
+			add("favorite_stores", "invalid store ID %q", id)
+		}
+	}
+	if cfg.AvailabilityMode != "" {
+		if err := validateAvailabilityMode(cfg.AvailabilityMode); err != nil {
+			add("availability_mode", "%v", err)
+		}
+	}
+	if err := validateStateFormat(cfg.StateFormat); err != nil {
+		add("state_format", "%v", err)
+	}
+	if err := validateNotificationStyle(cfg.NotificationStyle, cfg.Emoji); err != nil {
+		add("notification_style", "%v", err)
+	}
+	if err := validateSearchAreas(cfg.SearchArea); err != nil {
+		add("search_area", "%v", err)
+	}
+	if err := validateStoreServices(cfg.StoreServices); err != nil {
+		add("store_services", "%v", err)
+	}
+	if cfg.RequestSpacing.Duration < 0 {
+		add("request_spacing", "must not be negative, got %v", cfg.RequestSpacing)
+	}
+	if cfg.RequestJitter.Duration < 0 {
+		add("request_jitter", "must not be negative, got %v", cfg.RequestJitter)
+	}
+	if cfg.NotifierFailureThreshold < 0 {
+		add("notifier_failure_threshold", "must be 0 or more, got %d", cfg.NotifierFailureThreshold)
+	}
+	if err := validateNotifyAfter(cfg.StoreNotifyAfter); err != nil {
+		add("store_notify_after", "%v", err)
+	}
+	for _, group := range cfg.Variants {
+		if err := group.validate(); err != nil {
+			add("variants", "%v", err)
+		}
+	}
+	if cfg.MinQuantity < 0 {
+		add("min_quantity", "must be 0 or more, got %d", cfg.MinQuantity)
+	}
+	switch cfg.AvailabilitySource {
+	case "", sourceLocator:
+	case sourcePDP:
+		if cfg.PDPStockEndpoint == "" {
+			add("availability_source", "%q requires pdp_stock_endpoint", sourcePDP)
+		}
+	default:
+		add("availability_source", "invalid value %q: use %q or %q", cfg.AvailabilitySource, sourceLocator, sourcePDP)
+	}
+	if err := validateFulfillmentMode(cfg.Fulfillment); err != nil {
+		add("fulfillment", "%v", err)
+	}
+	if err := cfg.QuietHours.validate(); err != nil {
+		add("quiet_hours", "%v", err)
+	}
+	if err := cfg.WebhookRoutes.validate(); err != nil {
+		add("webhook_routes", "%v", err)
+	}
+	if err := cfg.DistanceColors.validate(); err != nil {
+		add("distance_colors", "%v", err)
+	}
+	if err := validateProxies(cfg.Proxies); err != nil {
+		add("proxies", "%v", err)
+	}
+	if err := cfg.Ntfy.validate(); err != nil {
+		add("ntfy", "%v", err)
+	}
+	if err := cfg.MonitorQueue.validate(); err != nil {
+		add("monitor_queue", "%v", err)
+	}
+	if err := cfg.LocatorRequest.validate("locator_request"); err != nil {
+		add("locator_request", "%v", err)
+	}
+	if err := cfg.PDPStockRequest.validate("pdp_stock_request"); err != nil {
+		add("pdp_stock_request", "%v", err)
+	}
+	if home := cfg.HomeLocation; home != nil {
+		if home.Latitude < -90 || home.Latitude > 90 {
+			add("home_location", "latitude %v out of range (-90..90)", home.Latitude)
+		}
+		if home.Longitude < -180 || home.Longitude > 180 {
+			add("home_location", "longitude %v out of range (-180..180)", home.Longitude)
+		}
+	}
+	for host, pin := range cfg.CertPins {
+		if raw, err := base64.StdEncoding.DecodeString(pin); err != nil || len(raw) != 32 {
+			add("cert_pins", "pin for %s is not a base64 SHA-256 hash", host)
+		}
+	}
+	for key, value := range map[string]int{
+		"confirm_attempts":        cfg.ConfirmAttempts,
+		"max_products":            cfg.MaxProducts,
+		"offline_threshold":       cfg.OfflineThreshold,
+		"max_idle_conns":          cfg.MaxIdleConns,
+		"max_idle_conns_per_host": cfg.MaxIdleConnsPerHost,
+		"backup_keep":             cfg.BackupKeep,
+	} {
+		if value < 0 {
+			add(key, "must not be negative")
+		}
+	}
+	if cfg.MaxResponseSize < 0 {
+		add("max_response_size", "must not be negative")
+	}
+	if cfg.NotifyRate < 0 {
+		add("notify_rate", "must not be negative")
+	}
+	if cfg.BackoffFactor != 0 && cfg.BackoffFactor <= 1 {
+		add("backoff_factor", "must be greater than 1")
+	}
+	if cfg.BackoffMin.Duration > 0 && cfg.BackoffMax.Duration > 0 && cfg.BackoffMax.Duration < cfg.BackoffMin.Duration {
+		add("backoff_max", "must not be lower than backoff_min")
+	}
+
+	return problems
+}
+
+// Funzione per stampare il risultato della validazione; ritorna true se non ci sono problemi
+func printConfigReport(problems []configProblem) bool {
+	if len(problems) == 0 {
+		color.Green("Configuration OK.\n")
+		return true
+	}
+	color.Red("Found %d configuration problems:\n", len(problems))
+	for _, problem := range problems {
+		fmt.Printf("  %s\n", problem)
+	}
+	return false
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Variante (taglia, tonalità) di un prodotto, con un proprio PID
+type ProductVariant struct {
+	PID  string `json:"pid"`
+	Name string `json:"name"`
+}
+
+// Prodotto con varianti: i PID vengono ricavati dalla pagina prodotto (product_url) oppure
+// indicati esplicitamente, e ogni variante viene controllata separatamente
+type VariantGroup struct {
+	// Nome del prodotto mostrato negli avvisi insieme al nome della variante
+	Label string `json:"label"`
+	// Pagina prodotto da cui ricavare le varianti (opzionale)
+	ProductURL string `json:"product_url"`
+	// Varianti indicate esplicitamente, usate in aggiunta a quelle della pagina
+	Variants []ProductVariant `json:"variants"`
+}
+
+// Funzione per validare un gruppo di varianti configurato
+func (g VariantGroup) validate() error {
+	if strings.TrimSpace(g.Label) == "" {
+		return fmt.Errorf("variant group without label")
+	}
+	if g.ProductURL == "" && len(g.Variants) == 0 {
+		return fmt.Errorf("variant group %q: set product_url or variants", g.Label)
+	}
+	if g.ProductURL != "" {
+		if u, err := url.Parse(g.ProductURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("variant group %q: invalid product_url %q", g.Label, g.ProductURL)
+		}
+	}
+	for _, variant := range g.Variants {
+		if strings.TrimSpace(variant.PID) == "" {
+			return fmt.Errorf("variant group %q: variant without pid", g.Label)
+		}
+	}
+	return nil
+}
+
+// Nome completo ("Prodotto - Variante") di ogni PID di variante, per gli avvisi
+var (
+	variantLabelsMu sync.Mutex
+	variantLabels   = make(map[string]string)
+)
+
+// Funzione per ottenere il nome della variante di un PID ("" se non è una variante)
+func variantLabel(pid string) string {
+	variantLabelsMu.Lock()
+	defer variantLabelsMu.Unlock()
+	return variantLabels[pid]
+}
+
+// Funzione per ricavare i PID delle varianti configurate. Le varianti delle pagine prodotto
+// vengono riscaricate insieme alle categorie; se una pagina non risponde si mantengono
+// le varianti trovate in precedenza.
+func (w *productWatchList) refreshVariants() {
+	if w.pageVariants == nil {
+		w.pageVariants = make(map[string][]ProductVariant)
+	}
+	labels := make(map[string]string)
+	var pids []string
+	for _, group := range config.Variants {
+		variants := append([]ProductVariant{}, group.Variants...)
+		if group.ProductURL != "" {
+			resolved, err := resolveProductVariants(group.ProductURL)
+			if err != nil {
+				logger.Warn("Failed to resolve product variants", logFields{"product_url": group.ProductURL, "error": err})
+			} else {
+				w.pageVariants[group.ProductURL] = resolved
+			}
+			variants = append(variants, w.pageVariants[group.ProductURL]...)
+		}
+		for _, variant := range variants {
+			pid := strings.TrimSpace(variant.PID)
+			if _, seen := labels[pid]; seen {
+				continue
+			}
+			labels[pid] = variantDisplayName(group.Label, variant.Name)
+			pids = append(pids, pid)
+		}
+	}
+
+	variantLabelsMu.Lock()
+	variantLabels = labels
+	variantLabelsMu.Unlock()
+	if len(pids) != len(w.variantPIDs) {
+		logger.Info("Product variants refreshed", logFields{"groups": len(config.Variants), "variants": len(pids)})
+	}
+	w.variantPIDs = pids
+}
+
+// Suffisso delle righe di output con il nome della variante, se presente
+func variantSuffix(result CheckResult) string {
+	if result.Variant == "" {
+		return ""
+	}
+	return fmt.Sprintf(", Variant: %s", result.Variant)
+}
+
+func variantDisplayName(label string, name string) string {
+	if name == "" {
+		return label
+	}
+	return label + " - " + name
+}
+
+// Dati strutturati (JSON-LD) delle pagine prodotto e attributi dei selettori di variante
+var (
+	jsonLDPattern     = regexp.MustCompile(`(?is)<script[^>]*application/ld\+json[^>]*>(.*?)</script>`)
+	variantTagPattern = regexp.MustCompile(`<[^>]+data-pid="([^"]+)"[^>]*>`)
+	variantNameAttr   = regexp.MustCompile(`(?:data-attr-value|data-variant-name|aria-label|title)="([^"]+)"`)
+)
+
+// Funzione per ricavare le varianti da una pagina prodotto: prima dai dati JSON-LD
+// (hasVariant o offers con sku), altrimenti dagli elementi con data-pid e un nome
+func resolveProductVariants(productURL string) ([]ProductVariant, error) {
+	body, err := fetchPage(productURL, logFields{"product_url": productURL})
+	if err != nil {
+		return nil, err
+	}
+	variants := parseProductVariants(body)
+	if len(variants) == 0 {
+		return nil, fmt.Errorf("no variants found in product page")
+	}
+	return variants, nil
+}
+
+// Funzione per estrarre le varianti dall'HTML di una pagina prodotto
+func parseProductVariants(body []byte) []ProductVariant {
+	var variants []ProductVariant
+	seen := make(map[string]bool)
+	add := func(pid string, name string) {
+		pid = strings.TrimSpace(pid)
+		if pid == "" || seen[pid] {
+			return
+		}
+		seen[pid] = true
+		variants = append(variants, ProductVariant{PID: pid, Name: strings.TrimSpace(html.UnescapeString(name))})
+	}
+
+	for _, match := range jsonLDPattern.FindAllSubmatch(body, -1) {
+		var data interface{}
+		if json.Unmarshal(match[1], &data) != nil {
+			continue
+		}
+		collectJSONLDVariants(data, add)
+	}
+	if len(variants) == 0 {
+		for _, tag := range variantTagPattern.FindAllSubmatch(body, -1) {
+			if name := variantNameAttr.FindSubmatch(tag[0]); name != nil {
+				add(string(tag[1]), string(name[1]))
+			}
+		}
+	}
+	return variants
+}
+
+// Funzione per cercare ricorsivamente le varianti nei dati JSON-LD. hasVariant e offers
+// possono essere un elenco oppure un singolo oggetto.
+func collectJSONLDVariants(value interface{}, add func(pid string, name string)) {
+	switch v := value.(type) {
+	case []interface{}:
+		for _, item := range v {
+			collectJSONLDVariants(item, add)
+		}
+	case map[string]interface{}:
+		for _, key := range []string{"hasVariant", "offers"} {
+			var items []interface{}
+			switch field := v[key].(type) {
+			case []interface{}:
+				items = field
+			case map[string]interface{}:
+				items = []interface{}{field}
+			default:
+				continue
+			}
+			for _, item := range items {
+				entry, ok := item.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				sku, _ := entry["sku"].(string)
+				name, _ := entry["name"].(string)
+				add(sku, name)
+			}
+		}
+		if graph, ok := v["@graph"]; ok {
+			collectJSONLDVariants(graph, add)
+		}
+	}
+}
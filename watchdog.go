@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"time"
+)
+
+// Watchdog di connettività: dopo un certo numero di errori di rete consecutivi considera
+// la macchina offline e sospende i controlli finché una sonda di connessione non riesce.
+type connectivityWatchdog struct {
+	failures int
+	offline  bool
+}
+
+// Funzione per riconoscere gli errori di rete (DNS, connessione rifiutata, timeout...)
+func isNetworkError(err error) bool {
+	var netErr net.Error
+	var dnsErr *net.DNSError
+	var opErr *net.OpError
+	return errors.As(err, &netErr) || errors.As(err, &dnsErr) || errors.As(err, &opErr)
+}
+
+func (w *connectivityWatchdog) recordSuccess() {
+	w.failures = 0
+}
+
+// Registra un errore di rete e ritorna true se è stata raggiunta la soglia per andare offline
+func (w *connectivityWatchdog) recordFailure() bool {
+	w.failures++
+	threshold := config.OfflineThreshold
+	if threshold <= 0 {
+		threshold = 3
+	}
+	return w.failures >= threshold
+}
+
+// Funzione per sospendere i controlli finché l'host dell'endpoint non torna raggiungibile.
+// I cambi di stato vengono registrati una sola volta, senza ripetere l'errore ad ogni sonda.
+func (w *connectivityWatchdog) waitForConnectivity(endpoint_url string) {
+	host := "www.sephora.fr"
+	if u, err := url.Parse(endpoint_url); err == nil && u.Hostname() != "" {
+		host = u.Hostname()
+	}
+
+	interval := config.OfflineProbeInterval.Duration
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+
+	w.offline = true
+	logger.Warn("Network appears to be offline, pausing checks", logFields{"failures": w.failures, "probe_interval": interval.String()})
+
+	for {
+		select {
+		case <-appCtx.Done():
+			return
+		case <-time.After(interval):
+		}
+
+		conn, err := net.DialTimeout("tcp", net.JoinHostPort(host, "443"), 5*time.Second)
+		if err == nil {
+			conn.Close()
+			break
+		}
+		logger.Debug("Connectivity probe failed", logFields{"host": host, "error": err})
+	}
+
+	w.offline = false
+	w.failures = 0
+	logger.Info("Network is back online, resuming checks", logFields{"host": host})
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// Store e prodotti monitorati all'ultimo avvio dello sniper, per mostrare all'avvio successivo
+// cosa è cambiato nei file di configurazione
+const lastWatchListFile = "last_watchlist.json"
+
+type watchListSnapshot struct {
+	Country    string   `json:"country"`
+	Stores     []string `json:"stores"`
+	Products   []string `json:"products"`
+	Categories []string `json:"categories,omitempty"`
+	Cities     []string `json:"cities,omitempty"`
+}
+
+func currentWatchList(settings runtimeSettings) watchListSnapshot {
+	products := settings.Config.Products
+	if len(products) == 0 && len(settings.Config.Categories) == 0 && len(settings.Config.Variants) == 0 {
+		products = []string{defaultProductID}
+	}
+	sorted := func(values []string) []string {
+		out := append([]string{}, values...)
+		sort.Strings(out)
+		return out
+	}
+	return watchListSnapshot{
+		Country:    strings.TrimSpace(settings.Country),
+		Stores:     sorted(settings.StoreIDs),
+		Products:   sorted(products),
+		Categories: sorted(settings.Config.Categories),
+		Cities:     sorted(settings.Config.MonitorCities),
+	}
+}
+
+// Funzione per stampare all'avvio le differenze rispetto all'ultima esecuzione (store, prodotti,
+// categorie e città aggiunti o rimossi) e salvare la lista attuale per il prossimo avvio
+func printWatchListDiff(settings runtimeSettings) {
+	current := currentWatchList(settings)
+	var previous watchListSnapshot
+	found, err := readStateFile(lastWatchListFile, &previous)
+	if err != nil {
+		logger.Warn("Failed to read the previous watch list", logFields{"error": err})
+	}
+
+	if found && err == nil {
+		labels, _ := readStoreLabels()
+		var lines []string
+		diff := func(kind string, before []string, after []string, label func(string) string) {
+			added, removed := diffStoreIDs(before, after)
+			for _, value := range added {
+				lines = append(lines, color.GreenString("  + %s %s%s", kind, value, label(value)))
+			}
+			for _, value := range removed {
+				lines = append(lines, color.RedString("  - %s %s%s", kind, value, label(value)))
+			}
+		}
+		none := func(string) string { return "" }
+		storeLabel := func(id string) string {
+			if labels[id] != "" {
+				return " (" + labels[id] + ")"
+			}
+			return ""
+		}
+		if previous.Country != current.Country {
+			lines = append(lines, color.YellowString("  country %s -> %s", previous.Country, current.Country))
+		}
+		diff("store", previous.Stores, current.Stores, storeLabel)
+		diff("product", previous.Products, current.Products, none)
+		diff("category", previous.Categories, current.Categories, none)
+		diff("city", previous.Cities, current.Cities, none)
+
+		if len(lines) == 0 {
+			outputln("Watch list unchanged since the last run.")
+		} else {
+			outputln("Watch list changes since the last run:")
+			for _, line := range lines {
+				outputln(line)
+			}
+		}
+		logger.Info("Watch list compared with the last run", logFields{"changes": len(lines)})
+	}
+
+	if readOnlyMode {
+		return
+	}
+	if err := writeStateFile(lastWatchListFile, current); err != nil {
+		logger.Warn("Failed to save the watch list", logFields{"error": err})
+	}
+}